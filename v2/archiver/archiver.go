@@ -61,27 +61,36 @@ func (server *Server) Stop() {
 	<-server.finishedCh
 }
 
-func startSaveServe(hdb *dbhist.Db, histCh <-chan []byte, finCh chan<- struct{}) {
+//startSaveServe saves the received histories and acknowledges them
+//when saved. A history that can not be decoded is acknowledged as
+//resending it would not help.
+func startSaveServe(hdb *dbhist.Db, histCh <-chan *arnet.RecvHist, finCh chan<- struct{}) {
 	noSaved := 0
 Loop:
 	for {
 		no := cropNo(len(histCh))
 		hists := make([]*bg.Hist, 0, no)
+		ackChs := make([]chan<- bool, 0, no)
 		for i := 0; i < no; i++ {
-			histBytes, open := <-histCh
+			recvHist, open := <-histCh
 			if open {
-				hist, err := arnet.HistDecoder(histBytes)
+				hist, err := arnet.HistDecoder(recvHist.Bytes)
 				if err != nil {
 					err = errors.WithMessage(err, "Decoding hist msg failed")
 					log.PrintErr(err)
+					recvHist.AckCh <- true
 				} else {
 					hists = append(hists, hist)
+					ackChs = append(ackChs, recvHist.AckCh)
 				}
 			} else {
 				break Loop
 			}
 		}
 		err := hdb.Puts(hists)
+		for _, ackCh := range ackChs {
+			ackCh <- err == nil
+		}
 		if err != nil {
 			log.PrintErr(err)
 		} else {
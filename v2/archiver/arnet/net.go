@@ -10,6 +10,20 @@ import (
 	"github.com/rezder/go-error/log"
 	"io"
 	"net"
+	"time"
+)
+
+const (
+	//ackOK the reply of a saved game history.
+	ackOK = "ok"
+	//ackFAILED the reply of a game history that was not saved.
+	ackFAILED = "failed"
+)
+
+var (
+	//ackTIMEOUT the time a sender waits for the archiver to acknowledge
+	//a game history.
+	ackTIMEOUT = time.Minute
 )
 
 //PokeListener listen for pokes and send them on.
@@ -66,9 +80,17 @@ type netZmq struct {
 }
 
 // Receiver a zmq receiver of game histories.
+// Every received history must be acknowledged before the next is received.
 type Receiver struct {
 	*netZmq
-	HistCh chan []byte
+	HistCh chan *RecvHist
+}
+
+//RecvHist is a received encoded game history. True must be send on the
+//ack channel when the history is saved and false if it failed.
+type RecvHist struct {
+	Bytes []byte
+	AckCh chan<- bool
 }
 
 // NewReceiver creates a zmq receiver of game histories.
@@ -79,7 +101,7 @@ func NewReceiver(port string) (nz *Receiver, err error) {
 	if err != nil {
 		return nz, err
 	}
-	nz.soc, err = nz.context.NewSocket(zmq4.PULL)
+	nz.soc, err = nz.context.NewSocket(zmq4.REP)
 	if err != nil {
 		closeZmq(nz.netZmq)
 		return nz, err
@@ -89,7 +111,7 @@ func NewReceiver(port string) (nz *Receiver, err error) {
 		closeZmq(nz.netZmq)
 		return nz, err
 	}
-	nz.HistCh = make(chan []byte, 400)
+	nz.HistCh = make(chan *RecvHist)
 	return nz, err
 }
 
@@ -121,11 +143,22 @@ func (nz *Receiver) Close() (err error) {
 //
 //If there is a error on the net work it closes down it may be to harsh
 //and we need to have atomic/sync/channel variable to check anyway.
-func zmqListen(receiver *zmq4.Socket, histCh chan<- []byte) {
+//
+//The reply to the sender is the acknowledgement of the history.
+func zmqListen(receiver *zmq4.Socket, histCh chan<- *RecvHist) {
 	var err error
 	var msBytes []byte
+	ackCh := make(chan bool, 1)
 	for {
 		msBytes, err = receiver.RecvBytes(0)
+		if err == nil {
+			histCh <- &RecvHist{Bytes: msBytes, AckCh: ackCh}
+			reply := ackFAILED
+			if <-ackCh {
+				reply = ackOK
+			}
+			_, err = receiver.SendBytes([]byte(reply), 0)
+		}
 		if err != nil {
 			err = errors.Wrap(err, "Closing receiver. Net work receiver zmq failed")
 			log.PrintErr(err)
@@ -135,19 +168,20 @@ func zmqListen(receiver *zmq4.Socket, histCh chan<- []byte) {
 			}
 			log.Print(log.DebugMsg, "Socket closed")
 			break
-		} else {
-			histCh <- msBytes
 		}
 	}
 	close(histCh)
 }
 
-// Sender a zmq sender of game history
+// Sender a zmq sender of encoded game histories.
+// The result of every history is received on the AckCh before the next
+// can be send, true if the archiver saved the history. The sender is
+// broken after a false result and must be stopped.
 type Sender struct {
 	*netZmq
-	finCh    chan struct{}
-	HistCh   chan *bg.Hist
-	BrokenCh chan *bg.Hist
+	finCh  chan struct{}
+	HistCh chan []byte
+	AckCh  chan bool
 }
 
 //NewSender creates a zmq sender of game histories.
@@ -158,7 +192,7 @@ func NewSender(addr string) (nz *Sender, err error) {
 	if err != nil {
 		return nz, err
 	}
-	nz.soc, err = nz.context.NewSocket(zmq4.PUSH)
+	nz.soc, err = nz.context.NewSocket(zmq4.REQ)
 	if err != nil {
 		closeZmq(nz.netZmq)
 		return nz, err
@@ -168,6 +202,11 @@ func NewSender(addr string) (nz *Sender, err error) {
 		closeZmq(nz.netZmq)
 		return nz, err
 	}
+	err = nz.soc.SetRcvtimeo(ackTIMEOUT)
+	if err != nil {
+		closeZmq(nz.netZmq)
+		return nz, err
+	}
 	err = nz.soc.Connect("tcp://" + addr) //"tcp://localhost:5558"
 	if err != nil {
 		closeZmq(nz.netZmq)
@@ -175,24 +214,27 @@ func NewSender(addr string) (nz *Sender, err error) {
 	}
 
 	nz.finCh = make(chan struct{})
-	nz.BrokenCh = make(chan *bg.Hist)
-	nz.HistCh = make(chan *bg.Hist)
+	nz.AckCh = make(chan bool)
+	nz.HistCh = make(chan []byte)
 	return nz, err
 }
 
 // Start starts a zmq sender.
 func (nz *Sender) Start() {
-	go zmqSend(nz.soc, nz.HistCh, nz.finCh, nz.BrokenCh)
+	go zmqSend(nz.soc, nz.HistCh, nz.finCh, nz.AckCh)
 }
 
-// Stop stops a zmq sender.
+// Stop stops a zmq sender, a history being send is not acknowledged.
 func (nz *Sender) Stop() {
 	log.Print(log.DebugMsg, "Closing zmq sender hist channel")
 	close(nz.HistCh)
-	select {
-	case <-nz.finCh:
-	case <-nz.BrokenCh:
-		<-nz.finCh
+Loop:
+	for {
+		select {
+		case <-nz.finCh:
+			break Loop
+		case <-nz.AckCh:
+		}
 	}
 	log.Print(log.DebugMsg, "Closing zmq sender connection")
 	closeZmq(nz.netZmq)
@@ -221,33 +263,34 @@ func closeZmq(zmq *netZmq) {
 		log.PrintErr(err)
 	}
 }
+
+//zmqSend sends the histories and waits for the acknowledgements.
+//A failed request leaves the socket unusable so the sender stops
+//after the first failure.
 func zmqSend(
 	sender *zmq4.Socket,
-	histCh <-chan *bg.Hist,
+	histCh <-chan []byte,
 	finCh chan<- struct{},
-	brokenCh chan<- *bg.Hist) {
+	ackCh chan<- bool) {
 
 	var err error
-	var msBytes []byte
-	var hist *bg.Hist
-	var open bool
+	var reply []byte
 	var n int
-	for {
-		hist, open = <-histCh
-		if open {
-			msBytes, err = HistEncode(hist)
-			if err != nil {
-				err = errors.Wrap(err, "Game history encoding for zmq failed")
-				log.PrintErr(err)
-			} else {
-				log.Print(log.DebugMsg, "Zmq sends game history")
-				n, err = sender.SendBytes(msBytes, 0)
-				log.Printf(log.DebugMsg, "Zmq send %v bytes", n)
-				if err != nil { //TODO MAYBE resend.
-					brokenCh <- hist
-				}
-			}
-		} else {
+	for msBytes := range histCh {
+		log.Print(log.DebugMsg, "Zmq sends game history")
+		n, err = sender.SendBytes(msBytes, 0)
+		log.Printf(log.DebugMsg, "Zmq send %v bytes", n)
+		if err == nil {
+			reply, err = sender.RecvBytes(0)
+		}
+		if err == nil && string(reply) != ackOK {
+			err = errors.Errorf("Archiver replied %v", string(reply))
+		}
+		if err != nil {
+			log.PrintErr(errors.Wrap(err, "Zmq game history not acknowledged"))
+		}
+		ackCh <- err == nil
+		if err != nil {
 			break
 		}
 	}
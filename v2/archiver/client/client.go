@@ -1,6 +1,7 @@
 package client
 
 import (
+	"github.com/boltdb/bolt"
 	"github.com/pkg/errors"
 	"github.com/rezder/go-battleline/v2/archiver/arnet"
 	bg "github.com/rezder/go-battleline/v2/game"
@@ -10,19 +11,28 @@ import (
 )
 
 //Client handle connection to game history archiver.
+//The game histories is saved in a outbox before they are send and
+//deleted when the archiver acknowledges them. The outbox is replayed
+//on start and when a archiver pokes.
 type Client struct {
 	archiverAdr  string
 	histCh       chan *bg.Hist
 	finishCh     chan struct{}
 	pokeListener net.Listener
+	outbox       *outbox
 }
 
 //New create client that handles connection to an archiver.
-func New(pokePort int, achiverAdr string) (c *Client, err error) {
+//The outbox is kept in the database.
+func New(pokePort int, achiverAdr string, db *bolt.DB) (c *Client, err error) {
 	c = new(Client)
 	c.archiverAdr = achiverAdr
 	c.histCh = make(chan *bg.Hist, 25)
 	c.finishCh = make(chan struct{})
+	c.outbox, err = newOutbox(db)
+	if err != nil {
+		return c, err
+	}
 	c.pokeListener, err = net.Listen("tcp", ":"+strconv.Itoa(pokePort))
 	return c, err
 }
@@ -31,7 +41,7 @@ func New(pokePort int, achiverAdr string) (c *Client, err error) {
 func (c *Client) Start() {
 	addServerCh := make(chan string)
 	go arnet.PokeListener(c.pokeListener, addServerCh)
-	go start(c.archiverAdr, c.histCh, addServerCh, c.finishCh)
+	go start(c.archiverAdr, c.histCh, addServerCh, c.outbox, c.finishCh)
 }
 func startZmqSender(archiverAdrs []string) (*arnet.Sender, []string) {
 	var conn *arnet.Sender
@@ -52,13 +62,22 @@ func startZmqSender(archiverAdrs []string) (*arnet.Sender, []string) {
 	}
 	return conn, archiverAdrs
 }
+
+//start saves the game histories in the outbox and sends them one at a
+//time to the archiver. A history is deleted from the outbox when
+//acknowledged, if not the connection is broken and the next archiver
+//address is tried. The histories stays in the outbox until a archiver
+//is available.
 func start(
 	serverAddr string,
 	histCh <-chan *bg.Hist,
 	addServerCh <-chan string,
+	box *outbox,
 	finishCh chan<- struct{}) {
 
 	var archConn *arnet.Sender
+	var key, histBytes []byte //The next or the history being send.
+	isSending := false
 
 	archiverAdrs := make([]string, 0, 2)
 	if len(serverAddr) != 0 {
@@ -67,31 +86,47 @@ func start(
 	}
 Loop:
 	for {
+		var sendCh chan<- []byte
+		var ackCh <-chan bool
+		if archConn != nil {
+			ackCh = archConn.AckCh
+			if key == nil {
+				var err error
+				key, histBytes, err = box.first()
+				if err != nil {
+					log.PrintErr(err)
+				}
+			}
+			if key != nil && !isSending {
+				sendCh = archConn.HistCh
+			}
+		}
 		select {
-		case hist, open := <-histCh: //TODO what if 25 game histories buffer is to small and it blocks because zmq is to slow. Should it close down archiver and try a new one. Hint check len(histCh and close if full)
+		case hist, open := <-histCh:
 			if !open {
 				break Loop
-			} else {
-				if archConn != nil {
-					//Assume no buffer on histCh and brokenCh as it will block on broken or miss game histories
-					select {
-					case archConn.HistCh <- hist:
-					case resendHist := <-archConn.BrokenCh:
-						log.Printf(log.DebugMsg, "Zmq broken closing history channel on %v", archiverAdrs[0])
-						archConn.Stop()
-						archConn = nil
-						archiverAdrs = archiverAdrs[1:]
-						archConn, archiverAdrs = startZmqSender(archiverAdrs)
-						if archConn != nil {
-							archConn.HistCh <- resendHist
-						}
-					}
-
+			}
+			if err := box.put(hist); err != nil {
+				log.PrintErr(err)
+			}
+		case sendCh <- histBytes:
+			isSending = true
+		case isOk := <-ackCh:
+			isSending = false
+			if isOk {
+				if err := box.delete(key); err != nil {
+					log.PrintErr(err)
 				}
+				key = nil
+			} else {
+				log.Printf(log.DebugMsg, "Zmq broken closing history channel on %v", archiverAdrs[0])
+				archConn.Stop()
+				archiverAdrs = archiverAdrs[1:]
+				archConn, archiverAdrs = startZmqSender(archiverAdrs)
 			}
 		case server := <-addServerCh:
 			archiverAdrs = append(archiverAdrs, server)
-			if len(archiverAdrs) == 1 {
+			if archConn == nil {
 				archConn, archiverAdrs = startZmqSender(archiverAdrs)
 			}
 		}
@@ -108,6 +143,11 @@ func (c *Client) Archive(hist *bg.Hist) {
 	c.histCh <- hist
 }
 
+//QueueLen returns the number of game histories waiting to be send.
+func (c *Client) QueueLen() int {
+	return len(c.histCh) + c.outbox.len()
+}
+
 //Stop stops the connection to the archiver.
 func (c *Client) Stop() {
 	err := c.pokeListener.Close()
@@ -1,15 +1,35 @@
 package client
 
 import (
+	"github.com/boltdb/bolt"
 	"github.com/rezder/go-battleline/v2/archiver/arnet"
 	bg "github.com/rezder/go-battleline/v2/game"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
-func TestMq(t *testing.T) {
+//openTestDb opens a bolt database in a temporary directory.
+func openTestDb(t *testing.T) (db *bolt.DB, fileName string) {
+	dir, err := ioutil.TempDir("", "archclient")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	fileName = filepath.Join(dir, "outbox.db")
+	db, err = bolt.Open(fileName, 0600, nil)
+	if err != nil {
+		t.Fatalf("Open database failed: %v", err)
+	}
+	return db, fileName
+}
 
-	client, err := New(7373, "")
+func TestMq(t *testing.T) {
+	db, _ := openTestDb(t)
+	defer func() { _ = db.Close() }()
+	client, err := New(7373, "", db)
 	if err != nil {
 		t.Fatalf("Create poke listener failed: %v", err)
 	}
@@ -26,19 +46,28 @@ func TestMq(t *testing.T) {
 
 	game := createGame(1, 2, 7)
 	client.Archive(game.Hist)
-	histBytes, open := <-nzr.HistCh
+	recvHist, open := <-nzr.HistCh
+	if open {
+		recvHist.AckCh <- true
+		for i := 0; i < 100 && client.QueueLen() != 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if no := client.QueueLen(); no != 0 {
+			t.Errorf("Acknowledged game should be deleted from outbox got: %v", no)
+		}
+	}
+	client.Stop()
 	if !open {
 		t.Error("No Game send channel closed")
 	} else {
 		t.Log("recieved game")
 	}
-	client.Stop()
 	err = nzr.Close()
 	if err != nil {
 		t.Errorf("Closing zmq receiver failed with %v", err)
 	}
 	if open {
-		histSend, err := arnet.HistDecoder(histBytes)
+		histSend, err := arnet.HistDecoder(recvHist.Bytes)
 		if err != nil {
 			t.Errorf("Hist decode failed %v", err)
 		}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"encoding/binary"
+
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/archiver/arnet"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-error/log"
+)
+
+//outbox is the game histories waiting to be archived. The histories is
+//saved encoded in a bucket with a sequence number key, so they are send
+//in the order they was finished.
+type outbox struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+//newOutbox creates the outbox bucket if it does not exist.
+func newOutbox(db *bolt.DB) (box *outbox, err error) {
+	box = new(outbox)
+	box.db = db
+	box.bucket = []byte("ArchiveOutboxBucket")
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, txErr := tx.CreateBucketIfNotExists(box.bucket)
+		if txErr != nil {
+			return errors.Wrapf(txErr, log.ErrNo(34)+"Creating bucket %v", string(box.bucket))
+		}
+		return nil
+	})
+	return box, err
+}
+
+//put adds a game history.
+func (box *outbox) put(hist *bg.Hist) (err error) {
+	histBytes, err := arnet.HistEncode(hist)
+	if err != nil {
+		return errors.Wrapf(err, "Encoding game history %v,%v failed", hist.PlayerIDs, hist.Time)
+	}
+	err = box.db.Update(func(tx *bolt.Tx) error {
+		bck := tx.Bucket(box.bucket)
+		seq, txErr := bck.NextSequence()
+		if txErr != nil {
+			return txErr
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, seq)
+		return bck.Put(key, histBytes)
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Saving game history %v,%v in outbox failed", hist.PlayerIDs, hist.Time)
+	}
+	return err
+}
+
+//first returns the oldest encoded game history, key is nil if the
+//outbox is empty.
+func (box *outbox) first() (key, histBytes []byte, err error) {
+	err = box.db.View(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket(box.bucket).Cursor().First()
+		if k != nil {
+			key = append([]byte(nil), k...)
+			histBytes = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if err != nil {
+		err = errors.Wrap(err, "Reading outbox failed")
+	}
+	return key, histBytes, err
+}
+
+//delete removes an archived game history.
+func (box *outbox) delete(key []byte) (err error) {
+	err = box.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(box.bucket).Delete(key)
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Deleting outbox key %v failed", key)
+	}
+	return err
+}
+
+//len returns the number of game histories in the outbox.
+func (box *outbox) len() (no int) {
+	_ = box.db.View(func(tx *bolt.Tx) error {
+		no = tx.Bucket(box.bucket).Stats().KeyN
+		return nil
+	})
+	return no
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/rezder/go-battleline/v2/archiver/arnet"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"testing"
+)
+
+func TestOutbox(t *testing.T) {
+	db, fileName := openTestDb(t)
+	box, err := newOutbox(db)
+	if err != nil {
+		t.Fatalf("Creating outbox failed: %v", err)
+	}
+	hists := []*bg.Hist{createGame(1, 2, 3).Hist, createGame(1, 2, 5).Hist}
+	for _, hist := range hists {
+		if err = box.put(hist); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if no := box.len(); no != len(hists) {
+		t.Errorf("Expected %v histories got: %v", len(hists), no)
+	}
+	key, histBytes, err := box.first()
+	if err != nil || key == nil {
+		t.Fatalf("First failed: %v, %v", key, err)
+	}
+	hist, err := arnet.HistDecoder(histBytes)
+	if err != nil || !hist.IsEqual(hists[0]) {
+		t.Errorf("Expected the first history got: %v, %v", hist, err)
+	}
+	if err = box.delete(key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	db, err = bolt.Open(fileName, 0600, nil)
+	if err != nil {
+		t.Fatalf("Reopen database failed: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	box, err = newOutbox(db)
+	if err != nil {
+		t.Fatalf("Reopen outbox failed: %v", err)
+	}
+	if no := box.len(); no != 1 {
+		t.Errorf("Expected the history not acknowledged to be kept got: %v", no)
+	}
+	_, histBytes, _ = box.first()
+	if hist, err = arnet.HistDecoder(histBytes); err != nil || !hist.IsEqual(hists[1]) {
+		t.Errorf("Expected the second history got: %v, %v", hist, err)
+	}
+}
+
+func TestOutboxNoArchiver(t *testing.T) {
+	db, _ := openTestDb(t)
+	defer func() { _ = db.Close() }()
+	client, err := New(0, "", db)
+	if err != nil {
+		t.Fatalf("Creating client failed: %v", err)
+	}
+	client.Start()
+	client.Archive(createGame(1, 2, 4).Hist)
+	client.Stop()
+	if no := client.QueueLen(); no != 1 {
+		t.Errorf("History should be kept in the outbox without a archiver got: %v", no)
+	}
+}
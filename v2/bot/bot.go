@@ -1,38 +1,87 @@
 package bot
 
 import (
-	"encoding/json"
-	"fmt"
 	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/client"
 	"github.com/rezder/go-battleline/v2/game"
 	"github.com/rezder/go-battleline/v2/http/games"
-	lg "github.com/rezder/go-battleline/v2/http/login"
 	"github.com/rezder/go-error/log"
-	"golang.org/x/net/websocket"
-	"io"
-	"net/http"
-	"net/http/cookiejar"
-	"net/url"
 	"time"
 )
 
-//Bot a battleline bot. The bot may finish on its own if the connection is lost
-// in that case the finConnCh is closed.
+var (
+	//reconnectMinDELAY the first delay before reconnecting, the delay
+	//doubles for every failure.
+	reconnectMinDELAY = time.Second
+	//reconnectMaxDELAY the maximum delay before reconnecting.
+	reconnectMaxDELAY = time.Minute
+)
+
+const (
+	//reconnectMaxFAILS the default number of reconnect failures in a row
+	//before the bot gives up.
+	reconnectMaxFAILS = 10
+	//pongDELAY the list request interval that keeps the connection open.
+	pongDELAY = 7 * time.Minute
+	//resumeInviteMAX the number of invites to resume a game with a
+	//opponent before the opponent loses the priority.
+	resumeInviteMAX = 3
+)
+
+//Bot a battleline bot. The bot reconnects when the connection is lost and
+//finish on its own if it fails MaxReconnects times in a row or the game
+//limit is reached, in that case the finConnCh is closed.
 type Bot struct {
-	ws            *websocket.Conn
+	client        *client.Client
+	dial          func() (*client.Client, error)
 	doneCh        chan struct{}
 	FinConnCh     chan struct{}
 	inviteHandler InviteHandler
 	mover         Mover
 	name          string
 	limitNoGame   int
+	//MaxReconnects is the number of reconnect failures in a row before
+	//the bot gives up, zero disables reconnects.
+	MaxReconnects int
 }
 
 // New create a battleline bot.
+// The bot logs in with the api token of its bot account.
+// caFile is a pem CA bundle used to verify the server certificate
+// when the scheme is https, if empty the system CAs is used.
 // remember to call cancel or stop to close all connections
 // if created without errors.
 func New(
-	scheme, gameURL, name, pw string,
+	scheme, gameURL, name, token, caFile string,
+	limitNoGames int,
+	inviteHandler InviteHandler,
+	mover Mover,
+) (bot *Bot, err error) {
+	dial := func() (*client.Client, error) {
+		return client.Dial(scheme, gameURL, name, token, caFile)
+	}
+	return newBot(dial, name, limitNoGames, inviteHandler, mover)
+}
+
+// NewPassword create a battleline bot that logs in with a password.
+// Deprecated: Bot accounts can only log in with a api token, use New
+// with the token of a account created with battclients -role=bot.
+func NewPassword(
+	scheme, gameURL, name, password, caFile string,
+	limitNoGames int,
+	inviteHandler InviteHandler,
+	mover Mover,
+) (bot *Bot, err error) {
+	dial := func() (*client.Client, error) {
+		return client.DialPassword(scheme, gameURL, name, password, caFile)
+	}
+	return newBot(dial, name, limitNoGames, inviteHandler, mover)
+}
+
+//newBot creates a bot and logs in with the dial function.
+func newBot(
+	dial func() (*client.Client, error),
+	name string,
 	limitNoGames int,
 	inviteHandler InviteHandler,
 	mover Mover,
@@ -44,21 +93,18 @@ func New(
 	bot.mover = mover
 	bot.name = name
 	bot.limitNoGame = limitNoGames
-	cookies, err := login(scheme, gameURL, name, pw)
+	bot.MaxReconnects = reconnectMaxFAILS
+	bot.dial = dial
+	bot.client, err = bot.dial()
 	if err != nil {
 		return nil, err
 	}
-	ws, err := createWs(scheme, gameURL, cookies)
-	if err != nil {
-		return nil, err
-	}
-	bot.ws = ws
 	return bot, err
 }
 
 //Cancel cancel a created bot.
 func (bot *Bot) Cancel() {
-	wsErr := bot.ws.Close()
+	wsErr := bot.client.Close()
 	if wsErr != nil {
 		log.PrintErr(wsErr)
 	}
@@ -66,7 +112,7 @@ func (bot *Bot) Cancel() {
 
 //Start starts a batttleline bot.
 func (bot *Bot) Start() {
-	go serve(bot.ws, bot.doneCh, bot.FinConnCh, bot.inviteHandler, bot.mover, bot.name, bot.limitNoGame)
+	go serve(bot)
 }
 
 //Stop stops a battleline bot.
@@ -75,328 +121,301 @@ func (bot *Bot) Stop() {
 	log.Println(log.Verbose, "Bot stopping because of interrupt signal")
 	<-bot.FinConnCh
 	log.Println(log.Verbose, "Bot stopped")
-	err := bot.ws.Close()
-	if err != nil {
-		log.PrintErr(errors.Wrap(err, "Closing websocket"))
-	}
 }
 
-//login logs in to the game server.
-func login(scheme, gameURL, name, pw string) (cookies []*http.Cookie, err error) {
-	client := new(http.Client) //TODO can not handle https maybe because of a nginx bug solved in 1.11 current docker version is 1.10
-	jar, err := cookiejar.New(nil)
-	if err != nil {
-		return cookies, err
-	}
-	client.Jar = jar
-	resp, err := client.PostForm(scheme+"://"+gameURL+"/post/login",
-		url.Values{"txtUserName": {name}, "pwdPassword": {pw}})
-	if err != nil {
-		return cookies, err
+//serve plays battleline games. When the connection is lost the bot logs
+//in again and resumes the interrupted game.
+func serve(bot *Bot) {
+	defer close(bot.FinConnCh)
+	p := &player{
+		c:             bot.client,
+		inviteHandler: bot.inviteHandler,
+		mover:         bot.mover,
+		name:          bot.name,
+		limitNoGame:   bot.limitNoGame,
+		resumeOpps:    make(map[int]bool),
+		resumeTries:   make(map[int]int),
 	}
-	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil && err == nil {
-			err = cerr
+	noFails := 0
+	for {
+		startTs := time.Now()
+		if isStop := p.run(bot.doneCh); isStop {
+			break
 		}
-	}()
-	decoder := json.NewDecoder(resp.Body)
-	var tmp struct{ LogInStatus lg.Status }
-	err = decoder.Decode(&tmp)
-	if err != nil {
-		err = errors.Wrap(err, "Decoding of login status failed.")
-		return cookies, err
-	}
-	loginStatus := tmp.LogInStatus
-	if !loginStatus.IsOk() {
-		err = fmt.Errorf("Login failed: %v", loginStatus)
-		return cookies, err
-	}
-
-	cookiesURL, err := url.Parse(scheme + "://" + gameURL + "/in/gamews")
-	if err != nil {
-		return cookies, err
-	}
-	cookies = client.Jar.Cookies(cookiesURL) //Strips the path from cookie proberly not a problem
-	okCookies := false
-	if len(cookies) != 0 {
-		for _, cookie := range cookies {
-			if cookie.Name == "sid" {
-				okCookies = true
-				break
-			}
+		p.interrupt()
+		if time.Since(startTs) < reconnectMaxDELAY {
+			noFails = noFails + 1
+		} else {
+			noFails = 0
+		}
+		var isOk bool
+		p.c, noFails, isOk = reconnect(bot.dial, bot.doneCh, bot.MaxReconnects, noFails)
+		if !isOk {
+			break
 		}
 	}
-	if !okCookies {
-		err = errors.New("Invalid cookies")
-		return cookies, err
-	}
-	return cookies, err
-}
-
-//createWs creates the websocket connection.
-func createWs(scheme string, addrPort string, cookies []*http.Cookie) (conn *websocket.Conn,
-	err error) {
-	wsScheme := "ws://"
-	if scheme == "https" {
-		wsScheme = "wss://"
-	}
-	//Second argument is the orgin of the javascript that create the websocket.
-	//It is only used in handshake my handshake is not using it .
-	config, err := websocket.NewConfig(wsScheme+addrPort+"/in/gamews", "http://localhost/")
-	if err != nil {
-		return conn, err
-	}
-	addCookies(config, cookies)
-	return websocket.DialConfig(config)
-
+	log.Printf(log.Verbose, "Number of game played: %v, number of wins: %v", p.noGame, p.noWins)
 }
 
-//addCookies adds cookies to the websocket header.
-func addCookies(config *websocket.Config, cookies []*http.Cookie) {
-	if len(cookies) != 0 {
-		value := ""
-		for _, cookie := range cookies {
-			ctxt := fmt.Sprintf("%s=%s", cookie.Name, cookie.Value)
-			if len(value) == 0 {
-				value = ctxt
-			} else {
-				value = value + "; " + ctxt
-			}
-
+//reconnect logs in again with exponential backoff. noFails is the
+//failures in a row, a connection that was lost fast counts as a failure
+//so a server that keeps closing the connection is not hammered.
+//isOk is false if the bot is stopped or gives up.
+func reconnect(
+	dial func() (*client.Client, error),
+	doneCh <-chan struct{},
+	maxFails int,
+	noFails int,
+) (c *client.Client, fails int, isOk bool) {
+	for noFails < maxFails {
+		delay := reconnectMinDELAY << uint(noFails)
+		if delay > reconnectMaxDELAY || delay <= 0 {
+			delay = reconnectMaxDELAY
 		}
-		config.Header.Set("Cookie", value)
+		log.Printf(log.Min, "Connection lost, reconnecting in %v", delay)
+		select {
+		case <-time.After(delay):
+		case <-doneCh:
+			return nil, noFails, false
+		}
+		c, err := dial()
+		if err == nil {
+			log.Println(log.Min, "Reconnected")
+			return c, noFails, true
+		}
+		log.PrintErr(errors.Wrap(err, "Reconnect failed"))
+		noFails = noFails + 1
 	}
+	log.Printf(log.Min, "Giving up after %v reconnect failures", noFails)
+	return nil, noFails, false
 }
 
-//serve plays battleline games.
-func serve(
-	conn *websocket.Conn,
-	doneCh <-chan struct{},
-	finConnCh chan<- struct{},
-	inviteHandler InviteHandler,
-	mover Mover,
-	name string,
-	limitNoGame int) {
+//player handles the server messages of a bot.
+//resumeOpps is the opponents of saved and interrupted games, they are
+//invited before other players to resume the games, resumeTries is the
+//number of invites to resume the games.
+type player struct {
+	client.NopHandler
+	c              *client.Client
+	inviteHandler  InviteHandler
+	mover          Mover
+	name           string
+	limitNoGame    int
+	playingData    *games.PlayingChData
+	resumeOpps     map[int]bool
+	resumeTries    map[int]int
+	interruptedOpp int
+	isLimit        bool
+	noGame         int
+	noWins         int
+}
 
-	noGame := 0
-	noWins := 0
-	defer close(finConnCh)
-	messCh := make(chan *JsonDataTemp)
-	messDoneCh := make(chan struct{})
-	go netRead(conn, messCh, messDoneCh)
-	var playingData *games.PlayingChData
-	pongTimer := time.NewTimer(7 * time.Minute)
+//run runs the client until the connection is lost, isStop is true
+//if the bot is stopped or the game limit is reached.
+func (p *player) run(doneCh <-chan struct{}) (isStop bool) {
+	runCh := make(chan error, 1)
+	go func(c *client.Client) { runCh <- c.Run(p) }(p.c)
+	pongTimer := time.NewTimer(pongDELAY)
 Loop:
 	for {
 		select {
 		case <-pongTimer.C:
-			act := games.NewAction(games.ACTIDList)
 			log.Println(log.DebugMsg, "Timer Request list")
-			ok := netWrite(conn, act)
-			if !ok {
-				close(messDoneCh)
+			if err := p.c.List(); err != nil {
+				log.PrintErr(err)
 				break Loop
 			}
-			pongTimer.Reset(7 * time.Minute)
+			pongTimer.Reset(pongDELAY)
 		case <-doneCh:
-			close(messDoneCh)
+			isStop = true
 			break Loop
-		case jsonDataTemp, open := <-messCh:
-			if open {
-				switch jsonDataTemp.JsonType {
-				case games.JTList:
-					if playingData == nil {
-						var list map[string]*games.PubData
-						err := json.Unmarshal(jsonDataTemp.Data, &list)
-						if err != nil {
-							err = errors.Wrap(err, "Unmarshal List failed")
-							log.PrintErr(err)
-							close(messDoneCh)
-							break Loop
-						}
-						cap := 0
-						if len(list) > 1 {
-							cap = len(list) - 1
-						}
-						readyOpps := make([]*games.PubData, 0, cap)
-						isRequestList := false
-						for _, player := range list {
-							if player.Opp == 0 && player.Name != name {
-								readyOpps = append(readyOpps, player)
-							} else if player.Opp != 0 && player.Name == name {
-								isRequestList = true
-							}
-						}
+		case err := <-runCh:
+			if err != nil {
+				log.PrintErr(err)
+			}
+			runCh = nil
+			break Loop
+		}
+	}
+	pongTimer.Stop()
+	p.close()
+	if runCh != nil {
+		<-runCh
+	}
+	return isStop || p.isLimit
+}
 
-						if isRequestList {
-							act := games.NewAction(games.ACTIDList)
-							log.Println(log.DebugMsg, "Request list")
-							ok := netWrite(conn, act)
-							if !ok {
-								close(messDoneCh)
-								break Loop
-							}
-						} else if len(readyOpps) > 0 {
-							invite := inviteHandler.SendInvite(readyOpps)
-							if invite != 0 {
-								act := games.NewAction(games.ACTIDInvite)
-								act.ID = invite
-								log.Printf(log.DebugMsg, "Sending invite to %v", invite)
-								ok := netWrite(conn, act)
-								if !ok {
-									close(messDoneCh)
-									break Loop
-								}
-							}
-						}
-					}
+//interrupt stops the game of a lost connection, the opponent is
+//remembered so the game can be resumed.
+func (p *player) interrupt() {
+	if p.playingData != nil {
+		opp := p.opp(p.playingData)
+		log.Printf(log.DebugMsg, "Game with %v interrupted", opp)
+		p.interruptedOpp = opp
+		p.resumeOpps[opp] = true
+		p.mover.GameStop(p.playingData)
+		p.playingData = nil
+	}
+}
 
-				case games.JTInvite:
-					var invite games.Invite
-					err := json.Unmarshal(jsonDataTemp.Data, &invite)
-					if err != nil {
-						err = errors.Wrap(err, "Unmarshal Invite failed")
-						log.PrintErr(err)
-						close(messDoneCh)
-						break Loop
-					}
-					if !invite.IsRejected {
-						if playingData == nil && inviteHandler.AcceptInvite(&invite) {
-							act := games.NewAction(games.ACTIDInvAccept)
-							act.ID = invite.InvitorID
-							log.Printf(log.DebugMsg, "Accepting invite from %v", invite.InvitorID)
-							ok := netWrite(conn, act)
-							if !ok {
-								close(messDoneCh)
-								break Loop
-							}
-						} else {
-							act := games.NewAction(games.ACTIDInvDecline)
-							act.ID = invite.InvitorID
-							log.Printf(log.DebugMsg, "Declining invite from %v", invite.InvitorID)
-							ok := netWrite(conn, act)
-							if !ok {
-								close(messDoneCh)
-								break Loop
-							}
-						}
-					} else {
-						log.Printf(log.DebugMsg, "Invite to %v rejected", invite.ReceiverID)
-					}
-				case games.JTMess:
-				case games.JTPlaying:
-					var tmpPlayingData games.PlayingChData
-					err := json.Unmarshal(jsonDataTemp.Data, &tmpPlayingData)
-					if err != nil {
-						err = errors.Wrap(err, "Unmarshal playing data failed")
-						log.PrintErr(err)
-						close(messDoneCh)
-						break Loop
-					}
-					if playingData == nil {
-						playingData = &tmpPlayingData
-						noGame = noGame + 1
-						if playingData.ViewPos.LastMoveType == game.MoveTypeAll.Init {
-							mover.GameStart(playingData)
-						} else {
-							mover.GameRestart(playingData)
-						}
-					} else {
-						playingData = &tmpPlayingData
-					}
-					viewPos := playingData.ViewPos
-					if viewPos.Winner < 2 || !viewPos.LastMoveType.HasNext() {
-						if viewPos.Winner == viewPos.View.Playerix() {
-							noWins = noWins + 1
-						}
-						if viewPos.LastMoveType.IsPause() {
-							mover.GameStop(playingData)
-						} else {
-							mover.GameFinish(playingData)
-						}
-						playingData = nil
-						if noGame == limitNoGame {
-							close(messDoneCh)
-							break Loop
-						}
+//opp returns the opponent id of a game.
+func (p *player) opp(data *games.PlayingChData) int {
+	if data.ViewPos.View.Playerix() == 1 {
+		return data.PlayingIDs[0]
+	}
+	return data.PlayingIDs[1]
+}
 
-					} else if len(viewPos.Moves) > 0 {
-						moveix := mover.Move(viewPos)
-						act := games.NewAction(games.ACTIDMove)
-						act.Moveix = moveix
-						ok := netWrite(conn, act)
-						if !ok {
-							close(messDoneCh)
-							break Loop
-						}
-					}
-				case games.JTWatching:
-				case games.JTCloseCon:
-					var closeCon games.CloseCon
-					err := json.Unmarshal(jsonDataTemp.Data, &closeCon)
-					if err == nil {
-						log.Printf(log.DebugMsg, "Server closed connection: %v", closeCon.Reason)
-					} else {
-						err = errors.Wrap(err, "Unmarshal CloseCon failed")
-						log.PrintErr(err)
-					}
-					close(messDoneCh)
+//send closes the connection if sending the action failed.
+func (p *player) send(err error) {
+	if err != nil {
+		log.PrintErr(err)
+		p.close()
+	}
+}
 
-					break Loop
-				case games.JTClearInvites:
-				default:
-					txt := fmt.Sprintf("Message not implemented yet for %v\n", jsonDataTemp.JsonType)
-					panic(txt)
-				}
+//close closes the connection, it stops the client.
+func (p *player) close() {
+	if err := p.c.Close(); err != nil {
+		log.PrintErr(errors.Wrap(err, "Closing websocket"))
+	}
+}
 
-			} else { //closed messCh
-				break Loop
+//List invites a ready opponent, opponents of saved games first until
+//they have declined resumeInviteMAX invites. The opponents of saved
+//games are not invited to new games as the server rejects the invites.
+func (p *player) List(list map[string]*games.PubData) {
+	if tracker, ok := p.inviteHandler.(TrackingInviteHandler); ok {
+		tracker.UpdateList(list)
+	}
+	if p.playingData != nil {
+		return
+	}
+	cap := 0
+	if len(list) > 1 {
+		cap = len(list) - 1
+	}
+	readyOpps := make([]*games.PubData, 0, cap)
+	isRequestList := false
+	resumeOpp := 0
+	for _, pubData := range list {
+		if pubData.Opp == 0 && pubData.Name != p.name {
+			if !p.resumeOpps[pubData.ID] {
+				readyOpps = append(readyOpps, pubData)
+			} else if p.resumeTries[pubData.ID] < resumeInviteMAX && p.acceptResume(pubData.ID, pubData.Name) {
+				resumeOpp = pubData.ID
 			}
+		} else if pubData.Opp != 0 && pubData.Name == p.name {
+			isRequestList = true
+		}
+	}
+	if isRequestList {
+		log.Println(log.DebugMsg, "Request list")
+		p.send(p.c.List())
+	} else if resumeOpp != 0 {
+		log.Printf(log.DebugMsg, "Sending invite to %v to resume game", resumeOpp)
+		p.resumeTries[resumeOpp] = p.resumeTries[resumeOpp] + 1
+		p.send(p.c.Resume(resumeOpp, 0))
+	} else if len(readyOpps) > 0 {
+		invite := p.inviteHandler.SendInvite(readyOpps)
+		if invite != 0 {
+			log.Printf(log.DebugMsg, "Sending invite to %v", invite)
+			p.send(p.c.Invite(invite, 0, 0))
 		}
 	}
-	pongTimer.Stop()
-	log.Printf(log.Verbose, "Number of game played: %v, number of wins: %v", noGame, noWins)
 }
 
-//netWrite writes to the websocket.
-func netWrite(conn *websocket.Conn, act *games.Action) bool {
-	err := websocket.JSON.Send(conn, act)
-	if err != nil {
-		err = errors.Wrapf(err, "Writing action %v to websocket failed", act)
-		log.PrintErr(err)
-		return false
+//Invite accepts or declines a invite, invites to resume a game is
+//decided by acceptResume.
+func (p *player) Invite(invite *games.Invite) {
+	if invite.IsRejected {
+		log.Printf(log.DebugMsg, "Invite to %v rejected", invite.ReceiverID)
+		return
+	}
+	isAccept := false
+	if p.playingData == nil {
+		if invite.SavedGame != nil {
+			isAccept = p.acceptResume(invite.InvitorID, invite.InvitorName)
+		} else {
+			isAccept = p.inviteHandler.AcceptInvite(invite)
+		}
+	}
+	if isAccept {
+		log.Printf(log.DebugMsg, "Accepting invite from %v", invite.InvitorID)
+		p.send(p.c.Accept(invite.InvitorID))
+	} else {
+		log.Printf(log.DebugMsg, "Declining invite from %v", invite.InvitorID)
+		p.send(p.c.Decline(invite.InvitorID))
+	}
+}
+
+//acceptResume returns true if the game with the opponent may be
+//resumed, the invite handler decides if it is a ResumeInviteHandler
+//else all games is resumed.
+func (p *player) acceptResume(oppID int, oppName string) bool {
+	if resumer, ok := p.inviteHandler.(ResumeInviteHandler); ok {
+		return resumer.AcceptResume(oppID, oppName)
 	}
 	return true
+}
 
+//SavedGames remembers the opponents of the saved games.
+func (p *player) SavedGames(savedGames games.SavedGames) {
+	for _, savedGame := range savedGames {
+		p.resumeOpps[savedGame.OppID] = true
+	}
 }
 
-//netRead listent to the websocket and forward the message.
-func netRead(conn *websocket.Conn, messCh chan<- *JsonDataTemp, messDoneCh <-chan struct{}) {
-	defer close(messCh)
-Loop:
-	for {
-		var jsonMess JsonDataTemp
-		err := websocket.JSON.Receive(conn, &jsonMess)
-		if err == nil {
-			select {
-			case messCh <- &jsonMess:
-			case <-messDoneCh:
-				break Loop
+//Playing plays the game. The resumed interrupted game is not counted
+//again.
+func (p *player) Playing(data *games.PlayingChData) {
+	if data.ViewPos == nil {
+		return
+	}
+	if p.playingData == nil {
+		opp := p.opp(data)
+		if opp == p.interruptedOpp {
+			p.interruptedOpp = 0
+		} else {
+			p.noGame = p.noGame + 1
+			if tracker, ok := p.inviteHandler.(TrackingInviteHandler); ok {
+				tracker.GameStarted(opp)
 			}
-		} else if err == io.EOF {
-			break Loop
+		}
+		delete(p.resumeOpps, opp)
+		delete(p.resumeTries, opp)
+		if data.ViewPos.LastMoveType == game.MoveTypeAll.Init {
+			p.mover.GameStart(data)
 		} else {
-			err = errors.Wrap(err, "Reading from websocket failed")
-			log.PrintErr(err)
-			break Loop
+			p.mover.GameRestart(data)
+		}
+	}
+	p.playingData = data
+	viewPos := data.ViewPos
+	if viewPos.Winner < 2 || !viewPos.LastMoveType.HasNext() {
+		if viewPos.Winner == viewPos.View.Playerix() {
+			p.noWins = p.noWins + 1
+		}
+		if viewPos.LastMoveType.IsPause() {
+			p.resumeOpps[p.opp(data)] = true
+			p.mover.GameStop(data)
+		} else {
+			p.mover.GameFinish(data)
+		}
+		p.playingData = nil
+		if p.noGame == p.limitNoGame {
+			log.Printf(log.Verbose, "Game limit %v reached", p.limitNoGame)
+			p.isLimit = true
+			p.close()
 		}
+	} else if len(viewPos.Moves) > 0 {
+		p.send(p.c.Move(p.mover.Move(viewPos)))
 	}
 }
 
-//JsonDataTemp is the JsonData type before we can umarshal the interface values.
-type JsonDataTemp struct {
-	JsonType int
-	Data     json.RawMessage
+//Close logs the reason.
+func (p *player) Close(closeCon *games.CloseCon) {
+	log.Printf(log.DebugMsg, "Server closed connection: %v", closeCon.Reason)
 }
 
 //InviteHandler handles invites
@@ -0,0 +1,278 @@
+package bot
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rezder/go-battleline/v2/client"
+	bg "github.com/rezder/go-battleline/v2/game"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+	"github.com/rezder/go-battleline/v2/http/games"
+	"golang.org/x/net/websocket"
+)
+
+type testMover struct {
+	calls []string
+}
+
+func (m *testMover) GameStart(data *games.PlayingChData)   { m.calls = append(m.calls, "start") }
+func (m *testMover) GameRestart(data *games.PlayingChData) { m.calls = append(m.calls, "restart") }
+func (m *testMover) GameStop(data *games.PlayingChData)    { m.calls = append(m.calls, "stop") }
+func (m *testMover) GameFinish(data *games.PlayingChData)  { m.calls = append(m.calls, "finish") }
+func (m *testMover) Move(viewPos *bg.ViewPos) int {
+	m.calls = append(m.calls, "move")
+	return 0
+}
+
+//testSession serves a connection, it sends the messages and receive
+//one action after every message in expActs that is not zero.
+//The connection is closed when done unless isWaitClose.
+type testSession struct {
+	messages    []*games.JsonData
+	expActs     []int
+	isWaitClose bool
+}
+
+//testServer serves a session per connection and returns the received
+//actions of every session.
+func testServer(t *testing.T, sessions []testSession) (addr string, actCh <-chan []*games.Action) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultCh := make(chan []*games.Action, len(sessions))
+	sessionCh := make(chan testSession, len(sessions))
+	for _, session := range sessions {
+		sessionCh <- session
+	}
+	close(sessionCh)
+	receive := func(ws *websocket.Conn, acts []*games.Action) ([]*games.Action, bool) {
+		var act games.Action
+		if err := games.WireCodec.Receive(ws, &act); err != nil {
+			return acts, false
+		}
+		return append(acts, &act), true
+	}
+	handler := websocket.Handler(func(ws *websocket.Conn) {
+		session, isOpen := <-sessionCh
+		if !isOpen {
+			return
+		}
+		var acts []*games.Action
+		defer func() { resultCh <- acts }()
+		isOk := true
+		if acts, isOk = receive(ws, acts); !isOk {
+			return
+		}
+		for i, jdata := range session.messages {
+			if err := games.WireCodec.Send(ws, jdata); err != nil {
+				return
+			}
+			if session.expActs[i] != 0 {
+				if acts, isOk = receive(ws, acts); !isOk {
+					return
+				}
+			}
+		}
+		for isOk && session.isWaitClose {
+			acts, isOk = receive(ws, acts)
+		}
+	})
+	server := &http.Server{Handler: handler}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+	return listener.Addr().String(), resultCh
+}
+
+func TestReconnect(t *testing.T) {
+	oldDelay := reconnectMinDELAY
+	reconnectMinDELAY = 10 * time.Millisecond
+	defer func() { reconnectMinDELAY = oldDelay }()
+
+	game := bg.NewGame()
+	game.Start([2]int{2, 1}, 0)
+	moves := game.Pos.CalcMoves()
+	me := moves[0].Mover
+	startPos := bg.NewViewPos(game.Pos, bg.ViewAll.Players[me], dpos.NoPlayer)
+	game.Move(moves[0])
+	restartPos := bg.NewViewPos(game.Pos, bg.ViewAll.Players[me], dpos.NoPlayer)
+	finishPos := restartPos.Copy()
+	finishPos.Winner = me
+	finishPos.Moves = nil
+	var playingIDs [2]int
+	playingIDs[me] = 1
+	playingIDs[1-me] = 2
+	hello := &games.JsonData{JsonType: games.JTHello, Data: &games.Hello{Version: games.ProtocolVERSION}}
+	sessions := []testSession{
+		{
+			messages: []*games.JsonData{
+				hello,
+				{JsonType: games.JTList, Data: map[string]*games.PubData{
+					"1": {ID: 1, Name: "Alice"},
+					"2": {ID: 2, Name: "Bob"}}},
+				{JsonType: games.JTPlaying, Data: &games.PlayingChData{ViewPos: startPos, PlayingIDs: playingIDs}},
+			},
+			expActs: []int{0, games.ACTIDInvite, games.ACTIDMove},
+		},
+		{
+			messages: []*games.JsonData{
+				hello,
+				{JsonType: games.JTList, Data: map[string]*games.PubData{
+					"1": {ID: 1, Name: "Alice"},
+					"2": {ID: 2, Name: "Bob"},
+					"3": {ID: 3, Name: "Carl"}}},
+				{JsonType: games.JTPlaying, Data: &games.PlayingChData{ViewPos: restartPos, PlayingIDs: playingIDs}},
+				{JsonType: games.JTPlaying, Data: &games.PlayingChData{ViewPos: finishPos, PlayingIDs: playingIDs}},
+			},
+			expActs:     []int{0, games.ACTIDInvite, games.ACTIDMove, 0},
+			isWaitClose: true,
+		},
+	}
+	addr, actCh := testServer(t, sessions)
+	mover := new(testMover)
+	bot := testBot(t, addr, NewStdInviteHandler(true), mover)
+	bot.limitNoGame = 1
+	bot.MaxReconnects = 3
+	bot.Start()
+	select {
+	case <-bot.FinConnCh:
+	case <-time.After(10 * time.Second):
+		bot.Stop()
+		t.Fatal("Bot did not finish after the game limit")
+	}
+	for i, session := range sessions {
+		acts := <-actCh
+		if len(acts) == 0 || acts[0].ActType != games.ACTIDHello {
+			t.Fatalf("Session %v expected hello got: %v", i, acts)
+		}
+		acts = acts[1:]
+		expActs := make([]int, 0, len(session.expActs))
+		for _, actType := range session.expActs {
+			if actType != 0 {
+				expActs = append(expActs, actType)
+			}
+		}
+		if len(acts) != len(expActs) {
+			t.Fatalf("Session %v expected actions %v got: %v", i, expActs, acts)
+		}
+		for j, act := range acts {
+			if act.ActType != expActs[j] {
+				t.Errorf("Session %v action %v expected type %v got: %v", i, j, expActs[j], act)
+			}
+			if act.ActType == games.ACTIDInvite && act.ID != 2 {
+				t.Errorf("Session %v expected invite to Bob got: %v", i, act)
+			}
+		}
+	}
+	expCalls := "start move stop restart move finish"
+	if calls := strings.Join(mover.calls, " "); calls != expCalls {
+		t.Errorf("Expected mover calls %q got: %q", expCalls, calls)
+	}
+}
+
+//testBot creates a bot named Alice connected to the test server.
+func testBot(t *testing.T, addr string, inviteHandler InviteHandler, mover Mover) *Bot {
+	bot := &Bot{
+		dial: func() (*client.Client, error) {
+			ws, err := websocket.Dial("ws://"+addr+"/", "", "http://"+addr+"/")
+			if err != nil {
+				return nil, err
+			}
+			return client.New(ws), nil
+		},
+		doneCh:        make(chan struct{}),
+		FinConnCh:     make(chan struct{}),
+		inviteHandler: inviteHandler,
+		mover:         mover,
+		name:          "Alice",
+	}
+	var err error
+	if bot.client, err = bot.dial(); err != nil {
+		t.Fatal(err)
+	}
+	return bot
+}
+
+func TestResumeInviteMax(t *testing.T) {
+	hello := &games.JsonData{JsonType: games.JTHello, Data: &games.Hello{Version: games.ProtocolVERSION}}
+	list := &games.JsonData{JsonType: games.JTList, Data: map[string]*games.PubData{
+		"1": {ID: 1, Name: "Alice"},
+		"2": {ID: 2, Name: "Bob"},
+		"3": {ID: 3, Name: "Carl"}}}
+	session := testSession{
+		messages: []*games.JsonData{
+			hello,
+			{JsonType: games.JTSavedGames, Data: games.SavedGames{{OppID: 2}}},
+		},
+		expActs: []int{0, 0},
+	}
+	for i := 0; i <= resumeInviteMAX; i++ {
+		session.messages = append(session.messages, list)
+		session.expActs = append(session.expActs, games.ACTIDInvite)
+	}
+	addr, actCh := testServer(t, []testSession{session})
+	bot := testBot(t, addr, NewStdInviteHandler(true), new(testMover))
+	bot.Start()
+	select {
+	case <-bot.FinConnCh:
+	case <-time.After(10 * time.Second):
+		bot.Stop()
+		t.Fatal("Bot did not finish when the connection closed")
+	}
+	acts := <-actCh
+	if len(acts) != resumeInviteMAX+2 {
+		t.Fatalf("Expected hello and %v invites got: %v", resumeInviteMAX+1, acts)
+	}
+	for i, act := range acts[1:] {
+		isResume := i < resumeInviteMAX
+		expID := 3
+		if isResume {
+			expID = 2
+		}
+		if act.ActType != games.ACTIDInvite || act.ID != expID || act.IsResume != isResume {
+			t.Errorf("Invite %v expected to %v resume %v got: %v", i, expID, isResume, act)
+		}
+	}
+}
+
+func TestResumePolicy(t *testing.T) {
+	h, err := NewPolicyInviteHandler(&Policy{Accept: Filter{NoBots: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello := &games.JsonData{JsonType: games.JTHello, Data: &games.Hello{Version: games.ProtocolVERSION}}
+	session := testSession{
+		messages: []*games.JsonData{
+			hello,
+			{JsonType: games.JTSavedGames, Data: games.SavedGames{{OppID: 5}, {OppID: 2}}},
+			{JsonType: games.JTList, Data: map[string]*games.PubData{
+				"1": {ID: 1, Name: "Alice"},
+				"5": {ID: 5, Name: "Robot", IsBot: true}}},
+			{JsonType: games.JTInvite, Data: &games.Invite{InvitorID: 5, InvitorName: "Robot", SavedGame: &games.SavedGame{OppID: 5}}},
+			{JsonType: games.JTInvite, Data: &games.Invite{InvitorID: 2, InvitorName: "Bob", SavedGame: &games.SavedGame{OppID: 2}}},
+		},
+		expActs: []int{0, 0, 0, games.ACTIDInvDecline, games.ACTIDInvAccept},
+	}
+	addr, actCh := testServer(t, []testSession{session})
+	bot := testBot(t, addr, h, new(testMover))
+	bot.Start()
+	select {
+	case <-bot.FinConnCh:
+	case <-time.After(10 * time.Second):
+		bot.Stop()
+		t.Fatal("Bot did not finish when the connection closed")
+	}
+	acts := <-actCh
+	if len(acts) != 3 {
+		t.Fatalf("Expected hello and two invite responses got: %v", acts)
+	}
+	if acts[1].ActType != games.ACTIDInvDecline || acts[1].ID != 5 {
+		t.Errorf("Expected the resume invite from the bot declined got: %v", acts[1])
+	}
+	if acts[2].ActType != games.ACTIDInvAccept || acts[2].ID != 2 {
+		t.Errorf("Expected the resume invite from Bob accepted got: %v", acts[2])
+	}
+}
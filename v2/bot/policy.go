@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/http/games"
+)
+
+//TrackingInviteHandler is a invite handler that follows the player list
+//and the games played.
+type TrackingInviteHandler interface {
+	InviteHandler
+	UpdateList(list map[string]*games.PubData)
+	GameStarted(oppID int)
+}
+
+//ResumeInviteHandler is a invite handler that decides which saved and
+//interrupted games is resumed.
+type ResumeInviteHandler interface {
+	InviteHandler
+	AcceptResume(oppID int, oppName string) bool
+}
+
+//Filter selects players. The zero filter selects all players.
+type Filter struct {
+	Names     []string //Name patterns (regular expressions) one must match, empty all names.
+	NotNames  []string //Name patterns that must not match.
+	MinRating int      //The minimum rating, 0 no minimum.
+	MaxRating int      //The maximum rating, 0 no maximum.
+	NoBots    bool     //Bots is not selected.
+	NoHumans  bool     //Humans is not selected.
+	names     []*regexp.Regexp
+	notNames  []*regexp.Regexp
+}
+
+//compile compiles the name patterns.
+func (f *Filter) compile() (err error) {
+	f.names, err = compilePatterns(f.Names)
+	if err != nil {
+		return err
+	}
+	f.notNames, err = compilePatterns(f.NotNames)
+	return err
+}
+
+func compilePatterns(patterns []string) (regs []*regexp.Regexp, err error) {
+	regs = make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		reg, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Name pattern %v", pattern)
+		}
+		regs = append(regs, reg)
+	}
+	return regs, nil
+}
+
+//IsSelected returns true if the filter selects the player. rating is
+//only used if isRated.
+func (f *Filter) IsSelected(name string, isBot bool, rating int, isRated bool) bool {
+	if (isBot && f.NoBots) || (!isBot && f.NoHumans) {
+		return false
+	}
+	if f.MinRating != 0 || f.MaxRating != 0 {
+		if !isRated || (f.MinRating != 0 && rating < f.MinRating) ||
+			(f.MaxRating != 0 && rating > f.MaxRating) {
+			return false
+		}
+	}
+	for _, reg := range f.notNames {
+		if reg.MatchString(name) {
+			return false
+		}
+	}
+	if len(f.names) == 0 {
+		return true
+	}
+	for _, reg := range f.names {
+		if reg.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+//Policy is a invite policy configuration.
+//The server does not publish ratings, the ratings of the players is
+//configured in Ratings, a player without a rating is not selected by a
+//filter with a rating range.
+type Policy struct {
+	IsInviter    bool           //Send invites else accept invites.
+	Accept       Filter         //The players whose invites is accepted.
+	Invite       Filter         //The players that is invited.
+	MaxGames     int            //The maximum number of games per opponent, 0 no maximum.
+	IsRoundRobin bool           //Invite the ready players in turn, else the first ready player.
+	Ratings      map[string]int //The player ratings by name.
+}
+
+//LoadPolicy loads a json invite policy file.
+//Example: {"IsInviter":true,"Invite":{"NoBots":true},"MaxGames":3,"IsRoundRobin":true}
+func LoadPolicy(fileName string) (policy *Policy, err error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Open policy file %v failed", fileName)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	policy = new(Policy)
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(policy); err != nil {
+		return nil, errors.Wrapf(err, "Decode policy file %v failed", fileName)
+	}
+	return policy, nil
+}
+
+//PolicyInviteHandler handles invites with a invite policy.
+type PolicyInviteHandler struct {
+	policy      *Policy
+	isBots      map[int]bool
+	noGames     map[int]int
+	lastInvited int
+}
+
+//NewPolicyInviteHandler creates a invite handler from a policy.
+func NewPolicyInviteHandler(policy *Policy) (h *PolicyInviteHandler, err error) {
+	if err = policy.Accept.compile(); err != nil {
+		return nil, errors.Wrap(err, "Accept filter")
+	}
+	if err = policy.Invite.compile(); err != nil {
+		return nil, errors.Wrap(err, "Invite filter")
+	}
+	h = new(PolicyInviteHandler)
+	h.policy = policy
+	h.isBots = make(map[int]bool)
+	h.noGames = make(map[int]int)
+	return h, nil
+}
+
+//isSelected returns true if the player is selected by the filter and
+//the game cap is not reached.
+func (h *PolicyInviteHandler) isSelected(filter *Filter, id int, name string, isBot bool) bool {
+	if h.policy.MaxGames != 0 && h.noGames[id] >= h.policy.MaxGames {
+		return false
+	}
+	rating, isRated := h.policy.Ratings[name]
+	return filter.IsSelected(name, isBot, rating, isRated)
+}
+
+//AcceptInvite accepts the invites of players selected by the accept
+//filter. A invitor missing from the list is considered human.
+func (h *PolicyInviteHandler) AcceptInvite(invite *games.Invite) bool {
+	if h.policy.IsInviter {
+		return false
+	}
+	return h.isSelected(&h.policy.Accept, invite.InvitorID, invite.InvitorName, h.isBots[invite.InvitorID])
+}
+
+//SendInvite invites a ready player selected by the invite filter.
+func (h *PolicyInviteHandler) SendInvite(readyOpps []*games.PubData) (playerID int) {
+	if !h.policy.IsInviter {
+		return 0
+	}
+	selected := make([]int, 0, len(readyOpps))
+	for _, opp := range readyOpps {
+		if h.isSelected(&h.policy.Invite, opp.ID, opp.Name, opp.IsBot) {
+			selected = append(selected, opp.ID)
+		}
+	}
+	if len(selected) == 0 {
+		return 0
+	}
+	sort.Ints(selected)
+	playerID = selected[0]
+	if h.policy.IsRoundRobin {
+		for _, id := range selected {
+			if id > h.lastInvited {
+				playerID = id
+				break
+			}
+		}
+	}
+	h.lastInvited = playerID
+	return playerID
+}
+
+//AcceptResume accepts to resume the games with the players selected by
+//the invite filter of a inviter or the accept filter. The resumed game
+//was counted when it started so the game cap only stops the games
+//above the cap.
+func (h *PolicyInviteHandler) AcceptResume(oppID int, oppName string) bool {
+	filter := &h.policy.Accept
+	if h.policy.IsInviter {
+		filter = &h.policy.Invite
+	}
+	if h.policy.MaxGames != 0 && h.noGames[oppID] > h.policy.MaxGames {
+		return false
+	}
+	rating, isRated := h.policy.Ratings[oppName]
+	return filter.IsSelected(oppName, h.isBots[oppID], rating, isRated)
+}
+
+//UpdateList remembers the bot status of the players.
+func (h *PolicyInviteHandler) UpdateList(list map[string]*games.PubData) {
+	for _, pubData := range list {
+		h.isBots[pubData.ID] = pubData.IsBot
+	}
+}
+
+//GameStarted counts the games per opponent.
+func (h *PolicyInviteHandler) GameStarted(oppID int) {
+	h.noGames[oppID] = h.noGames[oppID] + 1
+}
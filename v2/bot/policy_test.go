@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rezder/go-battleline/v2/http/games"
+)
+
+func TestFilter(t *testing.T) {
+	filter := Filter{Names: []string{"^bot", "^Al"}, NotNames: []string{"x$"}, MinRating: 1000, MaxRating: 2000, NoHumans: true}
+	if err := filter.compile(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		isBot   bool
+		rating  int
+		isRated bool
+		exp     bool
+	}{
+		{"bot1", true, 1500, true, true},
+		{"Alice", true, 1000, true, true},
+		{"Bob", true, 1500, true, false},
+		{"botx", true, 1500, true, false},
+		{"bot1", false, 1500, true, false},
+		{"bot1", true, 2001, true, false},
+		{"bot1", true, 999, true, false},
+		{"bot1", true, 0, false, false},
+	}
+	for _, test := range tests {
+		if isSelected := filter.IsSelected(test.name, test.isBot, test.rating, test.isRated); isSelected != test.exp {
+			t.Errorf("Player %v expected selected %v got %v", test, test.exp, isSelected)
+		}
+	}
+	var all Filter
+	if !all.IsSelected("Bob", true, 0, false) || !all.IsSelected("Alice", false, 0, false) {
+		t.Error("The zero filter should select all")
+	}
+	filter = Filter{Names: []string{"("}}
+	if err := filter.compile(); err == nil {
+		t.Error("Expected a name pattern error")
+	}
+}
+
+func TestPolicyInvite(t *testing.T) {
+	h, err := NewPolicyInviteHandler(&Policy{
+		IsInviter:    true,
+		Invite:       Filter{NoBots: true},
+		MaxGames:     2,
+		IsRoundRobin: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readyOpps := []*games.PubData{
+		{ID: 7, Name: "Carl"},
+		{ID: 3, Name: "Bob"},
+		{ID: 5, Name: "Robot", IsBot: true},
+	}
+	var invited []int
+	for i := 0; i < 6; i++ {
+		playerID := h.SendInvite(readyOpps)
+		invited = append(invited, playerID)
+		if playerID != 0 {
+			h.GameStarted(playerID)
+		}
+	}
+	exp := []int{3, 7, 3, 7, 0, 0}
+	for i, playerID := range invited {
+		if playerID != exp[i] {
+			t.Errorf("Expected invites %v got %v", exp, invited)
+			break
+		}
+	}
+	if h.AcceptInvite(&games.Invite{InvitorID: 9, InvitorName: "Dan"}) {
+		t.Error("A inviter should not accept invites")
+	}
+}
+
+func TestPolicyAccept(t *testing.T) {
+	h, err := NewPolicyInviteHandler(&Policy{
+		Accept:  Filter{NoBots: true, MinRating: 1200},
+		Ratings: map[string]int{"Bob": 1500, "Carl": 1100, "Robot": 1600},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.UpdateList(map[string]*games.PubData{
+		"3": {ID: 3, Name: "Bob"},
+		"5": {ID: 5, Name: "Robot", IsBot: true},
+		"7": {ID: 7, Name: "Carl"},
+	})
+	tests := map[int]bool{3: true, 5: false, 7: false}
+	names := map[int]string{3: "Bob", 5: "Robot", 7: "Carl"}
+	for id, exp := range tests {
+		if isAccept := h.AcceptInvite(&games.Invite{InvitorID: id, InvitorName: names[id]}); isAccept != exp {
+			t.Errorf("Invite from %v expected accept %v got %v", names[id], exp, isAccept)
+		}
+	}
+	if h.SendInvite([]*games.PubData{{ID: 3, Name: "Bob"}}) != 0 {
+		t.Error("A accepter should not invite")
+	}
+}
+
+func TestPolicyResume(t *testing.T) {
+	h, err := NewPolicyInviteHandler(&Policy{
+		IsInviter: true,
+		Invite:    Filter{NoBots: true, NotNames: []string{"^Carl$"}},
+		MaxGames:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.UpdateList(map[string]*games.PubData{
+		"3": {ID: 3, Name: "Bob"},
+		"5": {ID: 5, Name: "Robot", IsBot: true},
+		"7": {ID: 7, Name: "Carl"},
+	})
+	h.GameStarted(3)
+	tests := map[int]bool{3: true, 5: false, 7: false}
+	names := map[int]string{3: "Bob", 5: "Robot", 7: "Carl"}
+	for id, exp := range tests {
+		if isAccept := h.AcceptResume(id, names[id]); isAccept != exp {
+			t.Errorf("Resume with %v expected accept %v got %v", names[id], exp, isAccept)
+		}
+	}
+	h.GameStarted(3)
+	if h.AcceptResume(3, "Bob") {
+		t.Error("Resume above the game cap should not be accepted")
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	fileName := filepath.Join(dir, "policy.json")
+	txt := `{"IsInviter":true,"Invite":{"NoBots":true,"Names":["^A"]},"MaxGames":3,"IsRoundRobin":true,"Ratings":{"Alice":1400}}`
+	if err = ioutil.WriteFile(fileName, []byte(txt), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := LoadPolicy(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.IsInviter || !policy.Invite.NoBots || policy.MaxGames != 3 || !policy.IsRoundRobin ||
+		policy.Ratings["Alice"] != 1400 || len(policy.Invite.Names) != 1 {
+		t.Errorf("Unexpected policy: %+v", policy)
+	}
+	if err = ioutil.WriteFile(fileName, []byte(`{"Unknown":1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadPolicy(fileName); err == nil {
+		t.Error("Expected unknown field error")
+	}
+}
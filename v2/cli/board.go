@@ -0,0 +1,198 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-battleline/v2/game/card"
+	"github.com/rezder/go-battleline/v2/game/pos"
+)
+
+const (
+	//boardCOLWIDTH the width of a player column of the board.
+	boardCOLWIDTH = 24
+	//movesLINEWIDTH the line width of the move list.
+	movesLINEWIDTH = 78
+)
+
+//colorLetters the troop color letters in card color order.
+var colorLetters = [...]string{"", "g", "r", "p", "y", "b", "o"}
+
+//cardText returns the notation of a card, troops is the color letter
+//and the strenght, tactic cards is the lower case name and backs
+//is troop or tac.
+func cardText(c card.Card) string {
+	switch {
+	case c.IsTroop():
+		troop := card.Troop(c)
+		return colorLetters[troop.Color()] + strconv.Itoa(troop.Strenght())
+	case c.IsMorale():
+		return strings.ToLower(card.Morale(c).String())
+	case c.IsEnv():
+		return strings.ToLower(card.Env(c).String())
+	case c.IsGuile():
+		return strings.ToLower(card.Guile(c).String())
+	case c.IsBack():
+		if card.Back(c).IsTac() {
+			return "tac"
+		}
+		return "troop"
+	}
+	return "?"
+}
+
+//posText returns the notation of a card position seen from the player.
+//The positions of the opponent is prefixed with o.
+func posText(cardPos pos.Card, player int) string {
+	var txt string
+	switch {
+	case cardPos == pos.CardAll.DeckTroop:
+		return "troops"
+	case cardPos == pos.CardAll.DeckTac:
+		return "tacs"
+	case cardPos.IsOnHand():
+		txt = "hand"
+	case cardPos.IsInDish():
+		txt = "dish"
+	default:
+		txt = "f" + strconv.Itoa(cardPos.Flagix()+1)
+	}
+	if cardPos.Player() != player {
+		txt = "o" + txt
+	}
+	return txt
+}
+
+//moveText returns the notation of a move.
+//A claim is claim followed by the flag numbers, a draw is draw troop
+//or draw tac and the other moves is the card moves separated by comma.
+//A card move is the card and the new position when the card comes from
+//the hand else the old and new position separated by a dash.
+//Example: "r5 f3", "claim 1 4", "redeploy dish, g7 f2-f6".
+func moveText(move *bg.Move) string {
+	switch move.MoveType {
+	case bg.MoveTypeAll.Cone:
+		txt := "claim"
+		for _, piece := range move.Moves {
+			txt = txt + " " + strconv.Itoa(piece.Index)
+		}
+		return txt
+	case bg.MoveTypeAll.Deck:
+		return "draw " + cardText(card.Card(move.Moves[0].Index))
+	case bg.MoveTypeAll.GiveUp:
+		return "giveup"
+	case bg.MoveTypeAll.Pause:
+		return "pause"
+	}
+	pieces := make([]string, 0, len(move.Moves))
+	for _, piece := range move.Moves {
+		txt := cardText(card.Card(piece.Index)) + " "
+		oldPos, newPos := pos.Card(piece.OldPos), pos.Card(piece.NewPos)
+		if oldPos != pos.CardAll.Players[move.Mover].Hand {
+			txt = txt + posText(oldPos, move.Mover) + "-"
+		}
+		pieces = append(pieces, txt+posText(newPos, move.Mover))
+	}
+	return strings.Join(pieces, ", ")
+}
+
+//normalizeMove returns the move text in lower case with single spaces
+//and without commas.
+func normalizeMove(txt string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.Replace(txt, ",", " ", -1))), " ")
+}
+
+//parseMove finds the move index of a move in notation or a move index.
+func parseMove(moves []*bg.Move, txt string) (moveix int, err error) {
+	if len(moves) == 0 {
+		return 0, errors.New("No moves to make")
+	}
+	txt = normalizeMove(txt)
+	if ix, numErr := strconv.Atoi(txt); numErr == nil {
+		if ix < 0 || ix >= len(moves) {
+			return 0, errors.Errorf("Move index %v out of range 0-%v", ix, len(moves)-1)
+		}
+		return ix, nil
+	}
+	for ix, move := range moves {
+		if normalizeMove(moveText(move)) == txt {
+			return ix, nil
+		}
+	}
+	return 0, errors.Errorf("Move %q is not a legal move, list them with moves", txt)
+}
+
+//writeMoves writes the move indices and notations in columns.
+func writeMoves(w io.Writer, moves []*bg.Move) {
+	line := ""
+	for ix, move := range moves {
+		item := fmt.Sprintf("%2d: %-12s", ix, moveText(move))
+		if len(line)+len(item) > movesLINEWIDTH {
+			fmt.Fprintln(w, strings.TrimRight(line, " "))
+			line = ""
+		}
+		line = line + item
+	}
+	if len(line) != 0 {
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
+
+//writeBoard writes the board as text seen from the view of the position.
+//Spectators see the board from the first player. names is the player
+//names.
+func writeBoard(w io.Writer, viewPos *bg.ViewPos, names [2]string) {
+	me := viewPos.View.Playerix()
+	if me == pos.NoPlayer {
+		me = 0
+	}
+	opp := 1 - me
+	posCards := bg.NewPosCards(viewPos.CardPos)
+	fmt.Fprintf(w, "%*s | Flag | %s\n", boardCOLWIDTH, names[opp], names[me])
+	for flagix := range pos.CardAll.Players[me].Flags {
+		flag := " " + strconv.Itoa(flagix+1) + "  "
+		switch viewPos.ConePos[flagix+1].Winner() {
+		case opp:
+			flag = "<" + strconv.Itoa(flagix+1) + "  "
+		case me:
+			flag = " " + strconv.Itoa(flagix+1) + " >"
+		}
+		oppCards := cardsText(posCards.Cards(pos.CardAll.Players[opp].Flags[flagix]))
+		myCards := cardsText(posCards.Cards(pos.CardAll.Players[me].Flags[flagix]))
+		row := fmt.Sprintf("%*s | %s | %s", boardCOLWIDTH, oppCards, flag, myCards)
+		fmt.Fprintln(w, strings.TrimRight(row, " "))
+	}
+	fmt.Fprintf(w, "Decks: %v troops, %v tactics\n",
+		len(posCards.Cards(pos.CardAll.DeckTroop))-viewPos.NoTroops[0]-viewPos.NoTroops[1],
+		len(posCards.Cards(pos.CardAll.DeckTac))-viewPos.NoTacs[0]-viewPos.NoTacs[1])
+	for _, player := range []int{opp, me} {
+		hand := posCards.Cards(pos.CardAll.Players[player].Hand)
+		if len(hand) != 0 {
+			fmt.Fprintf(w, "%v hand: %v\n", names[player], cardsText(hand))
+		} else {
+			fmt.Fprintf(w, "%v hand: %v troops, %v tactics\n", names[player],
+				viewPos.NoTroops[player], viewPos.NoTacs[player])
+		}
+		if dish := posCards.Cards(pos.CardAll.Players[player].Dish); len(dish) != 0 {
+			fmt.Fprintf(w, "%v dish: %v\n", names[player], cardsText(dish))
+		}
+	}
+	if viewPos.Winner != pos.NoPlayer {
+		fmt.Fprintf(w, "Winner: %v\n", names[viewPos.Winner])
+	} else if len(viewPos.Moves) != 0 && viewPos.Moves[0].Mover == me && viewPos.View.IsPlayer() {
+		fmt.Fprintln(w, "Your move:")
+		writeMoves(w, viewPos.Moves)
+	}
+}
+
+func cardsText(cards []card.Card) string {
+	txts := make([]string, len(cards))
+	for i, c := range cards {
+		txts[i] = cardText(c)
+	}
+	return strings.Join(txts, " ")
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-battleline/v2/game/pos"
+)
+
+func TestMoveNotation(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for gameNo := 0; gameNo < 5; gameNo++ {
+		game := bg.NewGame()
+		game.Start([2]int{1, 2}, 0)
+		for {
+			moves := game.Pos.CalcMoves()
+			for ix, move := range moves {
+				txt := moveText(move)
+				parsedix, err := parseMove(moves, strings.ToUpper(txt))
+				if err != nil || parsedix != ix {
+					t.Fatalf("Move %v: %q parsed to %v, %v", ix, txt, parsedix, err)
+				}
+			}
+			if winner, _ := game.Move(moves[r.Intn(len(moves))]); winner != pos.NoPlayer {
+				break
+			}
+		}
+	}
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	if _, err := parseMove(game.Pos.CalcMoves(), "99"); err == nil {
+		t.Error("Move index out of range should fail")
+	}
+	if _, err := parseMove(game.Pos.CalcMoves(), "x9 f1"); err == nil {
+		t.Error("Illegal move should fail")
+	}
+}
+
+func TestWriteBoard(t *testing.T) {
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	game.Move(game.Pos.CalcMoves()[0])
+	moves := game.Pos.CalcMoves()
+	viewPos := bg.NewViewPos(game.Pos, bg.ViewAll.Players[moves[0].Mover], pos.NoPlayer)
+	var buf bytes.Buffer
+	writeBoard(&buf, viewPos, [2]string{"Alice", "Bob"})
+	board := buf.String()
+	t.Log("\n" + board)
+	hand := bg.NewPosCards(viewPos.CardPos).Cards(pos.CardAll.Players[moves[0].Mover].Hand)
+	for _, c := range hand {
+		if !strings.Contains(board, cardText(c)) {
+			t.Errorf("Hand card %v missing", cardText(c))
+		}
+	}
+	if !strings.Contains(board, "Your move:") || !strings.Contains(board, moveText(moves[0])) {
+		t.Error("Moves missing")
+	}
+	if !strings.Contains(board, "hand: 7 troops, 0 tactics") {
+		t.Error("Opponent hand missing")
+	}
+}
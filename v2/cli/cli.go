@@ -0,0 +1,443 @@
+//Package cli is a terminal client for the battleline game server.
+//It shows the lobby, plays, watches and chats with text commands and
+//renders the board as text.
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/client"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-battleline/v2/game/pos"
+	"github.com/rezder/go-battleline/v2/http/games"
+)
+
+//helpTXT the command help.
+const helpTXT = `Commands:
+  list                      show the players
+  invite <player> [bestof] [delay]
+                            invite a player to a game or a best of match
+  resume <player> [delay]   invite a player to resume the saved game
+  accept <player>           accept a invite
+  decline <player>          decline a invite
+  retract <player>          retract a invite
+  rematch                   invite the last opponent again
+  board                     show the game
+  moves                     list the legal moves
+  move <notation|index>     make a move, example: move r5 f3
+  save [accept|decline]     request or answer a game save
+  quit                      give up the game
+  watch <player>            watch the game of a player
+  unwatch <player>          stop watching
+  show <player>             show a watched game
+  chat <text>               chat at the game table
+  wchat <player> <text>     chat at a watched game table
+  mess <player> <text>      send a message to a player
+  exit                      log out
+A player is a id or a name.`
+
+//Client is a terminal client. It keeps the last lobby list, invites
+//and games so they can be shown on request. The server messages is
+//handled from the client connection goroutine so the state is guarded
+//by the mutex.
+type Client struct {
+	conn     *client.Client
+	name     string
+	out      io.Writer
+	mu       sync.Mutex
+	list     map[string]*games.PubData
+	invites  map[int]*games.Invite
+	playing  *games.PlayingChData
+	watching map[int]*games.WatchingChData
+	isList   bool
+}
+
+//New creates a terminal client on a logged in connection.
+//The output is written to out.
+func New(conn *client.Client, name string, out io.Writer) (c *Client) {
+	c = new(Client)
+	c.conn = conn
+	c.name = name
+	c.out = out
+	c.list = make(map[string]*games.PubData)
+	c.invites = make(map[int]*games.Invite)
+	c.watching = make(map[int]*games.WatchingChData)
+	return c
+}
+
+//Run runs the client until the exit command, the end of the input or
+//the server closes the connection.
+func (c *Client) Run(in io.Reader) (err error) {
+	runCh := make(chan error, 1)
+	go func() { runCh <- c.conn.Run(c) }()
+	fmt.Fprintln(c.out, "Type help for the commands.")
+	lineCh := make(chan string)
+	go readLines(in, lineCh)
+	err = c.conn.List()
+Loop:
+	for err == nil {
+		select {
+		case err = <-runCh:
+			runCh = nil
+			break Loop
+		case line, open := <-lineCh:
+			if !open {
+				break Loop
+			}
+			c.mu.Lock()
+			act, isExit, cmdErr := c.command(line)
+			c.mu.Unlock()
+			if cmdErr != nil {
+				fmt.Fprintln(c.out, cmdErr)
+			}
+			if isExit {
+				break Loop
+			}
+			if act != nil {
+				err = c.conn.Send(act)
+			}
+		}
+	}
+	if runCh != nil {
+		if cerr := c.conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		<-runCh
+	}
+	return err
+}
+
+//readLines reads the input lines until the end of input.
+func readLines(in io.Reader, lineCh chan<- string) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		lineCh <- scanner.Text()
+	}
+	close(lineCh)
+}
+
+//command parses a command line. Commands that only shows information
+//writes it and returns no action.
+func (c *Client) command(line string) (act *games.Action, isExit bool, err error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, false, nil
+	}
+	cmd, args := strings.ToLower(fields[0]), fields[1:]
+	switch cmd {
+	case "help":
+		fmt.Fprintln(c.out, helpTXT)
+	case "exit":
+		isExit = true
+	case "list":
+		c.isList = true
+		act = games.NewAction(games.ACTIDList)
+	case "invite":
+		act, err = c.playerAction(games.ACTIDInvite, args, 1)
+		if err == nil && len(args) > 1 {
+			act.BestOf, err = strconv.Atoi(args[1])
+		}
+		if err == nil && len(args) > 2 {
+			act.WatchDelay, err = strconv.Atoi(args[2])
+		}
+	case "resume":
+		act, err = c.playerAction(games.ACTIDInvite, args, 1)
+		if err == nil {
+			act.IsResume = true
+			if len(args) > 1 {
+				act.WatchDelay, err = strconv.Atoi(args[1])
+			}
+		}
+	case "accept":
+		act, err = c.playerAction(games.ACTIDInvAccept, args, 1)
+	case "decline":
+		act, err = c.playerAction(games.ACTIDInvDecline, args, 1)
+		if err == nil {
+			delete(c.invites, act.ID)
+		}
+	case "retract":
+		act, err = c.playerAction(games.ACTIDInvRetract, args, 1)
+	case "rematch":
+		act = games.NewAction(games.ACTIDRematch)
+	case "board":
+		if c.playing == nil || c.playing.ViewPos == nil {
+			return nil, false, errors.New("No game")
+		}
+		writeBoard(c.out, c.playing.ViewPos, c.names(c.playing.PlayingIDs))
+	case "moves":
+		if c.playing == nil || c.playing.ViewPos == nil || len(c.playing.ViewPos.Moves) == 0 {
+			return nil, false, errors.New("No moves to make")
+		}
+		writeMoves(c.out, c.playing.ViewPos.Moves)
+	case "move", "m":
+		if c.playing == nil || c.playing.ViewPos == nil {
+			return nil, false, errors.New("No game")
+		}
+		var moveix int
+		if moveix, err = parseMove(c.playing.ViewPos.Moves, strings.Join(args, " ")); err == nil {
+			act = games.NewAction(games.ACTIDMove)
+			act.Moveix = moveix
+		}
+	case "save":
+		act = games.NewAction(games.ACTIDSave)
+		if len(args) != 0 {
+			switch strings.ToLower(args[0]) {
+			case "accept":
+				act.ActType = games.ACTIDSaveAccept
+			case "decline":
+				act.ActType = games.ACTIDSaveDecline
+			default:
+				act, err = nil, errors.Errorf("Unknown save answer %v", args[0])
+			}
+		}
+	case "quit":
+		act = games.NewAction(games.ACTIDQuit)
+	case "watch":
+		act, err = c.playerAction(games.ACTIDWatch, args, 1)
+	case "unwatch":
+		act, err = c.playerAction(games.ACTIDWatchStop, args, 1)
+		if err == nil {
+			delete(c.watching, act.ID)
+		}
+	case "show":
+		var id int
+		if id, err = c.playerID(args); err == nil {
+			data, isFound := c.watching[id]
+			if !isFound || data.ViewPos == nil {
+				return nil, false, errors.Errorf("Not watching %v", args[0])
+			}
+			writeBoard(c.out, data.ViewPos, c.names(data.PlayingIDs))
+		}
+	case "chat":
+		if len(args) == 0 {
+			return nil, false, errors.New("Missing chat text")
+		}
+		act = games.NewAction(games.ACTIDChat)
+		act.Mess = strings.Join(args, " ")
+	case "wchat":
+		act, err = c.playerAction(games.ACTIDChat, args, 2)
+	case "mess":
+		act, err = c.playerAction(games.ACTIDMess, args, 2)
+	default:
+		err = errors.Errorf("Unknown command %v, type help for the commands", cmd)
+	}
+	if err != nil {
+		act = nil
+	}
+	return act, isExit, err
+}
+
+//playerAction creates a action to the player of the first argument,
+//the arguments after the first minArgs is the message.
+func (c *Client) playerAction(actType int, args []string, minArgs int) (act *games.Action, err error) {
+	if len(args) < minArgs {
+		return nil, errors.Errorf("Expected %v arguments got: %v", minArgs, len(args))
+	}
+	id, err := c.playerID(args)
+	if err != nil {
+		return nil, err
+	}
+	act = games.NewAction(actType)
+	act.ID = id
+	if minArgs > 1 {
+		act.Mess = strings.Join(args[1:], " ")
+	}
+	return act, err
+}
+
+//playerID finds the player id of the first argument, the argument is
+//a id or a player name from the list.
+func (c *Client) playerID(args []string) (id int, err error) {
+	if len(args) == 0 {
+		return 0, errors.New("Missing player")
+	}
+	if id, err = strconv.Atoi(args[0]); err == nil {
+		return id, err
+	}
+	for _, p := range c.list {
+		if strings.EqualFold(p.Name, args[0]) {
+			return p.ID, nil
+		}
+	}
+	return 0, errors.Errorf("Unknown player %v", args[0])
+}
+
+//names returns the names of the players.
+func (c *Client) names(ids [2]int) (names [2]string) {
+	for i, id := range ids {
+		names[i] = c.playerName(id)
+	}
+	return names
+}
+
+//playerName returns the name of a player from the list.
+func (c *Client) playerName(id int) string {
+	if p, isFound := c.list[strconv.Itoa(id)]; isFound {
+		return p.Name
+	}
+	return "Player " + strconv.Itoa(id)
+}
+
+//List keeps the list and writes it when requested.
+func (c *Client) List(list map[string]*games.PubData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list = list
+	if c.isList {
+		c.isList = false
+		c.writeList()
+	}
+}
+
+//Invite writes the invite.
+func (c *Client) Invite(invite *games.Invite) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case invite.IsRejected && invite.InvitorName == c.name:
+		fmt.Fprintf(c.out, "Invite to %v was declined\n", invite.ReceiverName)
+	case invite.IsRejected:
+		delete(c.invites, invite.InvitorID)
+		fmt.Fprintf(c.out, "Invite from %v was retracted\n", invite.InvitorName)
+	default:
+		c.invites[invite.InvitorID] = invite
+		txt := "a game"
+		if invite.SavedGame != nil {
+			txt = "a saved game"
+		}
+		if invite.Match != nil {
+			txt = fmt.Sprintf("a best of %v match", invite.Match.BestOf)
+		}
+		fmt.Fprintf(c.out, "%v (%v) invites you to %v, answer with accept or decline %v\n",
+			invite.InvitorName, invite.InvitorID, txt, invite.InvitorID)
+	}
+}
+
+//Mess writes the message.
+func (c *Client) Mess(mess *games.MesData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.out, "Message from %v: %v\n", mess.SenderName, mess.Message)
+}
+
+//Chat writes the chat message, watched games is prefixed with the
+//watched player.
+func (c *Client) Chat(chat *games.TableChat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if chat.ChatMess == nil {
+		return
+	}
+	if chat.WatchingID != 0 {
+		fmt.Fprintf(c.out, "[%v] ", c.playerName(chat.WatchingID))
+	}
+	c.writeChat(chat.ChatMess)
+}
+
+//Playing keeps the game and writes the board.
+func (c *Client) Playing(data *games.PlayingChData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.playing = data
+	if data.ViewPos != nil {
+		writeBoard(c.out, data.ViewPos, c.names(data.PlayingIDs))
+	}
+	if data.IsOppConnLost {
+		fmt.Fprintln(c.out, "The opponent lost the connection")
+	}
+	if data.PauseReqID != 0 {
+		fmt.Fprintf(c.out, "%v requested a game save, answer with save accept or save decline\n",
+			c.playerName(data.PauseReqID))
+	}
+	if data.Match != nil {
+		fmt.Fprintf(c.out, "Match %v-%v of best of %v\n", data.Match.Wins[0], data.Match.Wins[1], data.Match.BestOf)
+	}
+}
+
+//Watching keeps the watched game and writes the board.
+func (c *Client) Watching(data *games.WatchingChData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if data.ViewPos == nil {
+		return
+	}
+	c.watching[data.WatchingID] = data
+	names := c.names(data.PlayingIDs)
+	fmt.Fprintf(c.out, "Watching %v vs %v:\n", names[0], names[1])
+	writeBoard(c.out, data.ViewPos, names)
+}
+
+//ClearInvites clears the invites.
+func (c *Client) ClearInvites(reason games.ClearInvites) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invites = make(map[int]*games.Invite)
+	fmt.Fprintf(c.out, "Invites cleared: %v\n", reason)
+}
+
+//SavedGames writes the saved games.
+func (c *Client) SavedGames(savedGames games.SavedGames) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, game := range savedGames {
+		fmt.Fprintf(c.out, "Saved game with %v from %v\n",
+			c.playerName(game.OppID), game.GameTs.Format("2006-01-02 15:04"))
+	}
+}
+
+//Error writes the error.
+func (c *Client) Error(errMess *games.ErrMess) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.out, "Error: %v\n", errMess.Message)
+}
+
+//Close writes the reason.
+func (c *Client) Close(closeCon *games.CloseCon) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(c.out, "Connection closed: %v\n", closeCon.Reason)
+}
+
+func (c *Client) writeChat(chat *bg.ChatMess) {
+	spectator := ""
+	if chat.IsSpectator {
+		spectator = " (spectator)"
+	}
+	fmt.Fprintf(c.out, "%v %v%v: %v\n", chat.Time.Format("15:04"), chat.SenderName, spectator, chat.Message)
+}
+
+//writeList writes the players sorted by name.
+func (c *Client) writeList() {
+	players := make([]*games.PubData, 0, len(c.list))
+	for _, p := range c.list {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Name < players[j].Name })
+	fmt.Fprintf(c.out, "%6s %-16s %-16s %v\n", "ID", "Name", "Playing", "Invite")
+	for _, p := range players {
+		opp := ""
+		if p.Opp != 0 {
+			opp = p.OppName
+		}
+		kind := ""
+		if p.IsBot {
+			kind = " (bot)"
+		}
+		invite := ""
+		if _, isFound := c.invites[p.ID]; isFound {
+			invite = "invites you"
+		}
+		fmt.Fprintf(c.out, "%6v %-16s %-16s %v\n", p.ID, p.Name+kind, opp, invite)
+	}
+	if c.playing != nil && c.playing.ViewPos != nil && c.playing.ViewPos.Winner == pos.NoPlayer {
+		fmt.Fprintln(c.out, "You are playing, type board to show the game")
+	}
+}
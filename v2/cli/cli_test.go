@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-battleline/v2/game/pos"
+	"github.com/rezder/go-battleline/v2/http/games"
+)
+
+func TestCommand(t *testing.T) {
+	var out bytes.Buffer
+	c := New(nil, "Alice", &out)
+	c.list["7"] = &games.PubData{ID: 7, Name: "Bob"}
+	game := bg.NewGame()
+	game.Start([2]int{3, 7}, 0)
+	moves := game.Pos.CalcMoves()
+	viewPos := bg.NewViewPos(game.Pos, bg.ViewAll.Players[moves[0].Mover], pos.NoPlayer)
+	c.playing = &games.PlayingChData{ViewPos: viewPos, PlayingIDs: [2]int{3, 7}}
+	tests := []struct {
+		line string
+		exp  *games.Action
+	}{
+		{"invite bob 3", &games.Action{ActType: games.ACTIDInvite, ID: 7, BestOf: 3, Moveix: games.SMNone}},
+		{"accept 7", &games.Action{ActType: games.ACTIDInvAccept, ID: 7, Moveix: games.SMNone}},
+		{"move " + moveText(moves[2]), &games.Action{ActType: games.ACTIDMove, Moveix: 2}},
+		{"m 1", &games.Action{ActType: games.ACTIDMove, Moveix: 1}},
+		{"save decline", &games.Action{ActType: games.ACTIDSaveDecline, Moveix: games.SMNone}},
+		{"wchat Bob nice move", &games.Action{ActType: games.ACTIDChat, ID: 7, Mess: "nice move", Moveix: games.SMNone}},
+		{"chat hi there", &games.Action{ActType: games.ACTIDChat, Mess: "hi there", Moveix: games.SMNone}},
+	}
+	for _, test := range tests {
+		act, isExit, err := c.command(test.line)
+		if err != nil || isExit || act == nil {
+			t.Errorf("Command %q failed: %v", test.line, err)
+			continue
+		}
+		if act.ActType != test.exp.ActType || act.ID != test.exp.ID || act.Moveix != test.exp.Moveix ||
+			act.Mess != test.exp.Mess || act.BestOf != test.exp.BestOf {
+			t.Errorf("Command %q expected %v got: %v", test.line, test.exp, act)
+		}
+	}
+	for _, line := range []string{"invite carl", "move z9 f1", "wchat 7", "dance"} {
+		if act, _, err := c.command(line); err == nil || act != nil {
+			t.Errorf("Command %q should fail", line)
+		}
+	}
+	if _, isExit, _ := c.command("exit"); !isExit {
+		t.Error("Exit failed")
+	}
+}
@@ -0,0 +1,415 @@
+//Package client is a client library of the battleline game server.
+//It logs in, decodes the server messages to typed messages for a
+//Handler and has a method for every action.
+//The protocol hello, the binary encoding and the view position deltas
+//is handled by the client, so the handler always receives full
+//view positions.
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-battleline/v2/http/games"
+	"github.com/rezder/go-error/log"
+	"golang.org/x/net/websocket"
+)
+
+//Caps the capabilities the client asks for in the hello.
+var Caps = []string{games.CapChat, games.CapErrors, games.CapDelta, games.CapBinary}
+
+//Handler handles the server messages. The methods is called from the
+//Run goroutine one at a time. Embed NopHandler to only handle some of
+//the messages.
+type Handler interface {
+	//List the players of the lobby by their id.
+	List(list map[string]*games.PubData)
+	//Invite a invite, when rejected the invite was declined or retracted.
+	Invite(invite *games.Invite)
+	//Mess a message from a player or the server.
+	Mess(mess *games.MesData)
+	//Chat a table chat message of the players game or a watched game.
+	Chat(chat *games.TableChat)
+	//Playing the players game.
+	Playing(data *games.PlayingChData)
+	//Watching a watched game.
+	Watching(data *games.WatchingChData)
+	//ClearInvites all invites is cleared.
+	ClearInvites(reason games.ClearInvites)
+	//SavedGames the players saved games.
+	SavedGames(savedGames games.SavedGames)
+	//Error a action failed.
+	Error(errMess *games.ErrMess)
+	//Close the server closes the connection.
+	Close(closeCon *games.CloseCon)
+}
+
+//NopHandler is a handler that ignores all messages.
+type NopHandler struct{}
+
+//List ignores the list.
+func (NopHandler) List(list map[string]*games.PubData) {}
+
+//Invite ignores the invite.
+func (NopHandler) Invite(invite *games.Invite) {}
+
+//Mess ignores the message.
+func (NopHandler) Mess(mess *games.MesData) {}
+
+//Chat ignores the chat message.
+func (NopHandler) Chat(chat *games.TableChat) {}
+
+//Playing ignores the game.
+func (NopHandler) Playing(data *games.PlayingChData) {}
+
+//Watching ignores the watched game.
+func (NopHandler) Watching(data *games.WatchingChData) {}
+
+//ClearInvites ignores the clear.
+func (NopHandler) ClearInvites(reason games.ClearInvites) {}
+
+//SavedGames ignores the saved games.
+func (NopHandler) SavedGames(savedGames games.SavedGames) {}
+
+//Error logs the error.
+func (NopHandler) Error(errMess *games.ErrMess) {
+	log.Printf(log.Min, "Server error on action %v: %v", errMess.ActType, errMess.Message)
+}
+
+//Close ignores the close.
+func (NopHandler) Close(closeCon *games.CloseCon) {}
+
+//Client is a connection to the game server.
+//The action methods may be called from any goroutine.
+type Client struct {
+	ws        *websocket.Conn
+	mu        sync.Mutex
+	codec     websocket.Codec
+	hello     *games.Hello
+	isClosed  bool
+	closeOnce sync.Once
+	streams   map[int]*stream
+}
+
+//stream is the last view position of a game stream used to apply
+//the deltas. Zero is the players own game else the watched player id.
+type stream struct {
+	seq     int64
+	viewPos *bg.ViewPos
+}
+
+//New creates a client on a logged in websocket.
+func New(ws *websocket.Conn) (c *Client) {
+	c = new(Client)
+	c.ws = ws
+	c.codec = websocket.JSON
+	c.streams = make(map[int]*stream)
+	return c
+}
+
+//Hello returns the server hello, nil until it is received.
+func (c *Client) Hello() *games.Hello {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hello
+}
+
+//Run sends the hello and handles the server messages until the server
+//closes the connection, the connection breaks or Close is called.
+//The error is nil when the server or Close closed the connection.
+func (c *Client) Run(handler Handler) (err error) {
+	hello := games.NewAction(games.ACTIDHello)
+	hello.Version = games.ProtocolVERSION
+	hello.Caps = Caps
+	if err = c.Send(hello); err != nil {
+		return err
+	}
+	for {
+		var frame games.WireFrame
+		if err = games.WireCodec.Receive(c.ws, &frame); err != nil {
+			c.mu.Lock()
+			isClosed := c.isClosed
+			c.mu.Unlock()
+			if err == io.EOF || isClosed {
+				return nil
+			}
+			return errors.Wrap(err, "Reading from websocket failed")
+		}
+		var isClose bool
+		if isClose, err = c.handle(&frame, handler); err != nil {
+			return errors.Wrapf(err, "Decoding message type %v failed", frame.JsonType)
+		}
+		if isClose {
+			return nil
+		}
+	}
+}
+
+//Close closes the connection.
+func (c *Client) Close() (err error) {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.isClosed = true
+		c.mu.Unlock()
+		err = c.ws.Close()
+	})
+	return err
+}
+
+//handle decodes a message and calls the handler.
+func (c *Client) handle(frame *games.WireFrame, handler Handler) (isClose bool, err error) {
+	switch frame.JsonType {
+	case games.JTList:
+		var list map[string]*games.PubData
+		if err = json.Unmarshal(frame.Data, &list); err == nil {
+			handler.List(list)
+		}
+	case games.JTInvite:
+		var invite games.Invite
+		if err = json.Unmarshal(frame.Data, &invite); err == nil {
+			handler.Invite(&invite)
+		}
+	case games.JTMess:
+		var mess games.MesData
+		if err = json.Unmarshal(frame.Data, &mess); err == nil {
+			handler.Mess(&mess)
+		}
+	case games.JTChat:
+		var chat games.TableChat
+		if err = json.Unmarshal(frame.Data, &chat); err == nil {
+			handler.Chat(&chat)
+		}
+	case games.JTPlaying, games.JTPlayingDelta:
+		var data games.PlayingChData
+		if err = json.Unmarshal(frame.Data, &data); err != nil {
+			return false, err
+		}
+		if data.Chat != nil {
+			handler.Chat(&games.TableChat{PlayingIDs: data.PlayingIDs, ChatMess: data.Chat})
+			return false, err
+		}
+		var isOk bool
+		data.ViewPos, isOk, err = c.viewPos(0, frame, data.Seq, data.ViewPos, data.Delta)
+		if isOk {
+			handler.Playing(&data)
+		}
+	case games.JTWatching, games.JTWatchingDelta:
+		var data games.WatchingChData
+		if err = json.Unmarshal(frame.Data, &data); err != nil {
+			return false, err
+		}
+		if data.Chat != nil {
+			handler.Chat(&games.TableChat{PlayingIDs: data.PlayingIDs, WatchingID: data.WatchingID, ChatMess: data.Chat})
+			return false, err
+		}
+		var isOk bool
+		data.ViewPos, isOk, err = c.viewPos(data.WatchingID, frame, data.Seq, data.ViewPos, data.Delta)
+		if isOk {
+			handler.Watching(&data)
+		}
+	case games.JTClearInvites:
+		var reason games.ClearInvites
+		if err = json.Unmarshal(frame.Data, &reason); err == nil {
+			handler.ClearInvites(reason)
+		}
+	case games.JTSavedGames:
+		var savedGames games.SavedGames
+		if err = json.Unmarshal(frame.Data, &savedGames); err == nil {
+			handler.SavedGames(savedGames)
+		}
+	case games.JTHello:
+		var hello games.Hello
+		if err = json.Unmarshal(frame.Data, &hello); err == nil {
+			c.setHello(&hello)
+		}
+	case games.JTError:
+		var errMess games.ErrMess
+		if err = json.Unmarshal(frame.Data, &errMess); err == nil {
+			handler.Error(&errMess)
+		}
+	case games.JTCloseCon:
+		var closeCon games.CloseCon
+		if err = json.Unmarshal(frame.Data, &closeCon); err == nil {
+			handler.Close(&closeCon)
+		}
+		isClose = true
+	default:
+		log.Printf(log.DebugMsg, "Ignoring unknown message type %v", frame.JsonType)
+	}
+	return isClose, err
+}
+
+//setHello keeps the hello and switch to the binary encoding if the
+//server accepted it.
+func (c *Client) setHello(hello *games.Hello) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hello = hello
+	log.Printf(log.DebugMsg, "Protocol version: %v capabilities: %v", hello.Version, hello.Caps)
+	for _, capability := range hello.Caps {
+		if capability == games.CapBinary {
+			c.codec = games.WireCodec
+		}
+	}
+}
+
+//viewPos returns the full view position of a playing or watching
+//message. The view position and delta is from the binary frame or the
+//json data. A delta is applied to the last view position of the stream,
+//if the sequence number shows a missing message a resync is requested
+//and isOk is false.
+func (c *Client) viewPos(
+	streamID int,
+	frame *games.WireFrame,
+	seq int64,
+	viewPos *bg.ViewPos,
+	delta *bg.ViewPosDelta,
+) (fullViewPos *bg.ViewPos, isOk bool, err error) {
+	if frame.ViewPos != nil {
+		viewPos = frame.ViewPos
+	}
+	if frame.Delta != nil {
+		delta = frame.Delta
+	}
+	s, isFound := c.streams[streamID]
+	if !isFound {
+		s = new(stream)
+		c.streams[streamID] = s
+	}
+	switch {
+	case frame.JsonType != games.JTPlayingDelta && frame.JsonType != games.JTWatchingDelta:
+		if viewPos == nil {
+			return nil, true, err
+		}
+		fullViewPos = viewPos
+	case s.viewPos == nil || seq != s.seq+1 || delta == nil:
+		log.Printf(log.DebugMsg, "Delta sequence gap %v after %v, requesting resync", seq, s.seq)
+		act := games.NewAction(games.ACTIDResync)
+		act.ID = streamID
+		return nil, false, c.Send(act)
+	default:
+		fullViewPos = s.viewPos.ApplyDelta(delta)
+	}
+	s.viewPos = fullViewPos
+	s.seq = seq
+	return fullViewPos, true, err
+}
+
+//Send sends a action.
+func (c *Client) Send(act *games.Action) (err error) {
+	c.mu.Lock()
+	codec := c.codec
+	c.mu.Unlock()
+	if err = codec.Send(c.ws, act); err != nil {
+		err = errors.Wrapf(err, "Writing action %v to websocket failed", act)
+	}
+	return err
+}
+
+func (c *Client) sendID(actType, id int) error {
+	act := games.NewAction(actType)
+	act.ID = id
+	return c.Send(act)
+}
+
+//Mess sends a message to a player.
+func (c *Client) Mess(id int, txt string) error {
+	act := games.NewAction(games.ACTIDMess)
+	act.ID = id
+	act.Mess = txt
+	return c.Send(act)
+}
+
+//Invite invites a player to a game. A bestOf larger than one invites to
+//a match and watchDelay is the spectator delay in seconds.
+func (c *Client) Invite(id, bestOf, watchDelay int) error {
+	act := games.NewAction(games.ACTIDInvite)
+	act.ID = id
+	act.BestOf = bestOf
+	act.WatchDelay = watchDelay
+	return c.Send(act)
+}
+
+//Resume invites a player to resume the saved game with the player,
+//watchDelay is the spectator delay in seconds.
+func (c *Client) Resume(id, watchDelay int) error {
+	act := games.NewAction(games.ACTIDInvite)
+	act.ID = id
+	act.WatchDelay = watchDelay
+	act.IsResume = true
+	return c.Send(act)
+}
+
+//Accept accepts the invite of a player.
+func (c *Client) Accept(id int) error {
+	return c.sendID(games.ACTIDInvAccept, id)
+}
+
+//Decline declines the invite of a player.
+func (c *Client) Decline(id int) error {
+	return c.sendID(games.ACTIDInvDecline, id)
+}
+
+//Retract retracts the invite to a player.
+func (c *Client) Retract(id int) error {
+	return c.sendID(games.ACTIDInvRetract, id)
+}
+
+//Move makes a move, moveix is the index of the view position moves.
+func (c *Client) Move(moveix int) error {
+	act := games.NewAction(games.ACTIDMove)
+	act.Moveix = moveix
+	return c.Send(act)
+}
+
+//Quit gives up the game.
+func (c *Client) Quit() error {
+	return c.Send(games.NewAction(games.ACTIDQuit))
+}
+
+//Watch starts watching the game of a player.
+func (c *Client) Watch(id int) error {
+	return c.sendID(games.ACTIDWatch, id)
+}
+
+//WatchStop stops watching the game of a player.
+func (c *Client) WatchStop(id int) error {
+	return c.sendID(games.ACTIDWatchStop, id)
+}
+
+//List requests the lobby list.
+func (c *Client) List() error {
+	return c.Send(games.NewAction(games.ACTIDList))
+}
+
+//Save requests the game saved.
+func (c *Client) Save() error {
+	return c.Send(games.NewAction(games.ACTIDSave))
+}
+
+//SaveAccept accepts the opponents save request.
+func (c *Client) SaveAccept() error {
+	return c.Send(games.NewAction(games.ACTIDSaveAccept))
+}
+
+//SaveDecline declines the opponents save request.
+func (c *Client) SaveDecline() error {
+	return c.Send(games.NewAction(games.ACTIDSaveDecline))
+}
+
+//Rematch invites the last opponent to a new game.
+func (c *Client) Rematch() error {
+	return c.Send(games.NewAction(games.ACTIDRematch))
+}
+
+//Chat sends a chat message to a table, id zero is the players own game
+//else the watched game of the player id.
+func (c *Client) Chat(id int, txt string) error {
+	act := games.NewAction(games.ACTIDChat)
+	act.ID = id
+	act.Mess = txt
+	return c.Send(act)
+}
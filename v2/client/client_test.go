@@ -0,0 +1,143 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+	"github.com/rezder/go-battleline/v2/http/games"
+	"golang.org/x/net/websocket"
+)
+
+type testHandler struct {
+	NopHandler
+	c        *Client
+	lists    int
+	chats    []*games.TableChat
+	playing  []*games.PlayingChData
+	watching []*games.WatchingChData
+	errs     []*games.ErrMess
+	closeCon *games.CloseCon
+}
+
+func (h *testHandler) List(list map[string]*games.PubData) { h.lists++ }
+func (h *testHandler) Chat(chat *games.TableChat)          { h.chats = append(h.chats, chat) }
+func (h *testHandler) Error(errMess *games.ErrMess)        { h.errs = append(h.errs, errMess) }
+func (h *testHandler) Close(closeCon *games.CloseCon)      { h.closeCon = closeCon }
+func (h *testHandler) Watching(data *games.WatchingChData) {
+	h.watching = append(h.watching, data)
+}
+func (h *testHandler) Playing(data *games.PlayingChData) {
+	h.playing = append(h.playing, data)
+	if err := h.c.Move(len(h.playing)); err != nil {
+		panic(err)
+	}
+}
+
+//testServer serves the messages on a websocket and returns the actions
+//received.
+func testServer(t *testing.T, messages []*games.JsonData) (ws *websocket.Conn, actCh <-chan []*games.Action) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultCh := make(chan []*games.Action, 1)
+	handler := websocket.Handler(func(ws *websocket.Conn) {
+		var acts []*games.Action
+		defer func() { resultCh <- acts }()
+		for _, jdata := range messages {
+			if jdata.JsonType == games.JTHello {
+				var hello games.Action
+				if err := games.WireCodec.Receive(ws, &hello); err != nil {
+					return
+				}
+				acts = append(acts, &hello)
+			}
+			if err := games.WireCodec.Send(ws, jdata); err != nil {
+				return
+			}
+		}
+		for {
+			var act games.Action
+			if err := games.WireCodec.Receive(ws, &act); err != nil {
+				return
+			}
+			acts = append(acts, &act)
+		}
+	})
+	server := &http.Server{Handler: handler}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(func() { _ = server.Close() })
+	addr := listener.Addr().String()
+	ws, err = websocket.Dial("ws://"+addr+"/", "", "http://"+addr+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws, resultCh
+}
+
+func TestClient(t *testing.T) {
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	viewPoss := make([]*bg.ViewPos, 0, 4)
+	for i := 0; i < 4; i++ {
+		viewPoss = append(viewPoss, bg.NewViewPos(game.Pos, bg.ViewAll.Players[0], dpos.NoPlayer))
+		game.Move(game.Pos.CalcMoves()[0])
+	}
+	gameTs := time.Now()
+	chat := &bg.ChatMess{SenderID: 2, SenderName: "Bob", Message: "Hi"}
+	messages := []*games.JsonData{
+		{JsonType: games.JTHello, Data: &games.Hello{Version: games.ProtocolVERSION, Caps: []string{games.CapDelta, games.CapBinary}}},
+		{JsonType: games.JTList, Data: map[string]*games.PubData{"2": {ID: 2, Name: "Bob"}}},
+		{JsonType: games.JTPlaying, Data: &games.PlayingChData{ViewPos: viewPoss[0], GameTs: gameTs, Seq: 1}},
+		{JsonType: games.JTPlayingDelta, Data: &games.PlayingChData{Delta: bg.NewViewPosDelta(viewPoss[0], viewPoss[1]), GameTs: gameTs, Seq: 2}},
+		{JsonType: games.JTPlayingDelta, Data: &games.PlayingChData{Delta: bg.NewViewPosDelta(viewPoss[2], viewPoss[3]), GameTs: gameTs, Seq: 4}},
+		{JsonType: games.JTPlaying, Data: &games.PlayingChData{PlayingIDs: [2]int{1, 2}, Chat: chat}},
+		{JsonType: games.JTWatching, Data: &games.WatchingChData{ViewPos: viewPoss[0], WatchingID: 5, Seq: 1}},
+		{JsonType: games.JTWatchingDelta, Data: &games.WatchingChData{Delta: bg.NewViewPosDelta(viewPoss[0], viewPoss[2]), WatchingID: 5, Seq: 2}},
+		{JsonType: games.JTError, Data: &games.ErrMess{ActType: games.ACTIDMove, Message: "Illegal move"}},
+		{JsonType: 99, Data: "Unknown"},
+		{JsonType: games.JTCloseCon, Data: &games.CloseCon{Reason: "Done"}},
+	}
+	ws, actCh := testServer(t, messages)
+	c := New(ws)
+	h := &testHandler{c: c}
+	if err := c.Run(h); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if hello := c.Hello(); hello == nil || len(hello.Caps) != 2 {
+		t.Errorf("Hello missing: %v", hello)
+	}
+	if h.lists != 1 || len(h.errs) != 1 || h.closeCon == nil || h.closeCon.Reason != "Done" {
+		t.Errorf("Messages missing list: %v errors: %v close: %v", h.lists, h.errs, h.closeCon)
+	}
+	if len(h.playing) != 2 || !h.playing[1].ViewPos.IsEqual(viewPoss[1]) {
+		t.Errorf("Expected the snapshot and the delta applied got: %v", h.playing)
+	}
+	if len(h.watching) != 2 || h.watching[1].WatchingID != 5 || !h.watching[1].ViewPos.IsEqual(viewPoss[2]) {
+		t.Errorf("Expected the watched snapshot and the delta applied got: %v", h.watching)
+	}
+	if len(h.chats) != 1 || h.chats[0].Message != "Hi" || h.chats[0].PlayingIDs[1] != 2 {
+		t.Errorf("Chat in playing data should be a table chat got: %v", h.chats)
+	}
+	_ = c.Close()
+	acts := <-actCh
+	expTypes := []int{games.ACTIDHello, games.ACTIDMove, games.ACTIDMove, games.ACTIDResync}
+	if len(acts) != len(expTypes) {
+		t.Fatalf("Expected %v actions got: %v", len(expTypes), acts)
+	}
+	for i, act := range acts {
+		if act.ActType != expTypes[i] {
+			t.Errorf("Action %v expected type %v got: %v", i, expTypes[i], act)
+		}
+	}
+	if acts[0].Version != games.ProtocolVERSION || len(acts[0].Caps) != len(Caps) {
+		t.Errorf("Unexpected hello: %v", acts[0])
+	}
+	if acts[2].Moveix != 2 {
+		t.Errorf("Expected move 2 got: %v", acts[2])
+	}
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	lg "github.com/rezder/go-battleline/v2/http/login"
+	"golang.org/x/net/websocket"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+)
+
+//Dial logs in to the game server with the api token of a bot account
+//and opens the game websocket.
+//caFile is a pem CA bundle used to verify the server certificate
+//when the scheme is https, if empty the system CAs is used.
+func Dial(scheme, gameURL, name, token, caFile string) (c *Client, err error) {
+	form := url.Values{"txtUserName": {name}, "txtToken": {token}}
+	ws, err := dial(scheme, gameURL, "/post/botlogin", form, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return New(ws), err
+}
+
+//DialPassword logs in to the game server with the password of a player
+//and opens the game websocket.
+func DialPassword(scheme, gameURL, name, password, caFile string) (c *Client, err error) {
+	form := url.Values{"txtUserName": {name}, "pwdPassword": {password}}
+	ws, err := dial(scheme, gameURL, "/post/login", form, caFile)
+	if err != nil {
+		return nil, err
+	}
+	return New(ws), err
+}
+
+func dial(scheme, gameURL, loginPath string, form url.Values, caFile string) (ws *websocket.Conn, err error) {
+	tlsConfig, err := loadTLSConfig(caFile)
+	if err != nil {
+		return nil, err
+	}
+	cookies, err := login(scheme, gameURL, loginPath, form, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return createWs(scheme, gameURL, cookies, tlsConfig)
+}
+
+//login posts the login form to the login path of the game server.
+func login(scheme, gameURL, loginPath string, form url.Values, tlsConfig *tls.Config) (cookies []*http.Cookie, err error) {
+	client := new(http.Client)
+	if tlsConfig != nil {
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return cookies, err
+	}
+	client.Jar = jar
+	resp, err := client.PostForm(scheme+"://"+gameURL+loginPath, form)
+	if err != nil {
+		return cookies, err
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	decoder := json.NewDecoder(resp.Body)
+	var tmp struct{ LogInStatus lg.Status }
+	err = decoder.Decode(&tmp)
+	if err != nil {
+		err = errors.Wrap(err, "Decoding of login status failed.")
+		return cookies, err
+	}
+	loginStatus := tmp.LogInStatus
+	if !loginStatus.IsOk() {
+		err = fmt.Errorf("Login failed: %v", loginStatus)
+		return cookies, err
+	}
+
+	cookiesURL, err := url.Parse(scheme + "://" + gameURL + "/in/gamews")
+	if err != nil {
+		return cookies, err
+	}
+	cookies = client.Jar.Cookies(cookiesURL) //Strips the path from cookie proberly not a problem
+	okCookies := false
+	if len(cookies) != 0 {
+		for _, cookie := range cookies {
+			if cookie.Name == "sid" {
+				okCookies = true
+				break
+			}
+		}
+	}
+	if !okCookies {
+		err = errors.New("Invalid cookies")
+		return cookies, err
+	}
+	return cookies, err
+}
+
+//createWs creates the websocket connection.
+func createWs(scheme string, addrPort string, cookies []*http.Cookie, tlsConfig *tls.Config) (conn *websocket.Conn,
+	err error) {
+	wsScheme := "ws://"
+	if scheme == "https" {
+		wsScheme = "wss://"
+	}
+	//Second argument is the orgin of the javascript that create the websocket.
+	//The server handshake only accept its own host as origin.
+	config, err := websocket.NewConfig(wsScheme+addrPort+"/in/gamews", scheme+"://"+addrPort+"/")
+	if err != nil {
+		return conn, err
+	}
+	config.TlsConfig = tlsConfig
+	addCookies(config, cookies)
+	return websocket.DialConfig(config)
+
+}
+
+//loadTLSConfig loads the CA bundle, nil is returned if
+//no CA file is given.
+func loadTLSConfig(caFile string) (config *tls.Config, err error) {
+	if len(caFile) == 0 {
+		return config, err
+	}
+	pemBs, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return config, errors.Wrapf(err, "Reading CA file %v failed", caFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBs) {
+		return config, errors.Errorf("No certificates found in CA file %v", caFile)
+	}
+	config = &tls.Config{RootCAs: pool}
+	return config, err
+}
+
+//addCookies adds cookies to the websocket header.
+func addCookies(config *websocket.Config, cookies []*http.Cookie) {
+	if len(cookies) != 0 {
+		value := ""
+		for _, cookie := range cookies {
+			ctxt := fmt.Sprintf("%s=%s", cookie.Name, cookie.Value)
+			if len(value) == 0 {
+				value = ctxt
+			} else {
+				value = value + "; " + ctxt
+			}
+
+		}
+		config.Header.Set("Cookie", value)
+	}
+}
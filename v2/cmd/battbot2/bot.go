@@ -26,24 +26,36 @@ func main() {
 	var tfServerFile string
 	var tfModelDir string
 	var scheme string // http or https
+	var token string
 	var pw string
+	var caFile string
 	var logLevel int
 	var limitNoGame int
+	var maxReconnects int
 	var isSendInvite bool
+	var policyFile string
 	flag.StringVar(&scheme, "scheme", "http", "Scheme http or https")
 	flag.StringVar(&gameURL, "gameurl", "game.rezder.com:8282", "The server url example: game.rezder.com:8181")
 	flag.StringVar(&tfURL, "tfurl", "", "The tensorflow server url example: localhost:5555")
 	flag.StringVar(&tfServerFile, "tfserver", "", "The python server file ex.: /home/rho/Python/tensorflow/battleline/botserver.py")
 	flag.StringVar(&tfModelDir, "tfmodeldir", "", "The tensorflow model dir ex.: /home/rho/Python/tensorflow/battleline/model1")
 	flag.StringVar(&name, "name", "Rene", "User name")
-	flag.StringVar(&pw, "pw", "12345678", "User password")
+	flag.StringVar(&caFile, "cafile", "", "The CA bundle pem file used to verify the server certificate with https, default the system CAs")
+	flag.StringVar(&token, "token", "", "The bot api token, created with battclients -role=bot")
+	flag.StringVar(&pw, "pw", "", "Deprecated use token, the password of a account that is not a bot account")
 	flag.IntVar(&logLevel, "loglevel", 0, "Log level 0 default lowest, 3 highest")
 	flag.BoolVar(&isSendInvite, "send", false, "If true send invites else accept invite")
 	flag.IntVar(&limitNoGame, "limit", 0, "When the number of game played reach the limit the bot closes down")
+	flag.StringVar(&policyFile, "policy", "", "The json invite policy file, if empty all invites is accepted or the first ready player is invited")
+	flag.IntVar(&maxReconnects, "reconnects", 10, "The number of failed reconnects in a row before the bot gives up, 0 disables reconnect")
 	flag.Parse()
 
 	log.InitLog(logLevel)
-	inviteHandler := bot.NewStdInviteHandler(isSendInvite)
+	inviteHandler, err := newInviteHandler(policyFile, isSendInvite)
+	if err != nil {
+		log.PrintErr(err)
+		return
+	}
 	if len(tfServerFile) > 0 && len(tfModelDir) > 0 && len(tfURL) == 0 {
 		log.Print(log.Min, "-tfurl was not included as option, it must as tfserver and tfmodeldir start as tensorflow server")
 		return
@@ -68,11 +80,18 @@ func main() {
 			log.PrintErr(cerr)
 		}
 	}()
-	battBot, err := bot.New(scheme, gameURL, name, pw, limitNoGame, inviteHandler, battMover)
+	var battBot *bot.Bot
+	if len(token) == 0 && len(pw) > 0 {
+		log.Print(log.Min, "Flag -pw is deprecated, create a bot account with battclients -role=bot and use its token with -token")
+		battBot, err = bot.NewPassword(scheme, gameURL, name, pw, caFile, limitNoGame, inviteHandler, battMover)
+	} else {
+		battBot, err = bot.New(scheme, gameURL, name, token, caFile, limitNoGame, inviteHandler, battMover)
+	}
 	if err != nil {
 		log.PrintErr(err)
 		return
 	}
+	battBot.MaxReconnects = maxReconnects
 	battBot.Start()
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
@@ -87,6 +106,20 @@ func main() {
 	}
 }
 
+//newInviteHandler creates the invite handler of the policy file, -send
+//makes the bot send invites with any policy.
+func newInviteHandler(policyFile string, isSendInvite bool) (bot.InviteHandler, error) {
+	if len(policyFile) == 0 {
+		return bot.NewStdInviteHandler(isSendInvite), nil
+	}
+	policy, err := bot.LoadPolicy(policyFile)
+	if err != nil {
+		return nil, err
+	}
+	policy.IsInviter = policy.IsInviter || isSendInvite
+	return bot.NewPolicyInviteHandler(policy)
+}
+
 type mover struct {
 	tfCon *tf.Con
 }
@@ -163,7 +196,7 @@ func cmdStart(serverFile, modelDir, tfURLTxt string) (cmd *exec.Cmd, err error)
 		}
 		log.Printf(log.DebugMsg, "Start python server: %v dir: %v", serverFile, modelDir)
 		cmd = exec.Command("python", serverFile, "--model_dir="+modelDir, "--port="+port)
-		log.Printf(log.Debug, "The command", cmd)
+		log.Printf(log.Debug, "The command: %v", cmd)
 		err = cmd.Start()
 		if err != nil {
 			return nil, err
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/rezder/go-battleline/v2/cli"
+	"github.com/rezder/go-battleline/v2/client"
+	"github.com/rezder/go-error/log"
+	"golang.org/x/term"
+	"os"
+	"strings"
+)
+
+func main() {
+	var gameURL string
+	var name string
+	var scheme string // http or https
+	var token string
+	var caFile string
+	var logLevel int
+	flag.StringVar(&scheme, "scheme", "http", "Scheme http or https")
+	flag.StringVar(&gameURL, "gameurl", "game.rezder.com:8282", "The server url example: game.rezder.com:8181")
+	flag.StringVar(&name, "name", "", "User name")
+	flag.StringVar(&caFile, "cafile", "", "The CA bundle pem file used to verify the server certificate with https, default the system CAs")
+	flag.StringVar(&token, "token", "", "The api token of a bot account, if empty the password is read from BATTCLI_PASSWORD or the terminal")
+	flag.IntVar(&logLevel, "loglevel", 0, "Log level 0 default lowest, 3 highest")
+	flag.Parse()
+
+	log.InitLog(logLevel)
+	if len(name) == 0 {
+		log.Print(log.Min, "-name is missing")
+		return
+	}
+	in := bufio.NewReader(os.Stdin)
+	var conn *client.Client
+	var err error
+	if len(token) != 0 {
+		conn, err = client.Dial(scheme, gameURL, name, token, caFile)
+	} else {
+		password := os.Getenv("BATTCLI_PASSWORD")
+		if len(password) == 0 {
+			password, err = readPassword(in)
+		}
+		if err == nil {
+			conn, err = client.DialPassword(scheme, gameURL, name, password, caFile)
+		}
+	}
+	if err != nil {
+		log.PrintErr(err)
+		return
+	}
+	log.Printf(log.Min, "Logged in as %v\n", name)
+	if err = cli.New(conn, name, os.Stdout).Run(in); err != nil {
+		log.PrintErr(err)
+	}
+}
+
+//readPassword reads the password from the terminal without echo, if
+//stdin is not a terminal the password is read as a line.
+func readPassword(in *bufio.Reader) (password string, err error) {
+	fmt.Print("Password: ")
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		var pw []byte
+		pw, err = term.ReadPassword(fd)
+		fmt.Println()
+		return string(pw), err
+	}
+	password, err = in.ReadString('\n')
+	password = strings.TrimRight(password, "\r\n")
+	return password, err
+}
@@ -10,13 +10,27 @@ import (
 
 func main() {
 	var dbfile string
+	var roleTxt string
+	var isNewToken bool
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [-dbfile] name password\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-dbfile] [-role] name password\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [-dbfile] [-newtoken] -role=bot name\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 	flag.StringVar(&dbfile, "dbfile", "clients.db", "The clients database file")
+	flag.StringVar(&roleTxt, "role", "player", "The client role player, bot or admin, the role of a existing client is updated")
+	flag.BoolVar(&isNewToken, "newtoken", false, "Create a new api token for a existing bot")
 	flag.Parse()
-	if flag.NArg() != 2 {
+	role, err := http.ParseRole(roleTxt)
+	if err != nil {
+		log.PrintErr(err)
+		return
+	}
+	if role.IsBot() && flag.NArg() != 1 {
+		log.Print(log.Min, " One argument is need name")
+		return
+	}
+	if !role.IsBot() && flag.NArg() != 2 {
 		log.Print(log.Min, " Two arguments is need name and password")
 		return
 	}
@@ -31,19 +45,47 @@ func main() {
 			log.PrintErr(err)
 		}
 	}()
-	client, err := http.NewClient(flag.Arg(0), flag.Arg(1))
+	var client *http.Client
+	var token string
+	if role.IsBot() {
+		client, token, err = http.NewBotClient(flag.Arg(0))
+	} else {
+		client, err = http.NewClient(flag.Arg(0), flag.Arg(1))
+	}
 	if err != nil {
 		log.PrintErr(err)
 		return
 	}
-	client, isUpd, err := cdb.UpdInsert(client)
+	client.Role = role
+	_, isUpd, err := cdb.UpdInsert(client)
 	if err != nil {
 		log.PrintErr(err)
 		return
 	}
 	if isUpd {
 		log.Print(log.Min, "Client added")
+		if role.IsBot() {
+			fmt.Println(token)
+		}
+		return
+	}
+	isUpd, err = cdb.UpdRole(client.Name, role)
+	if err != nil {
+		log.PrintErr(err)
+		return
+	}
+	if isUpd {
+		log.Printf(log.Min, "Client allready exist, role updated to %v", role)
 	} else {
 		log.Print(log.Min, "Client was not added as client allready exist")
 	}
+	if role.IsBot() && (isNewToken || isUpd) {
+		err = cdb.UpdToken(client.Name, client.Token)
+		if err != nil {
+			log.PrintErr(err)
+			return
+		}
+		log.Print(log.Min, "New bot token")
+		fmt.Println(token)
+	}
 }
@@ -3,40 +3,67 @@ package main
 
 import (
 	"flag"
+	"github.com/pkg/errors"
 	//"github.com/pkg/profile"
 	"github.com/rezder/go-battleline/v2/http"
+	"github.com/rezder/go-battleline/v2/http/config"
 	"github.com/rezder/go-error/log"
 	"math/rand"
 	"os"
 	"os/signal"
-	"strconv"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 )
 
 // Start the battleline server.
-// The server needs the html/pages and the html/static files
-// and will create a directory data with two gob files.
+// The server needs the html/pages and the html/static files in the root
+// directory and will create the data directory with the databases.
+// The configuration is loaded from the config file and the flags
+// overrides the file.
 func main() {
 	rand.Seed(time.Now().UnixNano())
-	portFlag := flag.Int("port", 8282, "tcp port")
-	bckupPortFlag := flag.String("bckupport", "", "Backup port for backing up unfinished games and clientes, not filled no backup server")
-	archPokePortFlag := flag.Int("archpokeport", 7373, "Arciver poke tcp port, the archivers poke this port when ready")
-	archAddrFlag := flag.String("archaddr", "", "Archiver address, if the archiver is allready running and ready")
 	logFlag := flag.Int("loglevel", 0, "Log level 0 default lowest, 3 highest")
-	//TODO change rootDirFlag default to ./server/htmlroot
-	rootDirFlag := flag.String("rootdir", "/home/rho/js/batt-game-app/build/", "The server files root directory")
+	selfSignedFlag := flag.Bool("selfsigned", false, "Generate a self-signed certificate for local development if the certificate file does not exist, set both certfile and keyfile or none for cert.pem and key.pem in the data directory")
+	certHostsFlag := flag.String("certhosts", "", "Comma separated host names and ips the self-signed certificate is valid for besides localhost and 127.0.0.1")
 	//TODO make backup server to databases
-	flag.Parse()
+	cfg, err := config.ParseFlags(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.PrintErr(err)
+		return
+	}
 	log.InitLog(*logFlag)
 	//defer profile.Start(profile.MemProfile, profile.NoShutdownHook).Stop()
-	var port string
-	if *portFlag == 80 { //Add https port if use https
-		port = ""
-	} else {
-		port = ":" + strconv.Itoa(*portFlag)
+	if *selfSignedFlag {
+		if len(cfg.CertFile) == 0 && len(cfg.KeyFile) == 0 {
+			cfg.CertFile = cfg.DataFile("cert.pem")
+			cfg.KeyFile = cfg.DataFile("key.pem")
+		} else if len(cfg.CertFile) == 0 || len(cfg.KeyFile) == 0 {
+			log.PrintErr(errors.New("Flag selfsigned requires both certfile and keyfile or none of them"))
+			return
+		}
+		if _, err := os.Stat(cfg.CertFile); os.IsNotExist(err) {
+			for _, file := range []string{cfg.CertFile, cfg.KeyFile} {
+				err = os.MkdirAll(filepath.Dir(file), 0700)
+				if err != nil {
+					log.PrintErr(err)
+					return
+				}
+			}
+			hosts := []string{"localhost", "127.0.0.1"}
+			if len(*certHostsFlag) > 0 {
+				hosts = append(hosts, strings.Split(*certHostsFlag, ",")...)
+			}
+			err = http.GenerateCert(cfg.CertFile, cfg.KeyFile, hosts)
+			if err != nil {
+				log.PrintErr(err)
+				return
+			}
+			log.Printf(log.Min, "Self-signed certificate created: %v", cfg.CertFile)
+		}
 	}
-	httpServer, err := http.New(port, *bckupPortFlag, *archPokePortFlag, *archAddrFlag, *rootDirFlag)
+	httpServer, err := http.New(cfg)
 	if err != nil {
 		log.PrintErr(err)
 		return
@@ -45,7 +72,11 @@ func main() {
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	log.Print(log.Min, "Server up and running. Close with ctrl+c")
-	<-stop
-	log.Print(log.Verbose, "Server closed with interrupt signal")
+	select {
+	case <-stop:
+		log.Print(log.Verbose, "Server closed with interrupt signal")
+	case <-httpServer.DrainedCh():
+		log.Print(log.Verbose, "Server closed after drain")
+	}
 	httpServer.Stop()
 }
@@ -1,19 +1,14 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/pkg/errors"
 	"github.com/rezder/go-battleline/v2/db/dbhist"
 	"github.com/rezder/go-battleline/v2/game"
-	lg "github.com/rezder/go-battleline/v2/http/login"
+	bhttp "github.com/rezder/go-battleline/v2/http"
 	"github.com/rezder/go-error/log"
-	"golang.org/x/net/websocket"
-	"net/http"
-	"net/http/cookiejar"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -23,9 +18,10 @@ import (
 )
 
 func main() {
-	rootDirFlag := flag.String("rootdir", "/home/rho/js/batt-game-app/build/", "The http server files root directory")
+	rootDirFlag := flag.String("rootdir", "", "The http server files root directory, not set the server default")
 	noFlag := flag.Int("no", 1000, "The numbers of games to play")
 	keepDbFlag := flag.Bool("keepdb", false, "Keep the game database")
+	tlsFlag := flag.Bool("tls", false, "Run the test over https with a self-signed certificate")
 	//tfAddrFlag := flag.String("tfaddr", "localhost:5555", "The tensorflow move server") TODO
 	log.InitLog(log.Debug)
 	flag.Parse()
@@ -47,11 +43,33 @@ func main() {
 		log.PrintErr(err)
 		return
 	}
+	tokens, err := createBots(filepath.Join(dirDataPath, "clients.db"), "Rene", "Peter")
+	if err != nil {
+		err = errors.Wrap(err, "Create bots failed")
+		log.PrintErr(err)
+		return
+	}
+	serverArgs := []string{"-datadir=" + dirDataPath, "-archaddr=localhost:7272"}
+	if len(*rootDirFlag) > 0 {
+		serverArgs = append(serverArgs, "-rootdir="+*rootDirFlag)
+	}
+	var botArgs []string
+	if *tlsFlag {
+		certFile := filepath.Join(dirDataPath, "cert.pem")
+		keyFile := filepath.Join(dirDataPath, "key.pem")
+		err = bhttp.GenerateCert(certFile, keyFile, []string{"localhost", "127.0.0.1"})
+		if err != nil {
+			log.PrintErr(err)
+			return
+		}
+		serverArgs = append(serverArgs, "-certfile="+certFile, "-keyfile="+keyFile)
+		botArgs = append(botArgs, "-scheme=https", "-cafile="+certFile)
+	}
 	runCmds := make([]*exec.Cmd, 0, 4)
 	archCmd := exec.Command("battarchiver2", "-dbfile="+bdbFileName, "-loglevel=3")
 	archCmd.Stderr = os.Stderr
 	archCmd.Stdout = os.Stdout
-	err := archCmd.Start()
+	err = archCmd.Start()
 	if err != nil {
 		err = errors.Wrap(err, "Batt Archiver2 cmd failed")
 		log.PrintErr(err)
@@ -60,7 +78,7 @@ func main() {
 	}
 	runCmds = append(runCmds, archCmd)
 	time.Sleep(time.Second * 1)
-	httpCmd := exec.Command("battserver2", "-rootdir="+*rootDirFlag, "-archaddr=localhost:7272")
+	httpCmd := exec.Command("battserver2", serverArgs...)
 	httpCmd.Stderr = os.Stderr
 	httpCmd.Stdout = os.Stdout
 	err = httpCmd.Start()
@@ -72,15 +90,7 @@ func main() {
 	runCmds = append(runCmds, httpCmd)
 	time.Sleep(time.Second * 1)
 
-	err = createUsrs()
-	if err != nil {
-		err = errors.Wrap(err, "Create users failed")
-		log.PrintErr(err)
-		intCmds(runCmds)
-		return
-	}
-	time.Sleep(time.Millisecond * 500)
-	botCmd := exec.Command("battbot2", "-name=Peter")
+	botCmd := exec.Command("battbot2", append(botArgs, "-name=Peter", "-token="+tokens[1])...)
 	err = botCmd.Start()
 	if err != nil {
 		err = errors.Wrap(err, "Batt Bot2 cmd failed")
@@ -90,7 +100,7 @@ func main() {
 	}
 	runCmds = append(runCmds, botCmd)
 	time.Sleep(time.Second * 1)
-	botSendCmd := exec.Command("battbot2", "-send", "-limit="+strconv.Itoa(*noFlag))
+	botSendCmd := exec.Command("battbot2", append(botArgs, "-send", "-name=Rene", "-token="+tokens[0], "-limit="+strconv.Itoa(*noFlag))...)
 	st := time.Now()
 	log.Printf(log.Min, "Start playing %v games: %v", *noFlag, st.Format(time.Stamp))
 	err = botSendCmd.Run()
@@ -176,83 +186,30 @@ func intCmd(cmd *exec.Cmd) {
 	}
 	return
 }
-func createUsrs() (err error) {
-	gameURL := "localhost:8282"
-	err = createUsr("Rene", "12345678", gameURL)
-	if err != nil {
-		return err
-	}
-	err = createUsr("Peter", "12345678", gameURL)
-	return err
-}
-func createUsr(name, pw, gameURL string) (err error) {
-	client := new(http.Client)
-	jar, err := cookiejar.New(nil)
+
+//createBots creates the bot accounts in the clients database and
+//returns their api tokens.
+func createBots(dbFile string, names ...string) (tokens []string, err error) {
+	cdb, err := bhttp.NewCdb(dbFile)
 	if err != nil {
-		return err
-	}
-	client.Jar = jar
-	resp, err := client.PostForm("http://"+gameURL+"/post/client",
-		url.Values{"txtUserName": {name}, "pwdPassword": {pw}})
-	if err != nil {
-		return err
+		return tokens, err
 	}
 	defer func() {
-		if cerr := resp.Body.Close(); cerr != nil && err == nil {
+		if cerr := cdb.Close(); cerr != nil && err == nil {
 			err = cerr
 		}
 	}()
-	decoder := json.NewDecoder(resp.Body)
-	var tmp struct{ LogInStatus lg.Status }
-	err = decoder.Decode(&tmp)
-	if err != nil {
-		err = errors.Wrap(err, "Decoding of login status failed.")
-		return err
-	}
-	loginStatus := tmp.LogInStatus
-	if !loginStatus.IsOk() {
-		err = fmt.Errorf("Login failed: %v", loginStatus)
-		return err
-	}
-
-	cookiesURL, err := url.Parse("http://" + gameURL + "/in/gamews")
-	if err != nil {
-		return err
-	}
-	cookies := client.Jar.Cookies(cookiesURL) //Strips the path from cookie proberly not a problem
-	okCookies := false
-	if len(cookies) != 0 {
-		for _, cookie := range cookies {
-			if cookie.Name == "sid" {
-				okCookies = true
-				break
-			}
+	tokens = make([]string, len(names))
+	for i, name := range names {
+		var client *bhttp.Client
+		client, tokens[i], err = bhttp.NewBotClient(name)
+		if err != nil {
+			return tokens, err
 		}
-	}
-	if !okCookies {
-		err = errors.New("Invalid cookies")
-		return err
-	}
-	wsScheme := "ws://"
-	config, err := websocket.NewConfig(wsScheme+"localhost:8282/in/gamews", "http://localhost/")
-	value := ""
-	for _, cookie := range cookies {
-		ctxt := fmt.Sprintf("%s=%s", cookie.Name, cookie.Value)
-		if len(value) == 0 {
-			value = ctxt
-		} else {
-			value = value + "; " + ctxt
+		_, _, err = cdb.UpdInsert(client)
+		if err != nil {
+			return tokens, err
 		}
-
 	}
-	config.Header.Set("Cookie", value)
-	if err != nil {
-		return err
-	}
-	ws, err := websocket.DialConfig(config)
-	if err != nil {
-		return err
-	}
-	err = ws.Close()
-	return err
+	return tokens, err
 }
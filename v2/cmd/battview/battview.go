@@ -12,13 +12,15 @@ import (
 func main() {
 	logLevelFlag := flag.Int("loglevel", 3, "Log level 0 default lowest, 3 highest")
 	portFlag := flag.Int("port", 9021, "The http server port")
+	rootDirFlag := flag.String("rootdir", "htmlroot", "The http server files root directory")
+	tfServerFlag := flag.String("tfserver", "botserver.py", "The python tensorflow model server file")
 
 	flag.Parse()
 	log.InitLog(*logLevelFlag)
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	log.Print(log.Min, "Server running. Close with ctrl+c\n")
-	server, err := viewer.New(*portFlag)
+	server, err := viewer.New(*portFlag, *rootDirFlag, *tfServerFlag)
 	if err != nil {
 		log.PrintErr(err)
 		return
@@ -278,6 +278,21 @@ func (bdb *Db) Search(
 	return hists, nextKey, err
 }
 
+//Keys returns all the keys of the database, the histories is not
+//decoded.
+func (bdb *Db) Keys() (keys [][]byte, err error) {
+	err = bdb.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bdb.bucketID)
+		return b.ForEach(func(k, _ []byte) error {
+			key := make([]byte, len(k))
+			copy(key, k)
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	return keys, err
+}
+
 // itob returns an 8-byte big endian representation of v.
 func itob(v int) []byte {
 	b := make([]byte, 8)
@@ -285,6 +300,13 @@ func itob(v int) []byte {
 	return b
 }
 
+//FilterMatch returns a search filter that selects the games of a match.
+func FilterMatch(matchID int64) func(*game.Hist) bool {
+	return func(hist *game.Hist) bool {
+		return hist.MatchID == matchID
+	}
+}
+
 //KeyPlayerIDs define a key as player ids where the smalles player
 //id comes first.
 func KeyPlayerIDs(ids [2]int) (key []byte) {
@@ -322,6 +344,15 @@ func KeyPlayers(hist *game.Hist) (b []byte) {
 	return KeyPlayerIDs(hist.PlayerIDs)
 }
 
+//Size returns the size of the database.
+func (bdb *Db) Size() (size int64, err error) {
+	err = bdb.db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	})
+	return size, err
+}
+
 //BackupHandleFunc handles http back up requests.
 func (bdb *Db) BackupHandleFunc(w http.ResponseWriter, req *http.Request) {
 	err := bdb.db.View(func(tx *bolt.Tx) error {
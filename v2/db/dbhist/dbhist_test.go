@@ -23,6 +23,16 @@ func TestUpdate(t *testing.T) {
 	testPutGet(fileName, KeyPlayers, t)
 }
 
+func TestFilterMatch(t *testing.T) {
+	filter := FilterMatch(7)
+	if !filter(&game.Hist{MatchID: 7}) {
+		t.Error("Filter should select the games of the match")
+	}
+	if filter(&game.Hist{MatchID: 8}) || filter(&game.Hist{}) {
+		t.Error("Filter should not select games of other matches")
+	}
+}
+
 func TestSearch(t *testing.T) {
 	name := "_test/testdb.db"
 	//err := createTestDb(name) //adds 30 game histories
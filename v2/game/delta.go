@@ -0,0 +1,80 @@
+package game
+
+import (
+	"github.com/rezder/go-battleline/v2/game/card"
+	"github.com/rezder/go-battleline/v2/game/pos"
+)
+
+// ViewPosDelta is the changes between two view positions of the same view.
+// Pieces is the board pieces that changed position, Moves is
+// the new legal moves.
+type ViewPosDelta struct {
+	Pieces         []*BoardPieceMove
+	PlayerReturned int
+	CardsReturned  [2]card.Card
+	LastMoveType   MoveType
+	LastMover      int
+	LastMoveIx     int
+	Winner         int
+	NoTacs         [2]int
+	NoTroops       [2]int
+	Moves          []*Move
+}
+
+// NewViewPosDelta creates the delta from the old to the new view position.
+func NewViewPosDelta(old, v *ViewPos) (d *ViewPosDelta) {
+	d = new(ViewPosDelta)
+	d.PlayerReturned = v.PlayerReturned
+	d.CardsReturned = v.CardsReturned
+	d.LastMoveType = v.LastMoveType
+	d.LastMover = v.LastMover
+	d.LastMoveIx = v.LastMoveIx
+	d.Winner = v.Winner
+	d.NoTacs = v.NoTacs
+	d.NoTroops = v.NoTroops
+	d.Moves = v.Moves
+	for cardix, cardPos := range v.CardPos {
+		if cardPos != old.CardPos[cardix] {
+			d.Pieces = append(d.Pieces, &BoardPieceMove{
+				Index:      cardix,
+				NewPos:     uint8(cardPos),
+				OldPos:     uint8(old.CardPos[cardix]),
+				BoardPiece: BoardPieceAll.Card,
+			})
+		}
+	}
+	for coneix, conePos := range v.ConePos {
+		if conePos != old.ConePos[coneix] {
+			d.Pieces = append(d.Pieces, &BoardPieceMove{
+				Index:      coneix,
+				NewPos:     uint8(conePos),
+				OldPos:     uint8(old.ConePos[coneix]),
+				BoardPiece: BoardPieceAll.Cone,
+			})
+		}
+	}
+	return d
+}
+
+// ApplyDelta returns a new view position with the delta applied.
+// The view position is not changed.
+func (v *ViewPos) ApplyDelta(d *ViewPosDelta) (n *ViewPos) {
+	n = v.Copy()
+	for _, piece := range d.Pieces {
+		if piece.IsCard() {
+			n.CardPos[piece.Index] = pos.Card(piece.NewPos)
+		} else {
+			n.ConePos[piece.Index] = pos.Cone(piece.NewPos)
+		}
+	}
+	n.PlayerReturned = d.PlayerReturned
+	n.CardsReturned = d.CardsReturned
+	n.LastMoveType = d.LastMoveType
+	n.LastMover = d.LastMover
+	n.LastMoveIx = d.LastMoveIx
+	n.Winner = d.Winner
+	n.NoTacs = d.NoTacs
+	n.NoTroops = d.NoTroops
+	n.Moves = d.Moves
+	return n
+}
@@ -0,0 +1,40 @@
+package game
+
+import (
+	"encoding/json"
+	"github.com/rezder/go-battleline/v2/game/pos"
+	"testing"
+)
+
+func TestViewPosDelta(t *testing.T) {
+	game := NewGame()
+	game.Start([2]int{1, 2}, 0)
+	var lastViews [4]*ViewPos
+	for i, view := range ViewAll.All() {
+		lastViews[i] = NewViewPos(game.Pos, view, pos.NoPlayer)
+	}
+	winner := pos.NoPlayer
+	for winner == pos.NoPlayer {
+		winner, _ = game.Move(testMove(game.Pos.CalcMoves()))
+		for i, view := range ViewAll.All() {
+			viewPos := NewViewPos(game.Pos, view, winner)
+			delta := NewViewPosDelta(lastViews[i], viewPos)
+			if len(delta.Pieces) > 10 {
+				t.Errorf("Delta should only contain the changed pieces got: %v", len(delta.Pieces))
+			}
+			js, err := json.Marshal(delta)
+			if err != nil {
+				t.Fatalf("Marshal delta failed: %v", err)
+			}
+			var decoded ViewPosDelta
+			if err = json.Unmarshal(js, &decoded); err != nil {
+				t.Fatalf("Unmarshal delta failed: %v", err)
+			}
+			applied := lastViews[i].ApplyDelta(&decoded)
+			if !applied.IsEqual(viewPos) {
+				t.Fatalf("Applied delta differs for view %v\nExpected:\n%v\nGot:\n%v", view, viewPos.Pos, applied.Pos)
+			}
+			lastViews[i] = viewPos
+		}
+	}
+}
@@ -206,10 +206,13 @@ func (g *Game) Pause(moves []*Move) (winner int) {
 }
 
 // Hist the history of a battleline game, every move made.
+// MatchID is zero if the game is not part of a match.
 type Hist struct {
 	Moves     []*Move
 	PlayerIDs [2]int
 	Time      time.Time
+	MatchID   int64
+	Chat      []*ChatMess
 }
 
 // Copy makes a copy of game history
@@ -218,12 +221,20 @@ func (h *Hist) Copy() (copy *Hist) {
 		copy = new(Hist)
 		copy.Time = h.Time
 		copy.PlayerIDs = h.PlayerIDs
+		copy.MatchID = h.MatchID
 		if h.Moves != nil {
 			copy.Moves = make([]*Move, len(h.Moves))
 			for i, refMove := range h.Moves {
 				copy.Moves[i] = refMove.Copy()
 			}
 		}
+		if h.Chat != nil {
+			copy.Chat = make([]*ChatMess, len(h.Chat))
+			for i, mess := range h.Chat {
+				cm := *mess
+				copy.Chat[i] = &cm
+			}
+		}
 	}
 	return copy
 }
@@ -237,7 +248,7 @@ func (h *Hist) IsEqual(o *Hist) bool {
 		return false
 	}
 	isEqual := false
-	if h.Time.Equal(o.Time) && h.PlayerIDs == o.PlayerIDs {
+	if h.Time.Equal(o.Time) && h.PlayerIDs == o.PlayerIDs && h.MatchID == o.MatchID {
 		if len(h.Moves) == len(o.Moves) {
 			isEqual = true
 			for i, move := range h.Moves {
@@ -247,10 +258,44 @@ func (h *Hist) IsEqual(o *Hist) bool {
 				}
 			}
 		}
+		if isEqual && len(h.Chat) == len(o.Chat) {
+			for i, mess := range h.Chat {
+				if !mess.IsEqual(o.Chat[i]) {
+					isEqual = false
+					break
+				}
+			}
+		} else {
+			isEqual = false
+		}
 	}
 	return isEqual
 }
 
+// ChatMess is a table chat message. Spectator messages are
+// only shown to spectators during the game.
+type ChatMess struct {
+	Time        time.Time
+	SenderID    int
+	SenderName  string
+	Message     string
+	IsSpectator bool
+}
+
+// IsEqual checks if two chat messages are equal.
+func (c *ChatMess) IsEqual(o *ChatMess) bool {
+	return c.Time.Equal(o.Time) &&
+		c.SenderID == o.SenderID &&
+		c.SenderName == o.SenderName &&
+		c.Message == o.Message &&
+		c.IsSpectator == o.IsSpectator
+}
+
+// AddChat adds a chat message to history.
+func (h *Hist) AddChat(mess *ChatMess) {
+	h.Chat = append(h.Chat, mess)
+}
+
 // AddMove adds a move to history.
 func (h *Hist) AddMove(move *Move) {
 	h.Moves = append(h.Moves, move)
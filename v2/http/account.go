@@ -0,0 +1,68 @@
+package http
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/http/login"
+	"github.com/rezder/go-error/log"
+	"net/http"
+)
+
+//accountHandler handles the self-service account requests of a logged in client.
+//All requests must include the current password.
+// ex: curl -b "name=Rene;sid=1234" -d "pwdPassword=12345678&pwdNewPassword=87654321" http://localhost:8282/in/account/password
+//     curl -b "name=Rene;sid=1234" -d "pwdPassword=12345678&txtNewName=Rene2" http://localhost:8282/in/account/rename
+//     curl -b "name=Rene;sid=1234" -d "pwdPassword=12345678" http://localhost:8282/in/account/delete
+type accountHandler struct {
+	clients *Clients
+	errCh   chan<- error
+}
+
+func (handler *accountHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name, sid, err := getCookies(r)
+	if err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	pw := r.FormValue("pwdPassword")
+	ip := remoteIP(r)
+	var status login.Status
+	if isThrottled(w, handler.clients.throttle, ip, name) {
+		status = login.StatusAll.Retry
+		err = httpWrite(struct{ LogInStatus login.Status }{LogInStatus: status}, w)
+		if err != nil {
+			handler.errCh <- err
+		}
+		return
+	}
+	switch r.URL.Path {
+	case "/in/account/password":
+		status, err = handler.clients.ChangePw(name, sid, pw, r.FormValue("pwdNewPassword"))
+	case "/in/account/rename":
+		status, err = handler.clients.Rename(name, sid, pw, r.FormValue("txtNewName"))
+	case "/in/account/delete":
+		status, err = handler.clients.Delete(name, sid, pw)
+	default:
+		handler.clients.throttle.release(ip, name)
+		http.NotFound(w, r)
+		return
+	}
+	handler.clients.throttle.finish(ip, name, status, err)
+	if err != nil {
+		handler.errCh <- err
+		status = login.StatusAll.Err
+	} else if status.IsInValid() {
+		err = errors.New(fmt.Sprintf(log.ErrNo(30)+"Account request %v failed! Name: %v Ip: %v", r.URL.Path, name, r.RemoteAddr))
+		handler.errCh <- err
+	} else if status.IsOk() {
+		log.Printf(log.Min, "Client %v account request %v", name, r.URL.Path)
+	}
+	err = httpWrite(struct{ LogInStatus login.Status }{LogInStatus: status}, w)
+	if err != nil {
+		handler.errCh <- err
+	}
+}
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/rezder/go-error/log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//adminHandler handles the admin api. The client must be logged in
+//with a admin account.
+// ex: curl -b "name=admin;sid=1234" http://localhost:8282/in/admin/clients
+//     curl -b "name=admin;sid=1234" -d "id=3&disable=true" http://localhost:8282/in/admin/disable
+//     curl -b "name=admin;sid=1234" -d "key=127.0.0.1" http://localhost:8282/in/admin/unlock
+//     curl -b "name=admin;sid=1234" -d "timeout=300" http://localhost:8282/in/admin/drain
+//The drain timeout defaults to the drainTimeout.
+type adminHandler struct {
+	clients      *Clients
+	errServer    *ErrServer
+	errCh        chan<- error
+	drainTimeout time.Duration
+}
+
+func (handler *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, sid, err := getCookies(r)
+	if err != nil || !handler.clients.VerifyAdmin(name, sid) {
+		err = errors.New(fmt.Sprintf(log.ErrNo(29)+"Admin request failed authorization! Name: %v Ip: %v", name, r.RemoteAddr))
+		handler.errCh <- err
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	isGet := r.URL.Path == "/in/admin/clients" || r.URL.Path == "/in/admin/tables" ||
+		r.URL.Path == "/in/admin/lockouts" || r.URL.Path == "/in/admin/errors"
+	if !isGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var resp interface{}
+	switch r.URL.Path {
+	case "/in/admin/clients":
+		resp = handler.clients.LogIns()
+	case "/in/admin/tables":
+		gameServer := handler.clients.GameServer()
+		if gameServer == nil {
+			http.Error(w, "Game server down", http.StatusServiceUnavailable)
+			return
+		}
+		resp = gameServer.Tables()
+	case "/in/admin/boot":
+		id, ok := formID(w, r)
+		if !ok {
+			return
+		}
+		resp = struct{ IsFound bool }{IsFound: handler.clients.Boot(id)}
+	case "/in/admin/disable":
+		id, ok := formID(w, r)
+		if !ok {
+			return
+		}
+		isDisable := r.FormValue("disable") != "false"
+		err = handler.clients.UpdateDisable(id, isDisable)
+		if err != nil {
+			handler.errCh <- err
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !isDisable {
+			handler.errServer.ResetPlayer(id)
+		}
+		resp = struct{ IsDisable bool }{IsDisable: isDisable}
+	case "/in/admin/savetable":
+		id, ok := formID(w, r)
+		if !ok {
+			return
+		}
+		gameServer := handler.clients.GameServer()
+		if gameServer == nil {
+			http.Error(w, "Game server down", http.StatusServiceUnavailable)
+			return
+		}
+		resp = struct{ IsFound bool }{IsFound: gameServer.SaveTable(id)}
+	case "/in/admin/broadcast":
+		gameServer := handler.clients.GameServer()
+		if gameServer == nil {
+			http.Error(w, "Game server down", http.StatusServiceUnavailable)
+			return
+		}
+		gameServer.Broadcast(r.FormValue("message"))
+		resp = struct{ IsSend bool }{IsSend: true}
+	case "/in/admin/errors":
+		resp = handler.errServer.PlayerErrs()
+	case "/in/admin/lockouts":
+		resp = handler.clients.Lockouts()
+	case "/in/admin/unlock":
+		resp = struct{ IsFound bool }{IsFound: handler.clients.Unlock(r.FormValue("key"))}
+	case "/in/admin/drain":
+		timeout := handler.drainTimeout
+		if txt := r.FormValue("timeout"); len(txt) > 0 {
+			secs, convErr := strconv.Atoi(txt)
+			if convErr != nil {
+				http.Error(w, "Invalid timeout", http.StatusBadRequest)
+				return
+			}
+			timeout = time.Duration(secs) * time.Second
+		}
+		resp = struct{ IsStarted bool }{IsStarted: handler.clients.Drain(timeout)}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	log.Printf(log.Min, "Admin %v request %v", name, r.URL.Path)
+	err = httpWrite(resp, w)
+	if err != nil {
+		handler.errCh <- err
+	}
+}
+
+//formID reads the player id from the form, a bad request is returned
+//if it fails.
+func formID(w http.ResponseWriter, r *http.Request) (id int, ok bool) {
+	id, err := strconv.Atoi(r.FormValue("id"))
+	if err != nil {
+		http.Error(w, "Missing or invalid id", http.StatusBadRequest)
+		return id, false
+	}
+	return id, true
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/rezder/go-battleline/v2/http/config"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battadmin")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	cfg := config.Default()
+	cfg.DataDir = dir
+	cfg.RootDir = dir
+	cfg.Port = 0
+	cfg.ArchPokePort = 0
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Creating server failed: %v", err)
+	}
+	server.Start()
+	defer server.Stop()
+	errCh := make(chan error, 10)
+	handler := &adminHandler{server.clients, server.errServer, errCh, time.Minute}
+	_, adminSid, err := server.clients.AddNew("Admin", testPW)
+	if err != nil {
+		t.Fatalf("Creating admin failed: %v", err)
+	}
+	server.clients.logIns["Admin"].Role = RoleAll.Admin
+	_, playerSid, err := server.clients.AddNew("Hans", testPW)
+	if err != nil {
+		t.Fatalf("Creating player failed: %v", err)
+	}
+	playerID := server.clients.logIns["Hans"].ID
+	admin := func(path string, form url.Values, v interface{}) int {
+		return testAdminReq(t, handler, "Admin", adminSid, path, form, v)
+	}
+
+	if code := testAdminReq(t, handler, "Hans", playerSid, "/in/admin/clients", nil, nil); code != http.StatusForbidden {
+		t.Errorf("Player admin request should be forbidden got: %v", code)
+	}
+	if code := testAdminReq(t, handler, "Admin", "1234", "/in/admin/clients", nil, nil); code != http.StatusForbidden {
+		t.Errorf("Admin request with a invalid session should be forbidden got: %v", code)
+	}
+	if len(errCh) != 2 {
+		t.Errorf("Forbidden requests should be reported got %v errors", len(errCh))
+	}
+	if code := testAdminReq(t, handler, "Admin", adminSid, "/in/admin/boot", nil, nil); code != http.StatusMethodNotAllowed {
+		t.Errorf("Get of a admin operation should not be allowed got: %v", code)
+	}
+	if code := admin("/in/admin/none", url.Values{}, nil); code != http.StatusNotFound {
+		t.Errorf("Unknown admin request should not be found got: %v", code)
+	}
+
+	var logIns []*LogInData
+	admin("/in/admin/clients", nil, &logIns)
+	if len(logIns) != 2 {
+		t.Errorf("Expected 2 logged in clients got: %v", len(logIns))
+	}
+	var tables []interface{}
+	admin("/in/admin/tables", nil, &tables)
+	if len(tables) != 0 {
+		t.Errorf("Expected no tables got: %v", tables)
+	}
+	var playerErrs []*PlayerErrData
+	admin("/in/admin/errors", nil, &playerErrs)
+	if len(playerErrs) != 0 {
+		t.Errorf("Expected no player errors got: %v", playerErrs)
+	}
+
+	id := url.Values{"id": {strconv.Itoa(playerID)}}
+	var found struct{ IsFound bool }
+	if code := admin("/in/admin/boot", url.Values{"id": {"x"}}, nil); code != http.StatusBadRequest {
+		t.Errorf("Boot with a invalid id should be a bad request got: %v", code)
+	}
+	admin("/in/admin/boot", id, &found)
+	if !found.IsFound || !server.clients.logIns["Hans"].isBootOut {
+		t.Errorf("Player should be booted got: %v", found)
+	}
+	found.IsFound = true
+	admin("/in/admin/savetable", id, &found)
+	if found.IsFound {
+		t.Error("Save table should not find a table")
+	}
+
+	var disable struct{ IsDisable bool }
+	for _, isDisable := range []bool{true, false} {
+		admin("/in/admin/disable", url.Values{"id": id["id"], "disable": {strconv.FormatBool(isDisable)}}, &disable)
+		client, _, err := server.clients.cdb.GetID(playerID)
+		if err != nil || disable.IsDisable != isDisable || client.IsDisable != isDisable {
+			t.Errorf("Disable %v failed got: %v, %v", isDisable, disable, err)
+		}
+	}
+
+	var send struct{ IsSend bool }
+	admin("/in/admin/broadcast", url.Values{"message": {"Hello"}}, &send)
+	if !send.IsSend {
+		t.Error("Broadcast should be send")
+	}
+
+	server.clients.throttle.fail("127.0.0.1", "Hans")
+	var lockouts []*LockoutData
+	admin("/in/admin/lockouts", nil, &lockouts)
+	if len(lockouts) != 2 {
+		t.Errorf("Expected a account and a ip lockout got: %v", lockouts)
+	}
+	admin("/in/admin/unlock", url.Values{"key": {"127.0.0.1"}}, &found)
+	if !found.IsFound || len(server.clients.Lockouts()) != 1 {
+		t.Errorf("Unlock of ip failed got: %v", found)
+	}
+
+	if code := admin("/in/admin/drain", url.Values{"timeout": {"x"}}, nil); code != http.StatusBadRequest {
+		t.Errorf("Drain with a invalid timeout should be a bad request got: %v", code)
+	}
+	var drain struct{ IsStarted bool }
+	admin("/in/admin/drain", url.Values{"timeout": {"0"}}, &drain)
+	if !drain.IsStarted {
+		t.Error("Drain should be started")
+	}
+	admin("/in/admin/drain", url.Values{}, &drain)
+	if drain.IsStarted {
+		t.Error("Drain should only be started once")
+	}
+}
+
+//testAdminReq makes a admin request, a nil form makes a get request.
+//The response is decoded into v if the request is ok.
+func testAdminReq(t *testing.T, handler http.Handler, name, sid, path string, form url.Values, v interface{}) int {
+	var r *http.Request
+	if form == nil {
+		r = httptest.NewRequest(http.MethodGet, path, nil)
+	} else {
+		r = httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	r.AddCookie(&http.Cookie{Name: "name", Value: name})
+	r.AddCookie(&http.Cookie{Name: "sid", Value: sid})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code == http.StatusOK && v != nil {
+		err := json.NewDecoder(w.Body).Decode(v)
+		if err != nil {
+			t.Errorf("Decoding %v response failed: %v", path, err)
+		}
+	}
+	return w.Code
+}
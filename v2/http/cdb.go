@@ -126,6 +126,141 @@ func (cdb *CDb) UpdDisable(id int, isDisable bool) (name string, isUpd bool, err
 	return name, isUpd, err
 }
 
+//UpdRole update a clients role.
+func (cdb *CDb) UpdRole(name string, role Role) (isUpd bool, err error) {
+	return cdb.upd(name, func(client *Client) bool {
+		if client.Role != role {
+			client.Role = role
+			return true
+		}
+		return false
+	})
+}
+
+//UpdToken update a bot clients api token hash.
+func (cdb *CDb) UpdToken(name string, token []byte) (err error) {
+	_, err = cdb.upd(name, func(client *Client) bool {
+		client.Token = token
+		return true
+	})
+	return err
+}
+
+//UpdPw update a clients password hash.
+func (cdb *CDb) UpdPw(name string, pwh []byte) (err error) {
+	_, err = cdb.upd(name, func(client *Client) bool {
+		client.Pw = pwh
+		return true
+	})
+	return err
+}
+
+//UpdName renames a client, the old name is released.
+//isUpd is false if the new name already exist.
+func (cdb *CDb) UpdName(name, newName string) (isUpd bool, err error) {
+	err = cdb.db.Update(func(tx *bolt.Tx) (updErr error) {
+		ixBck := tx.Bucket(cdb.ixBck)
+		keyBs := ixBck.Get([]byte(name))
+		if keyBs == nil {
+			updErr = errors.WithStack(fmt.Errorf("Client with name %v does not exist", name))
+			return updErr
+		}
+		if ixBck.Get([]byte(newName)) != nil {
+			return updErr
+		}
+		clientBck := tx.Bucket(cdb.clientBck)
+		client, updErr := decode(clientBck.Get(keyBs))
+		if updErr != nil {
+			return updErr
+		}
+		client.Name = newName
+		clientBs, updErr := encode(client)
+		if updErr != nil {
+			return updErr
+		}
+		keyBs = itob(client.ID)
+		updErr = clientBck.Put(keyBs, clientBs)
+		if updErr != nil {
+			return updErr
+		}
+		updErr = ixBck.Delete([]byte(name))
+		if updErr != nil {
+			return updErr
+		}
+		updErr = ixBck.Put([]byte(newName), keyBs)
+		if updErr != nil {
+			return updErr
+		}
+		isUpd = true
+		return updErr
+	})
+	return isUpd, err
+}
+
+//Delete deletes a client and release the name.
+func (cdb *CDb) Delete(name string) (isDeleted bool, err error) {
+	err = cdb.db.Update(func(tx *bolt.Tx) (updErr error) {
+		ixBck := tx.Bucket(cdb.ixBck)
+		keyBs := ixBck.Get([]byte(name))
+		if keyBs == nil {
+			return updErr
+		}
+		keyBs = append([]byte(nil), keyBs...)
+		updErr = tx.Bucket(cdb.clientBck).Delete(keyBs)
+		if updErr != nil {
+			return updErr
+		}
+		updErr = ixBck.Delete([]byte(name))
+		if updErr != nil {
+			return updErr
+		}
+		isDeleted = true
+		return updErr
+	})
+	return isDeleted, err
+}
+
+//upd updates a client. updFunc updates the client and returns
+//true if the client must be saved.
+func (cdb *CDb) upd(name string, updFunc func(*Client) bool) (isUpd bool, err error) {
+	err = cdb.db.Update(func(tx *bolt.Tx) (updErr error) {
+		ixBck := tx.Bucket(cdb.ixBck)
+		keyBs := ixBck.Get([]byte(name))
+		if keyBs == nil {
+			updErr = errors.WithStack(fmt.Errorf("Client with name %v does not exist", name))
+			return updErr
+		}
+		clientBck := tx.Bucket(cdb.clientBck)
+		client, updErr := decode(clientBck.Get(keyBs))
+		if updErr != nil {
+			return updErr
+		}
+		if updFunc(client) {
+			var clientBs []byte
+			clientBs, updErr = encode(client)
+			if updErr != nil {
+				return updErr
+			}
+			updErr = clientBck.Put(keyBs, clientBs)
+			if updErr != nil {
+				return updErr
+			}
+			isUpd = true
+		}
+		return updErr
+	})
+	return isUpd, err
+}
+
+//Size returns the size of the database.
+func (cdb *CDb) Size() (size int64, err error) {
+	err = cdb.db.View(func(tx *bolt.Tx) error {
+		size = tx.Size()
+		return nil
+	})
+	return size, err
+}
+
 //BackupHandleFunc handles http back up requests.
 func (cdb *CDb) BackupHandleFunc(w http.ResponseWriter, req *http.Request) {
 	err := cdb.db.View(func(tx *bolt.Tx) error {
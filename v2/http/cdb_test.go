@@ -15,6 +15,8 @@ func TestCDb(t *testing.T) {
 	clients := testInsert(cdb, t)
 	testGet(cdb, clients, t)
 	testDisable(cdb, clients[1], t)
+	testRole(cdb, clients[0], t)
+	testRenameDelete(cdb, clients, t)
 	err = cdb.Close()
 	if err != nil {
 		t.Errorf("Failed closed with error :%v", err)
@@ -135,3 +137,56 @@ func testDisable(cdb *CDb, inClient2 *Client, t *testing.T) {
 		t.Error("Saved client should be disable")
 	}
 }
+
+func testRole(cdb *CDb, client *Client, t *testing.T) {
+	isUpd, err := cdb.UpdRole(client.Name, RoleAll.Admin)
+	if err != nil {
+		t.Errorf("Upd role failed with Error: %v", err)
+	}
+	if !isUpd {
+		t.Error("Update role failed")
+	}
+	sClient, _, err := cdb.GetName(client.Name)
+	if err != nil {
+		t.Errorf("GetName failed with Error: %v", err)
+	}
+	if !sClient.Role.IsAdmin() {
+		t.Errorf("Saved client should be admin got: %v", sClient.Role)
+	}
+	_, err = cdb.UpdRole("NoName", RoleAll.Admin)
+	if err == nil {
+		t.Error("Update role of missing client should fail")
+	}
+}
+
+func testRenameDelete(cdb *CDb, clients []*Client, t *testing.T) {
+	isUpd, err := cdb.UpdName(clients[0].Name, clients[1].Name)
+	if err != nil || isUpd {
+		t.Errorf("Rename to existing name should not update got: %v, %v", isUpd, err)
+	}
+	isUpd, err = cdb.UpdName(clients[0].Name, "Renamed")
+	if err != nil || !isUpd {
+		t.Errorf("Rename failed got: %v, %v", isUpd, err)
+	}
+	_, isFound, _ := cdb.GetName(clients[0].Name)
+	if isFound {
+		t.Errorf("Old name %v should be released", clients[0].Name)
+	}
+	sClient, isFound, _ := cdb.GetName("Renamed")
+	if !isFound || sClient.ID != clients[0].ID {
+		t.Errorf("Renamed client not found: %v", sClient)
+	}
+	isDeleted, err := cdb.Delete("Renamed")
+	if err != nil || !isDeleted {
+		t.Errorf("Delete failed got: %v, %v", isDeleted, err)
+	}
+	_, isFound, _ = cdb.GetID(clients[0].ID)
+	if isFound {
+		t.Error("Deleted client should not be found")
+	}
+	_, isUpd, err = cdb.UpdInsert(clients[0])
+	if err != nil || !isUpd {
+		t.Errorf("Insert of released name failed got: %v, %v", isUpd, err)
+	}
+	testLogDb(t, cdb)
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/pkg/errors"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+//GenerateCert generates a self-signed certificate and key for local development.
+//The certificate is its own CA so it can be used as CA bundle by the clients.
+//hosts is the host names and ips the certificate is valid for.
+func GenerateCert(certFile, keyFile string, hosts []string) (err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return errors.Wrap(err, "Generating key failed")
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return errors.Wrap(err, "Generating serial number failed")
+	}
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Battleline development"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	certBs, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return errors.Wrap(err, "Creating certificate failed")
+	}
+	keyBs, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return errors.Wrap(err, "Marshal key failed")
+	}
+	err = writePem(certFile, "CERTIFICATE", certBs, 0644)
+	if err != nil {
+		return err
+	}
+	err = writePem(keyFile, "EC PRIVATE KEY", keyBs, 0600)
+	return err
+}
+
+//writePem writes a pem file.
+func writePem(fileName, blockType string, bs []byte, perm os.FileMode) (err error) {
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.Wrapf(err, "Open file %v failed", fileName)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	err = pem.Encode(file, &pem.Block{Type: blockType, Bytes: bs})
+	if err != nil {
+		err = errors.Wrapf(err, "Writing file %v failed", fileName)
+	}
+	return err
+}
+
+//loadTLSConfig loads the certificate and key, nil is returned if
+//no certificate file is given.
+func loadTLSConfig(certFile, keyFile string) (config *tls.Config, err error) {
+	if len(certFile) == 0 {
+		return config, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return config, errors.Wrapf(err, "Loading certificate %v and key %v failed", certFile, keyFile)
+	}
+	config = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	return config, err
+}
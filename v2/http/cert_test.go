@@ -0,0 +1,55 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batttls")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = GenerateCert(certFile, keyFile, []string{"localhost", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("Generate certificate failed: %v", err)
+	}
+	config, err := loadTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Loading certificate failed: %v", err)
+	}
+	netListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil {
+			t.Error("Request should be tls")
+		}
+	})}
+	go func() { _ = server.Serve(tls.NewListener(netListener, config)) }()
+	defer func() { _ = server.Close() }()
+	pemBs, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("Reading certificate failed: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBs) {
+		t.Fatal("Certificate is not a valid CA bundle")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + netListener.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("Https request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+}
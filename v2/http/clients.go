@@ -1,13 +1,17 @@
 package http
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"github.com/pkg/errors"
 	"github.com/rezder/go-battleline/v2/http/games"
 	"github.com/rezder/go-battleline/v2/http/login"
+	"github.com/rezder/go-error/log"
 	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/net/websocket"
-	"math/rand"
-	"strconv"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 )
@@ -15,14 +19,18 @@ import (
 const (
 	//pwCOST the password time cost, because of future improvement in hardware.
 	pwCOST = 5
+	//botNamePREFIX the name prefix of the server bots.
+	botNamePREFIX = "Bot"
 )
 
 var (
 	//NAMESIZE the name character size limit.
 	nameSIZE = [2]int{4, 20}
 	//PWSIZE the password size limit.
-	pwSIZE        = 8
-	dbClientsFILE = "server/data/clients.db" // can be change for test
+	pwSIZE = 8
+	//botNameRE matches the server bot names prefix, strength and number,
+	//clients can not register the names.
+	botNameRE = newBotNameRE()
 )
 
 //Client the login object. Hold information of the user including
@@ -32,12 +40,14 @@ type Client struct {
 	ID        int
 	Pw        []byte
 	IsDisable bool
+	Role      Role
+	Token     []byte //Hash of the bot api token.
 	//Filled when logIn
 	sid     string
 	sidTime time.Time
-	//Filled when establish websocket. Just because they login does
-	// not garantie they etstablish a web socket
-	ws *websocket.Conn
+	//Filled when establish a websocket or long-poll connection. Just
+	// because they login does not garantie they etstablish a connection
+	conn games.Conn
 	//Filled when asked to logout
 	isBootOut bool
 }
@@ -50,6 +60,11 @@ func (c *Client) Copy() *Client {
 		copy(pwh, c.Pw)
 		cv.Pw = pwh
 	}
+	if c.Token != nil {
+		th := make([]byte, len(c.Token))
+		copy(th, c.Token)
+		cv.Token = th
+	}
 	return &cv
 }
 
@@ -61,25 +76,24 @@ func (c *Client) IsEqual(o *Client) (isEqual bool) {
 		if c.Name == o.Name &&
 			c.ID == o.ID &&
 			c.IsDisable == o.IsDisable &&
+			c.Role == o.Role &&
 			c.sid == o.sid &&
 			c.sidTime == o.sidTime &&
-			c.ws == o.ws &&
+			c.conn == o.conn &&
 			c.isBootOut == o.isBootOut {
-			if len(c.Pw) == len(o.Pw) {
-				isEqual = true
-				for i, b := range c.Pw {
-					if b != o.Pw[i] {
-						isEqual = false
-						break
-					}
-				}
-			}
+			isEqual = bytes.Equal(c.Pw, o.Pw) && bytes.Equal(c.Token, o.Token)
 		}
 	}
 
 	return isEqual
 }
 
+//isSession checks the session id and that the session has not expired.
+func (c *Client) isSession(sid string, expiry time.Duration) bool {
+	return len(c.sid) != 0 && c.sid == sid && (expiry <= 0 || time.Since(c.sidTime) < expiry)
+}
+
+//NewClient creates a new player client.
 func NewClient(name string, pwTxt string) (client *Client, err error) {
 	pwh, err := bcrypt.GenerateFromPassword([]byte(pwTxt), pwCOST) //TODO we need salt some day, so player with same password does not have same hash.
 	if err != nil {
@@ -91,24 +105,56 @@ func NewClient(name string, pwTxt string) (client *Client, err error) {
 	return client, err
 }
 
+//NewBotClient creates a new bot client. The bot logs in with the returned
+//api token, the token can not be recovered from the client.
+func NewBotClient(name string) (client *Client, token string, err error) {
+	token, th, err := NewBotToken()
+	if err != nil {
+		return client, token, err
+	}
+	client = new(Client)
+	client.Name = name
+	client.Role = RoleAll.Bot
+	client.Token = th
+	return client, token, err
+}
+
+//NewBotToken creates a random bot api token and its hash.
+func NewBotToken() (token string, hash []byte, err error) {
+	token, err = randomHex(16)
+	if err != nil {
+		return token, hash, errors.Wrap(err, "Creating token failed")
+	}
+	hash, err = bcrypt.GenerateFromPassword([]byte(token), pwCOST)
+	return token, hash, err
+}
+
 //Clients the clients list.
 type Clients struct {
 	mu         *sync.RWMutex
 	logIns     map[string]*Client
 	cdb        *CDb
 	gameServer *games.Server
+	isDrain    bool
+	//sessionExpiry the life time of a session, zero for no expiry.
+	//A expired session can not be used for new requests but a
+	//joined client keeps playing until the connection closes.
+	sessionExpiry time.Duration
+	throttle      *throttle
 }
 
-//NewClients creates new clients.
-func NewClients(games *games.Server) (clients *Clients, err error) {
+//NewClients creates new clients with the clients database file.
+func NewClients(games *games.Server, dbFile string, sessionExpiry time.Duration) (clients *Clients, err error) {
 	clients = new(Clients)
 	clients.gameServer = games
+	clients.sessionExpiry = sessionExpiry
+	clients.throttle = newThrottle()
 	clients.mu = new(sync.RWMutex)
 	clients.logIns = make(map[string]*Client)
-	db, err := NewCdb(dbClientsFILE)
+	db, err := NewCdb(dbFile)
 	if err != nil {
 		_ = clients.gameServer.Cancel()
-		err = errors.Wrapf(err, "Init data base file %v failed", dbClientsFILE)
+		err = errors.Wrapf(err, "Init data base file %v failed", dbFile)
 		return clients, err
 	}
 	clients.cdb = db
@@ -155,21 +201,28 @@ func (clients *Clients) SetGameServer(games *games.Server) (oldGames *games.Serv
 	return oldGames
 }
 
+//GameServer returns the game server, nil if down.
+func (clients *Clients) GameServer() *games.Server {
+	clients.mu.RLock()
+	defer clients.mu.RUnlock()
+	return clients.gameServer
+}
+
 //JoinGameServer add a client to the game server.
 //ok: True: if request succeded the player is returned on the joined channel
 // when ready.
 //isJoined: True: if the client is already loged-in.
-func (clients *Clients) JoinGameServer(name string, sid string, ws *websocket.Conn,
+func (clients *Clients) JoinGameServer(name string, sid string, conn games.Conn,
 	errCh chan<- error, joinedCh chan<- *games.Player) (ok, isJoined bool) {
 	clients.mu.Lock()
 	defer clients.mu.Unlock()
 	if clients.gameServer != nil {
 		client, found := clients.logIns[name]
 		if found {
-			if client.sid == sid { //I do not think this is necessary because of the handshake
-				if client.ws == nil {
-					client.ws = ws
-					clients.gameServer.JoinClient(client.ID, client.Name, client.ws, errCh, joinedCh)
+			if client.isSession(sid, clients.sessionExpiry) { //I do not think this is necessary because of the handshake
+				if client.conn == nil {
+					client.conn = conn
+					clients.gameServer.JoinClient(client.ID, client.Name, client.Role.IsBot(), client.conn, errCh, joinedCh)
 					ok = true
 				} else {
 					isJoined = true
@@ -180,32 +233,60 @@ func (clients *Clients) JoinGameServer(name string, sid string, ws *websocket.Co
 	return ok, isJoined
 }
 
+//PollConn returns the long-poll connection of a client.
+func (clients *Clients) PollConn(name, sid string) (conn *pollConn, isFound bool) {
+	clients.mu.RLock()
+	defer clients.mu.RUnlock()
+	client, found := clients.logIns[name]
+	if found && client.isSession(sid, clients.sessionExpiry) {
+		conn, isFound = client.conn.(*pollConn)
+	}
+	return conn, isFound
+}
+
 // VerifySid verify name and session id,
-//before ws is sat.
+//before the connection is sat.
 func (clients *Clients) VerifySid(name, sid string) (ok, isDown bool) {
 	clients.mu.RLock()
 	defer clients.mu.RUnlock()
 	isDown = clients.gameServer == nil
 	client, found := clients.logIns[name]
 	if found {
-		if client.sid == sid && client.ws == nil {
+		if client.isSession(sid, clients.sessionExpiry) && client.conn == nil {
 			ok = true
 		}
 	}
 	return ok, isDown
 }
 
-//LogIn log-in a client.
+//LogIn log-in a client with password.
+//Bot accounts can only log-in with a token.
 func (clients *Clients) LogIn(name string, pw string) (status login.Status, sid string, err error) {
+	return clients.logIn(name, pw, false)
+}
+
+//LogInToken log-in a bot client with its api token.
+func (clients *Clients) LogInToken(name string, token string) (status login.Status, sid string, err error) {
+	return clients.logIn(name, token, true)
+}
+
+//logIn log-in a client with password or token.
+func (clients *Clients) logIn(name string, secret string, isToken bool) (status login.Status, sid string, err error) {
 	client, isFound, err := clients.cdb.GetName(name)
 	if err != nil {
 		err = errors.Wrapf(err, "Failed loading client %v from database", name)
 		return status, sid, err
 	}
-	if !isFound {
+	var hash []byte
+	if isFound && isToken {
+		hash = client.Token
+	} else if isFound {
+		hash = client.Pw
+	}
+	if !isFound || client.Role.IsBot() != isToken || len(hash) == 0 {
 		status = login.StatusAll.InValid
 	} else {
-		cerr := bcrypt.CompareHashAndPassword(client.Pw, []byte(pw))
+		cerr := bcrypt.CompareHashAndPassword(hash, []byte(secret))
 		if cerr == bcrypt.ErrMismatchedHashAndPassword {
 			status = login.StatusAll.InValid
 		} else if cerr != nil {
@@ -213,7 +294,7 @@ func (clients *Clients) LogIn(name string, pw string) (status login.Status, sid
 		} else {
 			clients.mu.Lock()
 			defer clients.mu.Unlock()
-			if clients.gameServer == nil {
+			if clients.gameServer == nil || clients.isDrain {
 				status = login.StatusAll.Down
 			} else {
 				client, isFound, err = clients.cdb.GetID(client.ID)
@@ -226,7 +307,7 @@ func (clients *Clients) LogIn(name string, pw string) (status login.Status, sid
 					return status, sid, err
 				}
 				inClient, isIn := clients.logIns[name]
-				if isIn && inClient.ws != nil || isIn && time.Since(inClient.sidTime) < time.Minute*3 {
+				if isIn && inClient.conn != nil || isIn && time.Since(inClient.sidTime) < time.Minute*3 {
 					status = login.StatusAll.Exist
 				} else {
 					if isIn {
@@ -235,7 +316,10 @@ func (clients *Clients) LogIn(name string, pw string) (status login.Status, sid
 					if client.IsDisable {
 						status = login.StatusAll.Disabled
 					} else {
-						client.sid = sessionID()
+						client.sid, err = sessionID()
+						if err != nil {
+							return status, sid, err
+						}
 						client.sidTime = time.Now()
 						sid = client.sid
 						clients.logIns[name] = client
@@ -255,18 +339,163 @@ func (clients *Clients) UpdateDisable(id int, isDisable bool) (err error) {
 		return err
 	}
 	if isUpd {
-		clients.bootPlayer(name)
+		clients.mu.Lock()
+		client, isFound := clients.logIns[name]
+		clients.mu.Unlock()
+		if isFound {
+			clients.boot(client)
+		}
 	}
 	return err
 }
-func (clients *Clients) bootPlayer(name string) {
+
+//boot marks the client as booted out and boots it from the game server.
+//The lock must not be held as the game server may be waiting on it.
+func (clients *Clients) boot(client *Client) {
+	clients.mu.Lock()
+	client.isBootOut = true
+	gameServer := clients.gameServer
+	clients.mu.Unlock()
+	if gameServer != nil {
+		gameServer.BootPlayer(client.ID)
+	}
+}
+
+//AddBots adds the server bots to the game server, noBots of every strength.
+//A existing bot account is reused, a bot name used by a account without
+//the bot role is skipped.
+//The bot accounts are created if they do not exist, the token is not used
+//as server bots do not log in.
+func (clients *Clients) AddBots(noBots int) (err error) {
+	var client *Client
+	var isFound bool
+	for _, strength := range games.BotStrengthAll.All {
+		for i := 1; i <= noBots; i++ {
+			name := fmt.Sprintf("%v%v%v", botNamePREFIX, strength, i)
+			client, isFound, err = clients.cdb.GetName(name)
+			if err != nil {
+				return errors.Wrapf(err, "Failed loading bot %v from database", name)
+			}
+			if isFound && !client.Role.IsBot() {
+				log.Printf(log.Min, "Skipping server bot %v the account exist without the bot role", name)
+				continue
+			}
+			if !isFound {
+				client, _, err = NewBotClient(name)
+				if err != nil {
+					return err
+				}
+				client, _, err = clients.cdb.UpdInsert(client)
+				if err != nil {
+					return errors.Wrapf(err, "Failed creating bot %v", name)
+				}
+			}
+			clients.gameServer.AddBot(client.ID, client.Name, strength)
+		}
+	}
+	return err
+}
+
+//VerifyAdmin verify name and session id of a admin.
+func (clients *Clients) VerifyAdmin(name, sid string) (ok bool) {
+	clients.mu.RLock()
+	defer clients.mu.RUnlock()
 	client, found := clients.logIns[name]
 	if found {
-		client.isBootOut = true
-		if clients.gameServer != nil {
-			clients.gameServer.BootPlayer(client.ID)
+		ok = client.isSession(sid, clients.sessionExpiry) && client.Role.IsAdmin()
+	}
+	return ok
+}
+
+//LogInData is the admin information of a logged in client.
+type LogInData struct {
+	ID        int
+	Name      string
+	Role      Role
+	LogInTime time.Time
+	IsJoined  bool //The client have a websocket or long-poll connection.
+	IsBootOut bool
+}
+
+//LogIns returns the logged in clients.
+func (clients *Clients) LogIns() (logIns []*LogInData) {
+	clients.mu.RLock()
+	defer clients.mu.RUnlock()
+	logIns = make([]*LogInData, 0, len(clients.logIns))
+	for _, client := range clients.logIns {
+		logIns = append(logIns, &LogInData{
+			ID:        client.ID,
+			Name:      client.Name,
+			Role:      client.Role,
+			LogInTime: client.sidTime,
+			IsJoined:  client.conn != nil,
+			IsBootOut: client.isBootOut,
+		})
+	}
+	return logIns
+}
+
+//Counts returns the number of logged-in clients and the number
+//of clients with a websocket and a long-poll connection.
+func (clients *Clients) Counts() (logIns, websockets, polls int) {
+	clients.mu.RLock()
+	defer clients.mu.RUnlock()
+	for _, client := range clients.logIns {
+		if _, isPoll := client.conn.(*pollConn); isPoll {
+			polls++
+		} else if client.conn != nil {
+			websockets++
 		}
 	}
+	return len(clients.logIns), websockets, polls
+}
+
+//Lockouts returns the accounts and ips with failed log-ins.
+func (clients *Clients) Lockouts() []*LockoutData {
+	return clients.throttle.lockouts()
+}
+
+//Unlock removes the log-in lockout of a account or ip.
+func (clients *Clients) Unlock(key string) (isFound bool) {
+	return clients.throttle.unlock(key)
+}
+
+//Boot boots a logged in client out of the game server.
+func (clients *Clients) Boot(id int) (isFound bool) {
+	var bootClient *Client
+	clients.mu.Lock()
+	for _, client := range clients.logIns {
+		if client.ID == id {
+			bootClient = client
+			break
+		}
+	}
+	clients.mu.Unlock()
+	if bootClient != nil {
+		clients.boot(bootClient)
+		isFound = true
+	}
+	return isFound
+}
+
+//Drain stops new log-ins and new games, the players is informed with
+//a system message. The games running after the timeout is saved and
+//offered to the players after the restart.
+//isStarted is false if the drain is already started.
+func (clients *Clients) Drain(timeout time.Duration) (isStarted bool) {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	if !clients.isDrain && clients.gameServer != nil {
+		clients.isDrain = true
+		clients.gameServer.Drain(timeout)
+		txt := "The server is closing down for maintenance, no new games can be started."
+		if timeout > 0 {
+			txt = fmt.Sprintf("%v Games not finished in %v is saved and offered again after the restart.", txt, timeout)
+		}
+		clients.gameServer.Broadcast(txt)
+		isStarted = true
+	}
+	return isStarted
 }
 
 //IsGameServerDown checks if the game server is down.
@@ -276,7 +505,12 @@ func (clients *Clients) IsGameServerDown() bool {
 
 //AddNew create and log-in a new client.
 func (clients *Clients) AddNew(name string, pwTxt string) (status login.Status, sid string, err error) {
-	if checkNamePwSize(name, pwTxt) {
+	clients.mu.RLock()
+	_, isIn := clients.logIns[name] //A deleted or renamed client may not have left yet.
+	clients.mu.RUnlock()
+	if isIn {
+		status = login.StatusAll.Exist
+	} else if checkNamePwSize(name, pwTxt) && !isBotName(name) {
 		client, err := NewClient(name, pwTxt)
 		if err != nil {
 			return status, sid, err
@@ -289,7 +523,7 @@ func (clients *Clients) AddNew(name string, pwTxt string) (status login.Status,
 		if isUpd {
 			clients.mu.Lock()
 			defer clients.mu.Unlock()
-			if clients.gameServer == nil {
+			if clients.gameServer == nil || clients.isDrain {
 				status = login.StatusAll.Down
 			} else {
 				var isFound bool
@@ -302,7 +536,10 @@ func (clients *Clients) AddNew(name string, pwTxt string) (status login.Status,
 					err = errors.Errorf("Failed to load just verified client name,id %v,%v from data base, this should never happen", name, client.ID)
 					return status, sid, err
 				}
-				client.sid = sessionID()
+				client.sid, err = sessionID()
+				if err != nil {
+					return status, sid, err
+				}
 				client.sidTime = time.Now()
 				sid = client.sid
 				clients.logIns[name] = client
@@ -317,11 +554,186 @@ func (clients *Clients) AddNew(name string, pwTxt string) (status login.Status,
 	return status, sid, err
 }
 
-//create a random session id.
-func sessionID() (txt string) {
-	i := rand.Int()
-	txt = strconv.Itoa(i)
-	return txt
+//ChangePw changes the password of a logged in client.
+func (clients *Clients) ChangePw(name, sid, pw, newPw string) (status login.Status, err error) {
+	_, status, err = clients.verifyPw(name, sid, pw)
+	if err != nil || !status.IsOk() {
+		return status, err
+	}
+	if !checkNamePwSize(name, newPw) {
+		status = login.StatusAll.InValid
+		return status, err
+	}
+	pwh, err := bcrypt.GenerateFromPassword([]byte(newPw), pwCOST)
+	if err != nil {
+		return status, err
+	}
+	err = clients.cdb.UpdPw(name, pwh)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed updating password of client %v", name)
+	}
+	return status, err
+}
+
+//Delete deletes a logged in client and release the name.
+//The client is logged out and booted from the game server,
+//the saved games and a game in progress is deleted.
+func (clients *Clients) Delete(name, sid, pw string) (status login.Status, err error) {
+	client, status, err := clients.verifyPw(name, sid, pw)
+	if err != nil || !status.IsOk() {
+		return status, err
+	}
+	_, err = clients.cdb.Delete(name)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed deleting client %v", name)
+		return status, err
+	}
+	clients.mu.Lock()
+	isBoot := clients.revoke(client)
+	gameServer := clients.gameServer
+	clients.mu.Unlock()
+	if isBoot {
+		clients.boot(client)
+	}
+	if gameServer != nil {
+		gameServer.DeletePlayer(client.ID)
+	}
+	return status, err
+}
+
+//Rename renames a logged in client. The client is logged out and
+//must log-in with the new name, a game in progress is paused and
+//saved and can be resumed after the log-in.
+func (clients *Clients) Rename(name, sid, pw, newName string) (status login.Status, err error) {
+	client, status, err := clients.verifyPw(name, sid, pw)
+	if err != nil || !status.IsOk() {
+		return status, err
+	}
+	if len(newName) < nameSIZE[0] || len(newName) > nameSIZE[1] || isBotName(newName) {
+		status = login.StatusAll.InValid
+		return status, err
+	}
+	clients.mu.Lock()
+	if _, isIn := clients.logIns[newName]; isIn {
+		clients.mu.Unlock()
+		status = login.StatusAll.Exist
+		return status, err
+	}
+	isUpd, err := clients.cdb.UpdName(name, newName)
+	if err != nil || !isUpd {
+		clients.mu.Unlock()
+		if err != nil {
+			err = errors.Wrapf(err, "Failed renaming client %v", name)
+		} else {
+			status = login.StatusAll.Exist
+		}
+		return status, err
+	}
+	isBoot := clients.revoke(client)
+	clients.mu.Unlock()
+	if isBoot {
+		clients.boot(client)
+	}
+	return status, err
+}
+
+//verifyPw verify the session id and password of a logged in client.
+//Bot clients do not have a password. The lock must not be held, only
+//the session is checked with the lock so the password check do not
+//block other clients.
+func (clients *Clients) verifyPw(name, sid, pw string) (client *Client, status login.Status, err error) {
+	clients.mu.RLock()
+	client, isIn := clients.logIns[name]
+	isSession := isIn && client.isSession(sid, clients.sessionExpiry)
+	clients.mu.RUnlock()
+	if !isSession {
+		status = login.StatusAll.InValid
+		return client, status, err
+	}
+	dbClient, isFound, err := clients.cdb.GetName(name)
+	if err != nil {
+		err = errors.Wrapf(err, "Failed loading client %v from database", name)
+		return client, status, err
+	}
+	if !isFound || len(dbClient.Pw) == 0 {
+		status = login.StatusAll.InValid
+		return client, status, err
+	}
+	cerr := bcrypt.CompareHashAndPassword(dbClient.Pw, []byte(pw))
+	if cerr == bcrypt.ErrMismatchedHashAndPassword {
+		status = login.StatusAll.InValid
+	} else if cerr != nil {
+		err = cerr
+	} else {
+		status = login.StatusAll.Ok
+	}
+	return client, status, err
+}
+
+//LogOutSession logs out a client with a valid session, the session
+//is revoked at once.
+func (clients *Clients) LogOutSession(name, sid string) (isFound bool) {
+	isBoot := false
+	clients.mu.Lock()
+	client, isIn := clients.logIns[name]
+	if isIn && client.isSession(sid, clients.sessionExpiry) {
+		isBoot = clients.revoke(client)
+		isFound = true
+	}
+	clients.mu.Unlock()
+	if isBoot {
+		clients.boot(client)
+	}
+	return isFound
+}
+
+//revoke revokes the session of a client. A client without a connection
+//is logged out, isBoot is true if the client have a connection and must
+//be booted with boot after the lock is released, it is logged out when
+//the connection closes.
+//The lock must be held.
+func (clients *Clients) revoke(client *Client) (isBoot bool) {
+	client.sid = ""
+	if client.conn != nil {
+		return true
+	}
+	delete(clients.logIns, client.Name)
+	return false
+}
+
+//sessionID creates a random session id.
+func sessionID() (txt string, err error) {
+	txt, err = randomHex(32)
+	if err != nil {
+		err = errors.Wrap(err, "Creating session id failed")
+	}
+	return txt, err
+}
+
+//randomHex creates a cryptographically random hex string of size bytes.
+func randomHex(size int) (txt string, err error) {
+	bs := make([]byte, size)
+	_, err = rand.Read(bs)
+	if err != nil {
+		return txt, err
+	}
+	txt = hex.EncodeToString(bs)
+	return txt, err
+}
+
+//newBotNameRE creates the server bot name regular expression, the match
+//is not case sensitive.
+func newBotNameRE() *regexp.Regexp {
+	strengths := make([]string, len(games.BotStrengthAll.All))
+	for i, strength := range games.BotStrengthAll.All {
+		strengths[i] = strength.String()
+	}
+	return regexp.MustCompile(fmt.Sprintf("(?i)^%v(%v)[0-9]+$", botNamePREFIX, strings.Join(strengths, "|")))
+}
+
+//isBotName checks if the name is a server bot name.
+func isBotName(name string) bool {
+	return botNameRE.MatchString(name)
 }
 
 // checkNamePwSize check client name and password information for size when
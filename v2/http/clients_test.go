@@ -1,9 +1,12 @@
 package http
 
 import (
+	"fmt"
+	"github.com/rezder/go-battleline/v2/http/config"
 	"github.com/rezder/go-battleline/v2/http/games"
 	"os"
 	"testing"
+	"time"
 )
 
 const (
@@ -11,12 +14,13 @@ const (
 )
 
 func TestClients(t *testing.T) {
-	dbClientsFILE = "_test/clients.db"
-	gameServer, err := games.New(7373, "")
+	cfg := config.Default()
+	cfg.DataDir = "_test"
+	gameServer, err := games.New(cfg)
 	if err != nil {
 		t.Fatalf("Init games server failed, with error: %v", err)
 	}
-	clients, err := NewClients(gameServer)
+	clients, err := NewClients(gameServer, cfg.ClientsDbFile(), time.Hour)
 	if err != nil {
 		_ = gameServer.Cancel()
 		t.Fatalf("Init clients failed, with error: %v", err)
@@ -30,21 +34,25 @@ func TestClients(t *testing.T) {
 	testCreateClientInValid(clients, logIns, t)
 	testVerifySid(clients, logIns, t)
 	testLogInOut(clients, logIns, t)
+	testBotLogIn(clients, t)
+	testBotNames(clients, t)
+	gameServer.Start(make(chan error, 10))
+	testAccount(clients, t)
+	testSession(clients, t)
 	//clients.LogOut(client.Name)
 
 	_ = clients.SetGameServer(nil)
 	testDown(clients, logIns, t)
-	err = gameServer.Cancel()
-	if err != nil {
-		t.Errorf("Cancel game server failed, with error: %v", err)
-	}
+	gameServer.Stop()
 	err = clients.Close()
 	if err != nil {
 		t.Errorf("Closed clients failed, with error: %v", err)
 	}
-	err = os.Remove(dbClientsFILE)
-	if err != nil {
-		t.Errorf("Remove file %v failed, with error: %v", dbClientsFILE, err)
+	for _, dbFile := range []string{cfg.ClientsDbFile(), cfg.SavedGamesDbFile()} {
+		err = os.Remove(dbFile)
+		if err != nil {
+			t.Errorf("Remove file %v failed, with error: %v", dbFile, err)
+		}
 	}
 }
 func testClientDis(clients *Clients, logIns []*Client, t *testing.T) {
@@ -74,6 +82,140 @@ func testClientDis(clients *Clients, logIns []*Client, t *testing.T) {
 		}
 	}
 }
+func testBotLogIn(clients *Clients, t *testing.T) {
+	client, token, err := NewBotClient("Bot")
+	if err != nil {
+		t.Fatalf("Creating bot client failed with error: %v", err)
+	}
+	_, _, err = clients.cdb.UpdInsert(client)
+	if err != nil {
+		t.Fatalf("Inserting bot client failed with error: %v", err)
+	}
+	status, _, err := clients.LogIn(client.Name, token)
+	if err != nil || !status.IsInValid() {
+		t.Errorf("Bot password login should fail with invalid got: %v, %v", status, err)
+	}
+	status, _, err = clients.LogInToken("Hans", testPW)
+	if err != nil || !status.IsInValid() {
+		t.Errorf("Player token login should fail with invalid got: %v, %v", status, err)
+	}
+	status, _, err = clients.LogInToken(client.Name, token+"0")
+	if err != nil || !status.IsInValid() {
+		t.Errorf("Bot login with wrong token should fail with invalid got: %v, %v", status, err)
+	}
+	status, _, err = clients.LogInToken(client.Name, token)
+	if err != nil || !status.IsOk() {
+		t.Errorf("Bot token login failed: %v, %v", status, err)
+	}
+	clients.LogOut(client.Name)
+}
+func testBotNames(clients *Clients, t *testing.T) {
+	name := fmt.Sprintf("Bot%v1", games.BotStrengthAll.Easy)
+	for _, botName := range []string{name, "botHARD99"} {
+		status, _, err := clients.AddNew(botName, testPW)
+		if err != nil || !status.IsInValid() {
+			t.Errorf("Creating client with bot name %v should fail got: %v, %v", botName, status, err)
+		}
+	}
+	for _, playerName := range []string{"Bottom", "Botond", "BotHans", "BotEasy"} {
+		if isBotName(playerName) {
+			t.Errorf("Name %v should not be a bot name", playerName)
+		}
+	}
+	client, err := NewClient(name, testPW)
+	if err != nil {
+		t.Fatalf("Creating client failed with error: %v", err)
+	}
+	if _, _, err = clients.cdb.UpdInsert(client); err != nil {
+		t.Fatalf("Inserting client failed with error: %v", err)
+	}
+	if err = clients.AddBots(1); err != nil {
+		t.Errorf("Adding server bots should skip a bot name used by a player account got: %v", err)
+	}
+	bot, _, err := clients.cdb.GetName(name)
+	if err != nil || bot.Role.IsBot() {
+		t.Errorf("The player account should not become a bot got: %v, %v", bot, err)
+	}
+}
+func testAccount(clients *Clients, t *testing.T) {
+	name := "Account"
+	newPw := "87654321"
+	status, sid, err := clients.AddNew(name, testPW)
+	if err != nil || !status.IsOk() {
+		t.Fatalf("Creating client failed: %v, %v", status, err)
+	}
+	status, err = clients.ChangePw(name, sid, "wrongpw1", newPw)
+	if err != nil || !status.IsInValid() {
+		t.Errorf("Change password with wrong password should fail got: %v, %v", status, err)
+	}
+	status, err = clients.ChangePw(name, sid, testPW, newPw)
+	if err != nil || !status.IsOk() {
+		t.Errorf("Change password failed: %v, %v", status, err)
+	}
+	status, err = clients.Rename(name, sid, newPw, "Hans")
+	if err != nil || !status.IsExist() {
+		t.Errorf("Rename to existing name should fail got: %v, %v", status, err)
+	}
+	status, err = clients.Rename(name, sid, newPw, "BotEasy7")
+	if err != nil || !status.IsInValid() {
+		t.Errorf("Rename to a bot name should fail got: %v, %v", status, err)
+	}
+	newName := "Account2"
+	status, err = clients.Rename(name, sid, newPw, newName)
+	if err != nil || !status.IsOk() {
+		t.Errorf("Rename failed: %v, %v", status, err)
+	}
+	if _, isIn := clients.logIns[name]; isIn {
+		t.Error("Renamed client should be logged out")
+	}
+	status, sid, err = clients.LogIn(newName, newPw)
+	if err != nil || !status.IsOk() {
+		t.Errorf("Log-in with new name and password failed: %v, %v", status, err)
+	}
+	status, err = clients.Delete(newName, sid, newPw)
+	if err != nil || !status.IsOk() {
+		t.Errorf("Delete failed: %v, %v", status, err)
+	}
+	status, _, err = clients.LogIn(newName, newPw)
+	if err != nil || !status.IsInValid() {
+		t.Errorf("Log-in of deleted client should fail got: %v, %v", status, err)
+	}
+	status, _, err = clients.AddNew(newName, testPW)
+	if err != nil || !status.IsOk() {
+		t.Errorf("Name of deleted client should be released got: %v, %v", status, err)
+	}
+	clients.LogOut(newName)
+}
+func testSession(clients *Clients, t *testing.T) {
+	clients.LogOut("Hans")
+	status, sid, err := clients.LogIn("Hans", testPW)
+	if err != nil || !status.IsOk() {
+		t.Fatalf("Log-in failed: %v, %v", status, err)
+	}
+	if len(sid) != 64 {
+		t.Errorf("Session id should be 32 random bytes got: %v", sid)
+	}
+	if clients.LogOutSession("Hans", "arg") {
+		t.Error("Log out with wrong session id should fail")
+	}
+	if !clients.LogOutSession("Hans", sid) {
+		t.Error("Log out failed")
+	}
+	ok, _ := clients.VerifySid("Hans", sid)
+	if ok {
+		t.Error("Revoked session should not verify")
+	}
+	expiry := clients.sessionExpiry
+	clients.sessionExpiry = time.Millisecond
+	_, sid, _ = clients.LogIn("Hans", testPW)
+	time.Sleep(time.Millisecond * 2)
+	ok, _ = clients.VerifySid("Hans", sid)
+	if ok {
+		t.Error("Expired session should not verify")
+	}
+	clients.sessionExpiry = expiry
+	clients.LogOut("Hans")
+}
 func testDown(clients *Clients, logIns []*Client, t *testing.T) {
 	for _, client := range logIns {
 		ok, isDown := clients.VerifySid(client.Name, client.sid)
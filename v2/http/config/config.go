@@ -0,0 +1,184 @@
+//Package config contains the battleline server configuration.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Config is the server configuration. The configuration is loaded from
+//a json file and the command line flags overrides the file.
+type Config struct {
+	DataDir       string   //The databases and generated certificates directory.
+	RootDir       string   //The http server files root directory.
+	Port          int      //The tcp port, 80 or 443 with tls.
+	BackupPort    int      //The backup server port, 0 no backup server.
+	MetricsPort   int      //The metrics server port, 0 no metrics server.
+	ArchPokePort  int      //The port the archivers poke when ready.
+	ArchAddr      string   //The archiver address if already running.
+	Bots          int      //The number of server bots of every strength.
+	Origins       []string //The allowed websocket origins besides the server host.
+	CertFile      string   //The tls certificate file, set to serve https.
+	KeyFile       string   //The tls key file.
+	SessionExpiry Duration //The log-in session life time, 0 no expiry.
+	DrainTimeout  Duration //The default admin drain timeout.
+	ConnLostGrace Duration //The time a table waits for a lost player.
+	ErrWindow     Duration //The sliding window player errors is counted in.
+	ErrLimit      int      //The protocol errors in the window before a player is disabled, 0 never disables.
+}
+
+//Default returns the default configuration.
+func Default() (cfg *Config) {
+	cfg = new(Config)
+	cfg.DataDir = filepath.Join("server", "data")
+	cfg.RootDir = filepath.Join("server", "htmlroot")
+	cfg.Port = 8282
+	cfg.ArchPokePort = 7373
+	cfg.Bots = 1
+	cfg.SessionExpiry = Duration(24 * time.Hour)
+	cfg.DrainTimeout = Duration(10 * time.Minute)
+	cfg.ConnLostGrace = Duration(2 * time.Minute)
+	cfg.ErrWindow = Duration(10 * time.Minute)
+	cfg.ErrLimit = 25
+	return cfg
+}
+
+//Load loads a configuration file, the fields missing in the file
+//keeps the default value.
+func Load(fileName string) (cfg *Config, err error) {
+	cfg = Default()
+	file, err := os.Open(fileName)
+	if err != nil {
+		return cfg, errors.Wrapf(err, "Open config file %v failed", fileName)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(cfg)
+	if err != nil {
+		err = errors.Wrapf(err, "Decode config file %v failed", fileName)
+	}
+	return cfg, err
+}
+
+//Flags adds the configuration flags to the flag set, the flags
+//updates the configuration when parsed.
+func (cfg *Config) Flags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.DataDir, "datadir", cfg.DataDir, "The data directory of the databases")
+	fs.StringVar(&cfg.RootDir, "rootdir", cfg.RootDir, "The server files root directory")
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "tcp port")
+	fs.IntVar(&cfg.BackupPort, "bckupport", cfg.BackupPort, "Backup port for backing up unfinished games and clientes, 0 no backup server")
+	fs.IntVar(&cfg.MetricsPort, "metricsport", cfg.MetricsPort, "Metrics port, the Prometheus metrics is served on /metrics, 0 no metrics server")
+	fs.IntVar(&cfg.ArchPokePort, "archpokeport", cfg.ArchPokePort, "Arciver poke tcp port, the archivers poke this port when ready")
+	fs.StringVar(&cfg.ArchAddr, "archaddr", cfg.ArchAddr, "Archiver address, if the archiver is allready running and ready")
+	fs.IntVar(&cfg.Bots, "bots", cfg.Bots, "Number of server bots of every strength")
+	fs.Var((*stringList)(&cfg.Origins), "origins", "Comma separated list of allowed websocket origins besides the server host ex.: https://game.rezder.com")
+	fs.StringVar(&cfg.CertFile, "certfile", cfg.CertFile, "The tls certificate file, when set the server serves https")
+	fs.StringVar(&cfg.KeyFile, "keyfile", cfg.KeyFile, "The tls key file")
+	fs.Var(&cfg.SessionExpiry, "sessionexpiry", "The log-in session expiry, 0 no expiry")
+	fs.Var(&cfg.DrainTimeout, "draintimeout", "The default drain timeout, 0 waits for all games")
+	fs.Var(&cfg.ConnLostGrace, "connlostgrace", "The time a player have to reconnect before the game is paused")
+	fs.Var(&cfg.ErrWindow, "errwindow", "The sliding window player errors is counted in")
+	fs.IntVar(&cfg.ErrLimit, "errlimit", cfg.ErrLimit, "The protocol errors in the window before a player is disabled, 0 never disables")
+}
+
+//ParseFlags parses the command line arguments. The configuration file
+//of the config flag is loaded first and the flags set overrides it.
+//The flag set may contain other flags than the configuration flags.
+func ParseFlags(fs *flag.FlagSet, args []string) (cfg *Config, err error) {
+	cfg = Default()
+	cfg.Flags(fs)
+	fileName := fs.String("config", "", "The json configuration file, flags overrides the file")
+	err = fs.Parse(args)
+	if err != nil || len(*fileName) == 0 {
+		return cfg, err
+	}
+	fileCfg, err := Load(*fileName)
+	if err != nil {
+		return cfg, err
+	}
+	fileFs := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	fileCfg.Flags(fileFs)
+	fs.Visit(func(f *flag.Flag) {
+		if fileFs.Lookup(f.Name) != nil && err == nil {
+			err = fileFs.Set(f.Name, f.Value.String())
+		}
+	})
+	return fileCfg, err
+}
+
+//DataFile returns the path of a file in the data directory.
+func (cfg *Config) DataFile(name string) string {
+	return filepath.Join(cfg.DataDir, name)
+}
+
+//ClientsDbFile returns the clients database file.
+func (cfg *Config) ClientsDbFile() string {
+	return cfg.DataFile("clients.db")
+}
+
+//SavedGamesDbFile returns the unfinished games database file.
+func (cfg *Config) SavedGamesDbFile() string {
+	return cfg.DataFile("savegames.db")
+}
+
+//Duration is a time duration written as text in the configuration
+//file ex.: "2m30s".
+type Duration time.Duration
+
+//D returns the time duration.
+func (d Duration) D() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+//Set sets the duration from text, implements flag.Value.
+func (d *Duration) Set(txt string) error {
+	v, err := time.ParseDuration(txt)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+//MarshalJSON marshals the duration as text.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+//UnmarshalJSON unmarshals the duration from text.
+func (d *Duration) UnmarshalJSON(bs []byte) error {
+	var txt string
+	if err := json.Unmarshal(bs, &txt); err != nil {
+		return errors.Wrap(err, "Duration must be a text ex.: \"2m30s\"")
+	}
+	return d.Set(txt)
+}
+
+//stringList is a comma separated flag list.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(txt string) error {
+	*l = nil
+	if len(txt) > 0 {
+		*l = strings.Split(txt, ",")
+	}
+	return nil
+}
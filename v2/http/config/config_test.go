@@ -0,0 +1,62 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battconfig")
+	if err != nil {
+		t.Fatalf("Create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "server.json")
+	txt := `{"DataDir":"/var/lib/batt","Port":9090,"SessionExpiry":"1h","Origins":["https://a.com"]}`
+	err = ioutil.WriteFile(fileName, []byte(txt), 0600)
+	if err != nil {
+		t.Fatalf("Write config file failed: %v", err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	logLevel := fs.Int("loglevel", 0, "Log level")
+	args := []string{"-config=" + fileName, "-port=8080", "-loglevel=2", "-connlostgrace=30s"}
+	cfg, err := ParseFlags(fs, args)
+	if err != nil {
+		t.Fatalf("Parse flags failed: %v", err)
+	}
+	if cfg.Port != 8080 {
+		t.Errorf("Flag should override file port got: %v", cfg.Port)
+	}
+	if cfg.DataDir != "/var/lib/batt" {
+		t.Errorf("Data dir should be from file got: %v", cfg.DataDir)
+	}
+	if cfg.SessionExpiry.D() != time.Hour {
+		t.Errorf("Session expiry should be from file got: %v", cfg.SessionExpiry)
+	}
+	if cfg.ConnLostGrace.D() != 30*time.Second {
+		t.Errorf("Grace should be from flag got: %v", cfg.ConnLostGrace)
+	}
+	if len(cfg.Origins) != 1 || cfg.Origins[0] != "https://a.com" {
+		t.Errorf("Origins should be from file got: %v", cfg.Origins)
+	}
+	if cfg.ErrLimit != Default().ErrLimit {
+		t.Errorf("Missing fields should be default got: %v", cfg.ErrLimit)
+	}
+	if *logLevel != 2 {
+		t.Errorf("Other flags should be parsed got: %v", *logLevel)
+	}
+	if cfg.SavedGamesDbFile() != filepath.Join("/var/lib/batt", "savegames.db") {
+		t.Errorf("Wrong saved games file: %v", cfg.SavedGamesDbFile())
+	}
+	err = ioutil.WriteFile(fileName, []byte(`{"Prot":9090}`), 0600)
+	if err != nil {
+		t.Fatalf("Write config file failed: %v", err)
+	}
+	if _, err = Load(fileName); err == nil {
+		t.Error("Unknown fields should fail")
+	}
+}
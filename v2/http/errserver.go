@@ -1,7 +1,12 @@
 package http
 
 import (
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/http/games"
 	"github.com/rezder/go-error/log"
+	"sort"
+	"sync"
+	"time"
 )
 
 // ErrServer handles while the servers are running.
@@ -12,14 +17,17 @@ type ErrServer struct {
 	clients *Clients
 	ch      chan error
 	finCh   chan struct{}
+	counter *errCounter
 }
 
-//NewErrServer creates a new error server
-func NewErrServer(clients *Clients) (e *ErrServer) {
+//NewErrServer creates a new error server, a player with errLimit
+//protocol errors in the errWindow is disabled.
+func NewErrServer(clients *Clients, errLimit int, errWindow time.Duration) (e *ErrServer) {
 	e = new(ErrServer)
 	e.ch = make(chan error, 10)
 	e.clients = clients
 	e.finCh = make(chan struct{})
+	e.counter = newErrCounter(errLimit, errWindow)
 	return e
 }
 
@@ -30,7 +38,7 @@ func (e *ErrServer) Ch() chan<- error {
 
 //Start starts the server.
 func (e *ErrServer) Start() {
-	go errServe(e.ch, e.finCh)
+	go errServe(e.ch, e.finCh, e.counter, e.clients)
 }
 
 //Stop stopd the server.
@@ -39,18 +47,158 @@ func (e *ErrServer) Stop() {
 	<-e.finCh
 }
 
+//PlayerErrs returns the player error counters.
+func (e *ErrServer) PlayerErrs() []*PlayerErrData {
+	return e.counter.data()
+}
+
+//ResetPlayer resets the error counter of a player, used when
+//a player is enabled.
+func (e *ErrServer) ResetPlayer(playerID int) {
+	e.counter.reset(playerID)
+}
+
 //errServe runs a error server.
 //all errors should be send where the power to close down exist.
-//Currently it does nothing but log the errors.
-func errServe(errChan chan error, finCh chan struct{}) {
-	// TODO Add error count on player id and auto disable player with to many errors.
+//All errors is logged and player errors is counted, a player with
+//to many protocol errors is disabled.
+func errServe(errChan chan error, finCh chan struct{}, counter *errCounter, clients *Clients) {
 	for {
 		err, open := <-errChan
 		if open {
 			log.PrintErr(err)
+			if playerErr, ok := errors.Cause(err).(*games.PlayerErr); ok {
+				if counter.add(playerErr, time.Now()) {
+					log.Printf(log.Min, "Disable player id: %v because of to many errors", playerErr.PlayerID)
+					go func(id int) { //Disable boots the player which may produce errors.
+						if disErr := clients.UpdateDisable(id, true); disErr != nil {
+							log.PrintErr(disErr)
+						}
+					}(playerErr.PlayerID)
+				}
+			}
 		} else {
 			close(finCh)
 			break
 		}
 	}
 }
+
+//errCounter counts player errors in a sliding window.
+type errCounter struct {
+	mu      *sync.Mutex
+	players map[int]*playerErrCount
+	//limit the number of protocol errors in the window
+	//before a player is disabled, 0 never disables.
+	limit  int
+	window time.Duration
+}
+
+//playerErrCount the error times of a player per error kind.
+type playerErrCount struct {
+	times      map[games.PlayerErrKind][]time.Time
+	isDisabled bool
+}
+
+func newErrCounter(limit int, window time.Duration) (c *errCounter) {
+	c = new(errCounter)
+	c.limit = limit
+	c.window = window
+	c.mu = new(sync.Mutex)
+	c.players = make(map[int]*playerErrCount)
+	return c
+}
+
+//add adds a player error. isDisable is true when the protocol
+//errors crosses the limit, it is only true ones per player and never
+//if the limit is 0.
+func (c *errCounter) add(playerErr *games.PlayerErr, now time.Time) (isDisable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count, isFound := c.players[playerErr.PlayerID]
+	if !isFound {
+		count = &playerErrCount{times: make(map[games.PlayerErrKind][]time.Time)}
+		c.players[playerErr.PlayerID] = count
+	}
+	count.times[playerErr.Kind] = append(slideWindow(count.times[playerErr.Kind], now, c.window), now)
+	if c.limit > 0 && playerErr.Kind.IsProtocol() && !count.isDisabled &&
+		len(count.times[playerErr.Kind]) >= c.limit {
+		count.isDisabled = true
+		isDisable = true
+	}
+	c.prune(now)
+	return isDisable
+}
+
+//reset removes the errors of a player.
+func (c *errCounter) reset(playerID int) {
+	c.mu.Lock()
+	delete(c.players, playerID)
+	c.mu.Unlock()
+}
+
+//prune removes players without errors in the window that is not disabled.
+func (c *errCounter) prune(now time.Time) {
+	for id, count := range c.players {
+		if count.isDisabled {
+			continue
+		}
+		isEmpty := true
+		for kind, times := range count.times {
+			times = slideWindow(times, now, c.window)
+			count.times[kind] = times
+			if len(times) > 0 {
+				isEmpty = false
+			}
+		}
+		if isEmpty {
+			delete(c.players, id)
+		}
+	}
+}
+
+//slideWindow removes the times outside the window.
+func slideWindow(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	ix := 0
+	for ix < len(times) && now.Sub(times[ix]) > window {
+		ix++
+	}
+	return times[ix:]
+}
+
+//PlayerErrData is the admin information of a player error counter.
+//The counts is the number of errors in the window per error kind.
+type PlayerErrData struct {
+	PlayerID   int
+	Protocol   int
+	Server     int
+	LastErr    time.Time
+	IsDisabled bool //Disabled by the error server.
+}
+
+//data returns the player error counters.
+func (c *errCounter) data() (players []*PlayerErrData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	players = make([]*PlayerErrData, 0, len(c.players))
+	for id, count := range c.players {
+		d := &PlayerErrData{PlayerID: id, IsDisabled: count.isDisabled}
+		for kind, times := range count.times {
+			times = slideWindow(times, now, c.window)
+			if kind.IsProtocol() {
+				d.Protocol = len(times)
+			} else {
+				d.Server = len(times)
+			}
+			if len(times) > 0 && times[len(times)-1].After(d.LastErr) {
+				d.LastErr = times[len(times)-1]
+			}
+		}
+		players = append(players, d)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].LastErr.After(players[j].LastErr)
+	})
+	return players
+}
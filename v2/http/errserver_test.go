@@ -0,0 +1,55 @@
+package http
+
+import (
+	"github.com/rezder/go-battleline/v2/http/games"
+	"testing"
+	"time"
+)
+
+func TestErrCounter(t *testing.T) {
+	limit := 25
+	window := 10 * time.Minute
+	counter := newErrCounter(limit, window)
+	protocolErr := games.NewPlayerErr("Illegal move", 3).(*games.PlayerErr)
+	serverErr := games.NewPlayerServerErr("Websocket send", 3).(*games.PlayerErr)
+	start := time.Now()
+	for i := 0; i < limit*2; i++ {
+		if counter.add(serverErr, start) {
+			t.Fatal("Server errors should not disable a player")
+		}
+	}
+	for i := 0; i < limit-1; i++ {
+		if counter.add(protocolErr, start) {
+			t.Fatalf("Player disabled after %v errors", i+1)
+		}
+	}
+	ts := start.Add(window + time.Second)
+	if counter.add(protocolErr, ts) {
+		t.Error("Errors outside the window should not be counted")
+	}
+	for i := 0; i < limit-2; i++ {
+		if counter.add(protocolErr, ts) {
+			t.Fatalf("Player disabled after %v errors", i+2)
+		}
+	}
+	if !counter.add(protocolErr, ts) {
+		t.Error("Player should be disabled")
+	}
+	if counter.add(protocolErr, ts) {
+		t.Error("Player should only be disabled once")
+	}
+	data := counter.data()
+	if len(data) != 1 || !data[0].IsDisabled {
+		t.Errorf("Expected one disabled player got: %v", data)
+	}
+	counter.reset(3)
+	if len(counter.data()) != 0 {
+		t.Error("Reset failed")
+	}
+	counter = newErrCounter(0, window)
+	for i := 0; i < limit; i++ {
+		if counter.add(protocolErr, start) {
+			t.Fatal("A zero limit should never disable a player")
+		}
+	}
+}
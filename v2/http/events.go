@@ -0,0 +1,90 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/http/games"
+	"github.com/rezder/go-error/log"
+)
+
+const (
+	//eventsPING the interval of the keep alive comments of a idle
+	//event stream.
+	eventsPING = 25 * time.Second
+)
+
+//eventsHandler serves the public game events as a server-sent event
+//stream. A client that reconnects with the Last-Event-ID header receives
+//the events it missed.
+// ex: curl -N http://localhost:8282/events
+type eventsHandler struct {
+	clients *Clients
+	errCh   chan<- error
+}
+
+func (handler *eventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	gameServer := handler.clients.GameServer()
+	if gameServer == nil {
+		http.Error(w, "Game server down", http.StatusServiceUnavailable)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	eventLog := gameServer.Events()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	lastID := r.Header.Get("Last-Event-ID")
+	pingTicker := time.NewTicker(eventsPING)
+	defer pingTicker.Stop()
+	for {
+		events, nextID, newCh, isClosed := eventLog.Since(lastID)
+		lastID = nextID
+		for _, event := range events {
+			if err := writeEvent(w, event); err != nil {
+				log.Printf(log.DebugMsg, "Event stream closed: %v", err)
+				return
+			}
+		}
+		if len(events) != 0 {
+			flusher.Flush()
+		}
+		if isClosed {
+			return
+		}
+		select {
+		case <-newCh:
+		case <-pingTicker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-eventLog.CloseCh():
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+//writeEvent writes a server-sent event.
+func writeEvent(w http.ResponseWriter, event *games.Event) (err error) {
+	js, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "Marshal event")
+	}
+	_, err = fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", event.ID, event.Type, js)
+	return err
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rezder/go-battleline/v2/http/config"
+	"github.com/rezder/go-battleline/v2/http/games"
+)
+
+func TestEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battserver")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	cfg := config.Default()
+	cfg.DataDir = dir
+	cfg.RootDir = dir
+	cfg.Port = 0
+	cfg.ArchPokePort = 0
+	server, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Creating server failed: %v", err)
+	}
+	server.Start()
+	defer server.Stop()
+	req, err := http.NewRequest(http.MethodGet, "http://"+server.Addr().String()+"/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Last-Event-ID", "old-1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Events request failed: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected event stream got: %v", contentType)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	var id, eventType string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			var event games.Event
+			if err = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("Unmarshal event failed: %v", err)
+			}
+			if event.ID != id || event.Type != eventType || event.Type != games.EventJoin || event.Player == nil || !event.Player.IsBot {
+				t.Errorf("Expected the join event of the server bot got: %v, %v, %+v", id, eventType, event)
+			}
+			return
+		}
+	}
+	t.Errorf("Event stream ended: %v", scanner.Err())
+}
@@ -1,12 +1,35 @@
 package games
 
+import (
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-battleline/v2/game/card"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+	"time"
+)
+
+var (
+	//watchDelayMAX the maximum spectator broadcast delay.
+	watchDelayMAX = 10 * time.Minute
+)
+
 // benchServe serve a bench.
 // Handle all things related with watching the game. Adding and removing watchers and
-// relaying the game information.
-func benchServe(joinWatchChCl *JoinWatchChCl, watchingCh <-chan *WatchingChData) {
+// relaying the game information. Chat messages are relayed but new watchers only
+// receive the last game view with the history of the game as catch-up.
+// The game information and the players chat is relayed with the delay, the
+// spectators chat is relayed at once. When the game finish the watchers
+// receive the rest of the delayed information before the watchers is closed.
+// The number of watchers is kept in the table stats and the public game
+// events is added to the events log when relayed, the start event with the
+// first game view.
+func benchServe(joinWatchChCl *JoinWatchChCl, watchingCh <-chan *WatchingChData, delay time.Duration, isResume bool, stats *tableStats, events *EventLog) {
 	watchers := make(map[int]chan<- *WatchingChData)
 	var watchingChData *WatchingChData
-	var isOpen bool
+	hist := new(WatchHist)
+	var delayed []*benchDelayed
+	var delayTimer *time.Timer
+	var delayCh <-chan time.Time
+	isClosing := false
 Loop:
 	for {
 		select {
@@ -15,31 +38,150 @@ Loop:
 			isDelete := p.SendCh == nil
 			if isFound && isDelete {
 				delete(watchers, p.ID)
+				stats.addWatchers(-1)
 			} else if !isFound && !isDelete {
 				watchers[p.ID] = p.SendCh
+				stats.addWatchers(1)
 				if watchingChData != nil {
-					p.SendCh <- watchingChData
+					p.SendCh <- watchingChData.catchUp(hist)
 				}
 			} else { //if the player try to join twice
 				close(p.SendCh)
 			}
 
-		case watchingChData, isOpen = <-watchingCh:
+		case data, isOpen := <-watchingCh:
 			if !isOpen {
 				close(joinWatchChCl.Close) //stop join and leave
-				if len(watchers) > 0 {
-					for _, ch := range watchers {
-						close(ch)
-					}
+				if len(delayed) == 0 {
+					break Loop
+				}
+				watchingCh = nil
+				isClosing = true
+			} else if delay == 0 || data.Chat != nil && data.Chat.IsSpectator {
+				watchingChData = benchRelay(data, watchers, hist, watchingChData, isResume, events)
+			} else {
+				delayed = append(delayed, &benchDelayed{ts: time.Now().Add(delay), data: data})
+				if delayTimer == nil {
+					delayTimer = time.NewTimer(delay)
+					delayCh = delayTimer.C
 				}
-				break Loop
+			}
+		case <-delayCh:
+			now := time.Now()
+			for len(delayed) > 0 && !delayed[0].ts.After(now) {
+				watchingChData = benchRelay(delayed[0].data, watchers, hist, watchingChData, isResume, events)
+				delayed = delayed[1:]
+			}
+			if len(delayed) > 0 {
+				delayTimer.Reset(delayed[0].ts.Sub(now))
 			} else {
-				if len(watchers) > 0 {
-					for _, ch := range watchers {
-						ch <- watchingChData
-					}
+				delayTimer = nil
+				delayCh = nil
+				if isClosing {
+					break Loop
 				}
 			}
 		} //select
 	} //for
+	if len(watchers) > 0 {
+		for _, ch := range watchers {
+			close(ch)
+		}
+		stats.addWatchers(-len(watchers))
+	}
+}
+
+//benchRelay relays the game information to the watchers and updates
+//the history and the events. The last game view is returned.
+func benchRelay(
+	data *WatchingChData,
+	watchers map[int]chan<- *WatchingChData,
+	hist *WatchHist,
+	lastData *WatchingChData,
+	isResume bool,
+	events *EventLog) (watchingChData *WatchingChData) {
+
+	watchingChData = lastData
+	if data.histAdd != nil {
+		hist.Moves = append(hist.Moves, data.histAdd.Moves...)
+		hist.Chat = append(hist.Chat, data.histAdd.Chat...)
+	}
+	if data.Chat == nil {
+		if lastData == nil {
+			events.addStart(data.PlayingIDs, data.GameTs, isResume)
+		}
+		events.addView(data, lastData)
+		watchingChData = data
+	} else {
+		hist.Chat = append(hist.Chat, data.Chat)
+	}
+	if len(watchers) > 0 {
+		for _, ch := range watchers {
+			ch <- data
+		}
+	}
+	return watchingChData
+}
+
+//benchDelayed is game information waiting to be relayed.
+type benchDelayed struct {
+	ts   time.Time
+	data *WatchingChData
+}
+
+//WatchHist is the spectator history of a game, used by late watchers
+//to catch up. The moves is the game history moves where the cards moved
+//between the deck and a hand is hidden.
+type WatchHist struct {
+	Moves []*bg.Move
+	Chat  []*bg.ChatMess
+}
+
+//newWatchHist creates the spectator history of a game history.
+func newWatchHist(hist *bg.Hist) (w *WatchHist) {
+	w = new(WatchHist)
+	w.Moves = make([]*bg.Move, len(hist.Moves))
+	for i, move := range hist.Moves {
+		w.Moves[i] = spectatorMove(move)
+	}
+	w.Chat = make([]*bg.ChatMess, len(hist.Chat))
+	copy(w.Chat, hist.Chat)
+	return w
+}
+
+//spectatorMove copies a move, the cards moved between the deck and a
+//hand is replaced with the back of the card.
+func spectatorMove(move *bg.Move) (sMove *bg.Move) {
+	sMove = bg.NewMove(move.Mover, move.MoveType)
+	if move.Moves != nil {
+		sMove.Moves = make([]*bg.BoardPieceMove, len(move.Moves))
+	}
+	for i, bpMove := range move.Moves {
+		sbpMove := *bpMove
+		oldPos, newPos := dpos.Card(bpMove.OldPos), dpos.Card(bpMove.NewPos)
+		cardMove := card.Card(bpMove.Index)
+		if bpMove.IsCard() && !cardMove.IsBack() &&
+			(oldPos.IsInDeck() && newPos.IsOnHand() || oldPos.IsOnHand() && newPos.IsInDeck()) {
+			if cardMove.IsTac() {
+				sbpMove.Index = card.BACKTac
+			} else {
+				sbpMove.Index = card.BACKTroop
+			}
+		}
+		sMove.Moves[i] = &sbpMove
+	}
+	return sMove
+}
+
+//catchUp copies the watching data and adds a copy of the history.
+func (w *WatchingChData) catchUp(hist *WatchHist) (c *WatchingChData) {
+	cv := *w
+	c = &cv
+	c.Hist = &WatchHist{
+		Moves: make([]*bg.Move, len(hist.Moves)),
+		Chat:  make([]*bg.ChatMess, len(hist.Chat)),
+	}
+	copy(c.Hist.Moves, hist.Moves)
+	copy(c.Hist.Chat, hist.Chat)
+	return c
 }
@@ -0,0 +1,109 @@
+package games
+
+import (
+	"testing"
+	"time"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-battleline/v2/game/card"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+)
+
+func TestBenchDelayCatchUp(t *testing.T) {
+	delay := 50 * time.Millisecond
+	joinWatchChCl := NewJoinWatchChCl()
+	benchCh := make(chan *WatchingChData, 1)
+	go benchServe(joinWatchChCl, benchCh, delay, false, newTableStats(), nil)
+	watchChA := make(chan *WatchingChData, 10)
+	joinWatchChCl.Channel <- &JoinWatchChData{ID: 1, SendCh: watchChA}
+
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	benchCh <- &WatchingChData{ViewPos: bg.NewViewPos(game.Pos, bg.ViewAll.Spectator, dpos.NoPlayer), histAdd: newWatchHist(game.Hist)}
+	select {
+	case <-watchChA:
+		t.Error("Watcher received the game without delay")
+	default:
+	}
+	select {
+	case data := <-watchChA:
+		if data.ViewPos == nil {
+			t.Error("Watcher should receive the first view")
+		}
+	case <-time.After(10 * delay):
+		t.Fatal("Watcher did not receive the delayed view")
+	}
+	game.Move(game.Pos.CalcMoves()[0])
+	benchCh <- &WatchingChData{ViewPos: bg.NewViewPos(game.Pos, bg.ViewAll.Spectator, dpos.NoPlayer),
+		histAdd: &WatchHist{Moves: []*bg.Move{spectatorMove(game.Hist.LastMove())}}}
+	benchCh <- &WatchingChData{Chat: &bg.ChatMess{SenderID: 3, Message: "Hello", IsSpectator: true}}
+	select {
+	case data := <-watchChA:
+		if data.Chat == nil {
+			t.Error("Spectator chat should be relayed before the delayed view")
+		}
+	case <-time.After(delay / 2):
+		t.Error("Spectator chat should be relayed without delay")
+	}
+	<-watchChA
+	watchChB := make(chan *WatchingChData, 10)
+	joinWatchChCl.Channel <- &JoinWatchChData{ID: 2, SendCh: watchChB}
+	data := <-watchChB
+	if data.Hist == nil || len(data.Hist.Moves) != 2 || len(data.Hist.Chat) != 1 {
+		t.Errorf("Late watcher catch-up failed got: %v", data.Hist)
+	}
+	benchCh <- &WatchingChData{ViewPos: bg.NewViewPos(game.Pos, bg.ViewAll.Spectator, dpos.NoPlayer)}
+	close(benchCh)
+	select {
+	case <-watchChA:
+	case <-time.After(10 * delay):
+		t.Error("Watcher did not receive the delayed view after the game finished")
+	}
+	if _, open := <-watchChA; open {
+		t.Error("Watcher channel should be closed")
+	}
+}
+
+func TestBenchSpectatorMove(t *testing.T) {
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	for i := 0; i < 10 && game.Hist.LastMove().MoveType != bg.MoveTypeAll.Deck; i++ {
+		game.Move(game.Pos.CalcMoves()[0])
+	}
+	move := game.Hist.LastMove()
+	if move.MoveType != bg.MoveTypeAll.Deck {
+		t.Fatalf("Expected a deck move got: %v", move)
+	}
+	sMove := spectatorMove(move)
+	if !card.Card(sMove.Moves[0].Index).IsBack() || card.Card(move.Moves[0].Index).IsBack() {
+		t.Errorf("The drawn card should be hidden only for spectators got: %v, %v", sMove, move)
+	}
+	if w := newWatchHist(game.Hist); len(w.Moves) != len(game.Hist.Moves) || !w.Moves[1].IsEqual(game.Hist.Moves[1]) {
+		t.Errorf("Spectator history should keep the public moves got: %v", w.Moves)
+	}
+}
+
+func TestBenchDelayStartEvent(t *testing.T) {
+	delay := 50 * time.Millisecond
+	events := NewEventLog()
+	benchCh := make(chan *WatchingChData, 1)
+	go benchServe(NewJoinWatchChCl(), benchCh, delay, true, newTableStats(), events)
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	benchCh <- &WatchingChData{ViewPos: bg.NewViewPos(game.Pos, bg.ViewAll.Spectator, dpos.NoPlayer), PlayingIDs: [2]int{1, 2}, GameTs: game.Hist.Time}
+	_, nextID, newCh, _ := events.Since("")
+	time.Sleep(delay / 5)
+	if startEvents, _, _, _ := events.Since(nextID); len(startEvents) != 0 {
+		t.Errorf("The start event should be delayed got: %v", startEvents[0])
+	}
+	select {
+	case <-newCh:
+	case <-time.After(10 * delay):
+		t.Fatal("The start event was never added")
+	}
+	startEvents, _, _, _ := events.Since(nextID)
+	if len(startEvents) != 1 || startEvents[0].Type != EventStart || !startEvents[0].Game.IsResume {
+		t.Errorf("Expected one resumed start event got: %v", startEvents)
+	}
+	close(benchCh)
+}
@@ -0,0 +1,63 @@
+package games
+
+import (
+	"golang.org/x/net/websocket"
+	"time"
+)
+
+//Conn is a player connection. The player server sends and receives
+//through the connection so the transport can be a websocket or
+//http long-poll.
+type Conn interface {
+	//Send sends a message to the client.
+	Send(data *JsonData) error
+	//Receive receives a action from the client, it blocks until a
+	//action is received or the deadline. io.EOF is returned when the
+	//client closed the connection.
+	Receive(act *Action, deadline time.Time) error
+	//Close closes the connection.
+	Close() error
+}
+
+//BinaryConn is a connection that supports the binary wire encoding.
+//The actions is always accepted in both encodings.
+type BinaryConn interface {
+	Conn
+	//SetBinary switches the messages to the binary encoding.
+	SetBinary(isBinary bool)
+}
+
+//wsConn is a websocket player connection.
+type wsConn struct {
+	ws       *websocket.Conn
+	isBinary bool
+}
+
+//NewWsConn creates a player connection from a websocket.
+func NewWsConn(ws *websocket.Conn) Conn {
+	return &wsConn{ws: ws}
+}
+
+func (c *wsConn) Send(data *JsonData) error {
+	if c.isBinary {
+		return WireCodec.Send(c.ws, data)
+	}
+	return websocket.JSON.Send(c.ws, data)
+}
+
+//SetBinary is only called by the writer.
+func (c *wsConn) SetBinary(isBinary bool) {
+	c.isBinary = isBinary
+}
+
+func (c *wsConn) Receive(act *Action, deadline time.Time) (err error) {
+	err = c.ws.SetReadDeadline(deadline)
+	if err == nil {
+		err = WireCodec.Receive(c.ws, act)
+	}
+	return err
+}
+
+func (c *wsConn) Close() error {
+	return c.ws.Close()
+}
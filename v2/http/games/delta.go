@@ -0,0 +1,115 @@
+package games
+
+import (
+	bg "github.com/rezder/go-battleline/v2/game"
+	"time"
+)
+
+const (
+	//deltaSnapshotINTERVAL the number of deltas between full snapshots.
+	deltaSnapshotINTERVAL = 20
+)
+
+//deltaResync is a client request to resend the full view position
+//of a game stream, the value is the watched player id or zero for
+//the players own game.
+type deltaResync int
+
+//deltaStream is the state of the view positions send to a client for
+//a game, the players own or a watched game.
+type deltaStream struct {
+	seq      int64
+	last     *bg.ViewPos
+	gameTs   time.Time
+	noDeltas int
+	lastData interface{}
+}
+
+//next moves the stream to the view position, delta is nil when a
+//full snapshot must be send.
+func (s *deltaStream) next(viewPos *bg.ViewPos, gameTs time.Time, isSnapshot bool,
+	data interface{}) (seq int64, delta *bg.ViewPosDelta) {
+	s.seq++
+	if isSnapshot || s.last == nil || !gameTs.Equal(s.gameTs) ||
+		s.last.View != viewPos.View || s.noDeltas >= deltaSnapshotINTERVAL {
+		s.noDeltas = 0
+	} else {
+		delta = bg.NewViewPosDelta(s.last, viewPos)
+		s.noDeltas++
+	}
+	s.last = viewPos
+	s.gameTs = gameTs
+	s.lastData = data
+	return s.seq, delta
+}
+
+//deltaEncoder replaces the full view positions of the playing and watching
+//messages with deltas, for clients with the delta capability.
+type deltaEncoder struct {
+	playing  *deltaStream
+	watching map[int]*deltaStream
+}
+
+func newDeltaEncoder() (e *deltaEncoder) {
+	e = new(deltaEncoder)
+	e.playing = new(deltaStream)
+	e.watching = make(map[int]*deltaStream)
+	return e
+}
+
+//stream returns the game stream, zero is the players own game.
+func (e *deltaEncoder) stream(watchID int) (s *deltaStream) {
+	if watchID == 0 {
+		return e.playing
+	}
+	s, isFound := e.watching[watchID]
+	if !isFound {
+		s = new(deltaStream)
+		e.watching[watchID] = s
+	}
+	return s
+}
+
+//resync returns the last data of the stream and makes the next
+//message a snapshot. nil is returned if nothing was send.
+func (e *deltaEncoder) resync(watchID int) (data interface{}) {
+	s := e.stream(watchID)
+	s.last = nil
+	return s.lastData
+}
+
+//encode adds the sequence number to the playing and watching
+//messages and replaces the view position with a delta when possible.
+//The data is copied as it may be shared.
+func (e *deltaEncoder) encode(jdata *JsonData) *JsonData {
+	switch data := jdata.Data.(type) {
+	case *PlayingChData:
+		if data.ViewPos == nil {
+			return jdata
+		}
+		seq, delta := e.playing.next(data.ViewPos, data.GameTs, false, data)
+		playingData := *data
+		playingData.Seq = seq
+		if delta == nil {
+			return &JsonData{JsonType: JTPlaying, Data: &playingData}
+		}
+		playingData.ViewPos = nil
+		playingData.Delta = delta
+		return &JsonData{JsonType: JTPlayingDelta, Data: &playingData}
+	case *WatchingChData:
+		if data.ViewPos == nil {
+			return jdata
+		}
+		s := e.stream(data.WatchingID)
+		seq, delta := s.next(data.ViewPos, data.GameTs, data.Hist != nil, data)
+		watchingData := *data
+		watchingData.Seq = seq
+		if delta == nil {
+			return &JsonData{JsonType: JTWatching, Data: &watchingData}
+		}
+		watchingData.ViewPos = nil
+		watchingData.Delta = delta
+		return &JsonData{JsonType: JTWatchingDelta, Data: &watchingData}
+	}
+	return jdata
+}
@@ -0,0 +1,61 @@
+package games
+
+import (
+	"testing"
+	"time"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+)
+
+func TestDeltaEncoder(t *testing.T) {
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	gameTs := time.Now()
+	messages := []interface{}{newClientProtocol(ProtocolVERSION, []string{CapDelta})}
+	viewPoss := make([]*bg.ViewPos, 0, 4)
+	for i := 0; i < 3; i++ {
+		viewPos := bg.NewViewPos(game.Pos, bg.ViewAll.Players[0], dpos.NoPlayer)
+		viewPoss = append(viewPoss, viewPos)
+		messages = append(messages, &PlayingChData{ViewPos: viewPos, GameTs: gameTs})
+		game.Move(game.Pos.CalcMoves()[0])
+	}
+	messages = append(messages, deltaResync(0), deltaResync(5))
+	sent := runNetWrite(messages...)
+	if len(sent) != 5 {
+		t.Fatalf("Expected 3 playing, a resync and close got: %v", sent)
+	}
+	expTypes := []int{JTPlaying, JTPlayingDelta, JTPlayingDelta, JTPlaying}
+	var viewPos *bg.ViewPos
+	for i, expType := range expTypes {
+		data := sent[i].Data.(*PlayingChData)
+		if sent[i].JsonType != expType || data.Seq != int64(i+1) {
+			t.Fatalf("Message %v expected type %v got: %v seq: %v", i, expType, sent[i].JsonType, data.Seq)
+		}
+		if expType == JTPlaying {
+			viewPos = data.ViewPos
+		} else {
+			viewPos = viewPos.ApplyDelta(data.Delta)
+		}
+		expViewPos := viewPoss[len(viewPoss)-1] //The resync resends the last.
+		if i < len(viewPoss) {
+			expViewPos = viewPoss[i]
+		}
+		if !viewPos.IsEqual(expViewPos) {
+			t.Errorf("Message %v view position differs", i)
+		}
+	}
+	if data := messages[1].(*PlayingChData); data.Seq != 0 || data.ViewPos == nil {
+		t.Error("Send data should not be changed")
+	}
+}
+
+func TestDeltaLegacyClient(t *testing.T) {
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	viewPos := bg.NewViewPos(game.Pos, bg.ViewAll.Players[0], dpos.NoPlayer)
+	sent := runNetWrite(&PlayingChData{ViewPos: viewPos}, &PlayingChData{ViewPos: viewPos}, deltaResync(0))
+	if len(sent) != 3 || sent[1].JsonType != JTPlaying || sent[1].Data.(*PlayingChData).Seq != 0 {
+		t.Errorf("Legacy clients should receive full view positions got: %v", sent)
+	}
+}
@@ -0,0 +1,60 @@
+package games
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	//PlayerErrKindAll is the player error kind domain.
+	PlayerErrKindAll PlayerErrKindAllST
+)
+
+func init() {
+	PlayerErrKindAll = newPlayerErrKindAllST()
+}
+
+//PlayerErrKindAllST is the player error kind singleton.
+type PlayerErrKindAllST struct {
+	Protocol PlayerErrKind
+	Server   PlayerErrKind
+	All      []PlayerErrKind
+}
+
+func newPlayerErrKindAllST() (p PlayerErrKindAllST) {
+	p.Protocol = 0
+	p.Server = 1
+	p.All = []PlayerErrKind{p.Protocol, p.Server}
+	return p
+}
+
+//PlayerErrKind the player error kind domain value.
+//Protocol errors is caused by the player sending invalid actions,
+//server errors is caused by the server or the connection.
+type PlayerErrKind int
+
+func (p PlayerErrKind) String() (txt string) {
+	switch p {
+	case PlayerErrKindAll.Protocol:
+		txt = "Protocol"
+	case PlayerErrKindAll.Server:
+		txt = "Server"
+	default:
+		panic(fmt.Sprintf("Player error kind: %v does not exist ", int(p)))
+	}
+	return txt
+}
+
+//IsProtocol returns true if the error is caused by the player.
+func (p PlayerErrKind) IsProtocol() bool {
+	return p == PlayerErrKindAll.Protocol
+}
+
+//isJSONErr checks if a receive error is caused by invalid json.
+func isJSONErr(err error) bool {
+	switch err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package games
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+)
+
+const (
+	//eventLogSIZE the number of events kept for resuming clients.
+	eventLogSIZE = 1000
+
+	//EventJoin a player joined the server.
+	EventJoin = "join"
+	//EventLeave a player left the server.
+	EventLeave = "leave"
+	//EventStart a game started or resumed.
+	EventStart = "start"
+	//EventMove a move was made.
+	EventMove = "move"
+	//EventClaim flags was won by a claim.
+	EventClaim = "claim"
+	//EventWin a game was won.
+	EventWin = "win"
+	//EventPause a game was paused and saved.
+	EventPause = "pause"
+)
+
+//Event is a public server event, it only contains information a
+//spectator may see and game events is published after the watch delay.
+type Event struct {
+	ID     string
+	Type   string
+	Time   time.Time
+	Player *EventPlayer `json:",omitempty"`
+	Game   *EventGame   `json:",omitempty"`
+}
+
+//EventPlayer is the player of a join or leave event.
+type EventPlayer struct {
+	ID    int
+	Name  string
+	IsBot bool
+}
+
+//EventGame is the game of a game event. The player names is only set
+//on the start event.
+type EventGame struct {
+	PlayerIDs   [2]int
+	PlayerNames [2]string
+	GameTs      time.Time
+	IsResume    bool        `json:",omitempty"`
+	MoverID     int         `json:",omitempty"`
+	MoveType    string      `json:",omitempty"`
+	Flags       []int       `json:",omitempty"` //The flags won by a claim.
+	WinnerID    int         `json:",omitempty"`
+	ViewPos     *bg.ViewPos `json:",omitempty"` //The spectator view after a move.
+}
+
+//EventLog keeps the last public events. The event ids is the log
+//epoch and the sequence number separated by a dash, so a client
+//resuming after a server restart receives all kept events.
+//A nil log ignores events.
+type EventLog struct {
+	mu       *sync.Mutex
+	epoch    string
+	events   []*Event
+	next     int64         //The sequence number of the next event.
+	newCh    chan struct{} //Closed and replaced when events is added.
+	closeCh  chan struct{}
+	isClosed bool
+	names    func(ids [2]int) [2]string //Finds the player names, maybe nil.
+}
+
+//NewEventLog creates a event log.
+func NewEventLog() (l *EventLog) {
+	l = new(EventLog)
+	l.mu = new(sync.Mutex)
+	l.epoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+	l.events = make([]*Event, 0, eventLogSIZE)
+	l.next = 1
+	l.newCh = make(chan struct{})
+	l.closeCh = make(chan struct{})
+	return l
+}
+
+//add adds a event, the id and time is set.
+func (l *EventLog) add(event *Event) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.isClosed {
+		return
+	}
+	event.ID = l.epoch + "-" + strconv.FormatInt(l.next, 10)
+	event.Time = time.Now()
+	l.next = l.next + 1
+	if len(l.events) == eventLogSIZE {
+		copy(l.events, l.events[1:])
+		l.events = l.events[:len(l.events)-1]
+	}
+	l.events = append(l.events, event)
+	close(l.newCh)
+	l.newCh = make(chan struct{})
+}
+
+//Since returns the events after the last event id and a channel that
+//is closed when new events is added. A empty last id returns no events,
+//a unknown id returns all kept events. isClosed is true when the log
+//is closed.
+func (l *EventLog) Since(lastID string) (events []*Event, nextID string, newCh <-chan struct{}, isClosed bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	first := l.next - int64(len(l.events))
+	from := l.next
+	if len(lastID) != 0 {
+		from = first
+		if ix := strings.LastIndex(lastID, "-"); ix != -1 && lastID[:ix] == l.epoch {
+			seq, err := strconv.ParseInt(lastID[ix+1:], 10, 64)
+			if err == nil && seq >= first-1 && seq < l.next {
+				from = seq + 1
+			}
+		}
+	}
+	events = make([]*Event, int(l.next-from))
+	copy(events, l.events[from-first:])
+	nextID = lastID
+	if len(events) != 0 {
+		nextID = events[len(events)-1].ID
+	} else if len(lastID) == 0 {
+		nextID = l.epoch + "-" + strconv.FormatInt(l.next-1, 10)
+	}
+	return events, nextID, l.newCh, l.isClosed
+}
+
+//CloseCh returns the channel that is closed when the log is closed.
+func (l *EventLog) CloseCh() <-chan struct{} {
+	return l.closeCh
+}
+
+//close closes the log, no more events is added.
+func (l *EventLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.isClosed {
+		l.isClosed = true
+		close(l.closeCh)
+	}
+}
+
+//addPlayers adds the join and leave events of a player list update.
+func (l *EventLog) addPlayers(oldPlayers, newPlayers map[int]*PlayerData) {
+	if l == nil {
+		return
+	}
+	for id, player := range newPlayers {
+		if _, isFound := oldPlayers[id]; !isFound {
+			l.add(&Event{Type: EventJoin, Player: &EventPlayer{ID: id, Name: player.Name, IsBot: player.IsBot}})
+		}
+	}
+	for id, player := range oldPlayers {
+		if _, isFound := newPlayers[id]; !isFound {
+			l.add(&Event{Type: EventLeave, Player: &EventPlayer{ID: id, Name: player.Name, IsBot: player.IsBot}})
+		}
+	}
+}
+
+//addStart adds the start event of a game.
+func (l *EventLog) addStart(ids [2]int, gameTs time.Time, isResume bool) {
+	if l == nil {
+		return
+	}
+	var names [2]string
+	if l.names != nil {
+		names = l.names(ids)
+	}
+	l.add(&Event{Type: EventStart, Game: &EventGame{PlayerIDs: ids, PlayerNames: names, GameTs: gameTs, IsResume: isResume}})
+}
+
+//addView adds the events of a new spectator view, the view is
+//compared with the last view. The first view of a game have no events
+//as the bench adds the start event with the first view.
+func (l *EventLog) addView(data, lastData *WatchingChData) {
+	if l == nil || lastData == nil || data.ViewPos.IsEqual(lastData.ViewPos) {
+		return
+	}
+	viewPos := data.ViewPos
+	newGame := func() *EventGame {
+		return &EventGame{PlayerIDs: data.PlayingIDs, GameTs: data.GameTs}
+	}
+	moveType := viewPos.LastMoveType
+	switch {
+	case moveType == bg.MoveTypeAll.Cone:
+		var flags []int
+		for flagix := 1; flagix < len(viewPos.ConePos); flagix++ {
+			if viewPos.ConePos[flagix].IsWon() && !lastData.ViewPos.ConePos[flagix].IsWon() {
+				flags = append(flags, flagix)
+			}
+		}
+		if len(flags) != 0 {
+			game := newGame()
+			game.MoverID = data.PlayingIDs[viewPos.LastMover]
+			game.Flags = flags
+			l.add(&Event{Type: EventClaim, Game: game})
+		}
+	case moveType.IsPause():
+		l.add(&Event{Type: EventPause, Game: newGame()})
+	case moveType != bg.MoveTypeAll.Init && moveType != bg.MoveTypeAll.None:
+		game := newGame()
+		game.MoverID = data.PlayingIDs[viewPos.LastMover]
+		game.MoveType = moveType.String()
+		game.ViewPos = viewPos
+		l.add(&Event{Type: EventMove, Game: game})
+	}
+	if viewPos.Winner != dpos.NoPlayer {
+		game := newGame()
+		game.WinnerID = data.PlayingIDs[viewPos.Winner]
+		l.add(&Event{Type: EventWin, Game: game})
+	}
+}
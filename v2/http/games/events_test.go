@@ -0,0 +1,124 @@
+package games
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+)
+
+func TestEventLog(t *testing.T) {
+	l := NewEventLog()
+	events, lastID, newCh, isClosed := l.Since("")
+	if len(events) != 0 || isClosed {
+		t.Fatalf("New log should be empty got: %v, %v", events, isClosed)
+	}
+	l.addPlayers(map[int]*PlayerData{1: {ID: 1, Name: "Alice"}}, map[int]*PlayerData{2: {ID: 2, Name: "Bob", IsBot: true}})
+	select {
+	case <-newCh:
+	default:
+		t.Error("New events channel should be closed")
+	}
+	events, lastID, _, _ = l.Since(lastID)
+	if len(events) != 2 || events[0].Type != EventJoin || !events[0].Player.IsBot || events[1].Type != EventLeave {
+		t.Fatalf("Expected a join and a leave event got: %v", events)
+	}
+	if lastID != events[1].ID {
+		t.Errorf("Last id %v should be the id of the last event %v", lastID, events[1].ID)
+	}
+	resumed, _, _, _ := l.Since(events[0].ID)
+	if len(resumed) != 1 || resumed[0] != events[1] {
+		t.Errorf("Expected the event after the last id got: %v", resumed)
+	}
+	if all, _, _, _ := l.Since("unknown-1"); len(all) != 2 {
+		t.Errorf("Unknown id should return all events got: %v", all)
+	}
+	for i := 0; i < eventLogSIZE; i++ {
+		l.addStart([2]int{1, 2}, time.Now(), false)
+	}
+	events, _, _, _ = l.Since(events[0].ID)
+	if len(events) != eventLogSIZE || events[0].Type != EventStart {
+		t.Errorf("Expected the kept events got: %v", len(events))
+	}
+	l.close()
+	if _, _, _, isClosed = l.Since(lastID); !isClosed {
+		t.Error("Log should be closed")
+	}
+	select {
+	case <-l.CloseCh():
+	default:
+		t.Error("Close channel should be closed")
+	}
+	var nilLog *EventLog
+	nilLog.addStart([2]int{1, 2}, time.Now(), false)
+}
+
+func TestEventLogTable(t *testing.T) {
+	ids := [2]int{1, 2}
+	var recChs [2]chan *PlayingChData
+	var playerChs [2]chan<- *PlayingChData
+	for i := range recChs {
+		recChs[i] = make(chan *PlayingChData, 1)
+		playerChs[i] = recChs[i]
+	}
+	l := NewEventLog()
+	l.names = func(ids [2]int) [2]string { return [2]string{"Alice", "Bob"} }
+	_, lastID, _, _ := l.Since("")
+	finishCh := make(chan *bg.Game, 1)
+	go tableServe(ids, playerChs, NewJoinWatchChCl(), NewConnChCl(), NewPauseChCl(), NewChatChCl(), nil, nil, 0, time.Minute, newTableStats(), l, finishCh, make(chan error, 10))
+	var datas [2]*PlayingChData
+	for i, ch := range recChs {
+		datas[i] = <-ch
+	}
+	moveChs := [2]chan<- int{datas[0].MoveCh, datas[1].MoveCh}
+	for i := 0; i < 2; i++ {
+		mover := 0
+		if len(datas[1].ViewPos.Moves) > 0 {
+			mover = 1
+		}
+		moveChs[mover] <- 0
+		for j, ch := range recChs {
+			datas[j] = <-ch
+		}
+	}
+	mover := 0
+	if len(datas[1].ViewPos.Moves) > 0 {
+		mover = 1
+	}
+	moveChs[mover] <- SMQuit
+	for _, ch := range recChs {
+		<-ch
+	}
+	<-finishCh
+	expTypes := []string{EventStart, EventMove, EventMove, EventMove, EventWin}
+	var types []string
+	timeout := time.After(time.Second)
+	for len(types) < len(expTypes) {
+		var events []*Event
+		var newCh <-chan struct{}
+		events, lastID, newCh, _ = l.Since(lastID)
+		for _, event := range events {
+			types = append(types, event.Type)
+			if event.Type == EventStart && event.Game.PlayerNames[1] != "Bob" {
+				t.Errorf("Start event should have the names got: %v", event.Game)
+			}
+			if event.Type == EventMove && (event.Game.ViewPos == nil || event.Game.ViewPos.View.IsPlayer()) {
+				t.Errorf("Move event should have the spectator view got: %v", event.Game)
+			}
+			if event.Type == EventWin && event.Game.WinnerID != ids[1-mover] {
+				t.Errorf("Expected winner %v got: %v", ids[1-mover], event.Game)
+			}
+		}
+		if len(types) < len(expTypes) {
+			select {
+			case <-newCh:
+			case <-timeout:
+				t.Fatalf("Missing events got: %v", types)
+			}
+		}
+	}
+	if strings.Join(types, " ") != strings.Join(expTypes, " ") {
+		t.Errorf("Expected events %v got: %v", expTypes, types)
+	}
+}
@@ -3,26 +3,30 @@
 package games
 
 import (
-	"golang.org/x/net/websocket"
+	"github.com/rezder/go-battleline/v2/http/config"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 //Server the game server structur.
 type Server struct {
 	tables  *TablesServer
 	players *PlayersServer
+	pubList *PubList
 }
 
 //New Create a game server.
-func New(archPokePort int, archAddr string) (g *Server, err error) {
+func New(cfg *config.Config) (g *Server, err error) {
 	g = new(Server)
 	list := NewList()
-	tables, err := NewTablesServer(list, archPokePort, archAddr)
+	g.pubList = list
+	tables, err := NewTablesServer(list, cfg)
 	if err != nil {
 		return g, err
 	}
 	g.tables = tables
-	g.players = NewPlayersServer(list, g.tables.StartGameChCl)
+	g.players = NewPlayersServer(list, g.tables.StartGameChCl, g.tables.savedGamesDb, g.tables.restarts)
 	return g, err
 }
 
@@ -37,23 +41,36 @@ func (g *Server) Start(errCh chan<- error) {
 	g.players.Start()
 }
 
-//Stop stops the game server.
+//Stop stops the game server, the event log is closed.
 func (g *Server) Stop() {
 	g.players.Stop()
 	g.tables.Stop()
+	g.pubList.Events().close()
+}
+
+//Events returns the public event log.
+func (g *Server) Events() *EventLog {
+	return g.pubList.Events()
 }
 
 //JoinClient asks the server to add player to the game server.
 func (g *Server) JoinClient(
 	id int,
 	name string,
-	ws *websocket.Conn,
+	isBot bool,
+	conn Conn,
 	errCh chan<- error,
 	joinedCh chan<- *Player) {
-	player := NewPlayer(id, name, ws, errCh, joinedCh)
+	player := NewPlayer(id, name, isBot, conn, errCh, joinedCh)
 	g.players.JoinCh <- player
 }
 
+//AddBot adds a bot player hosted by the server. Bots must be added
+//before the server is started.
+func (g *Server) AddBot(id int, name string, strength BotStrength) {
+	g.players.AddBot(NewServerBot(id, name, strength))
+}
+
 //BootPlayer asks the server to boot a player
 func (g *Server) BootPlayer(playerID int) {
 	g.players.DisableCh <- &PlayersDisData{Disable: true, PlayerID: playerID}
@@ -64,6 +81,82 @@ func (g *Server) BootPlayerStop(playerID int) {
 	g.players.DisableCh <- &PlayersDisData{Disable: false, PlayerID: playerID}
 }
 
+//DeletePlayer deletes the saved games of a deleted player.
+func (g *Server) DeletePlayer(playerID int) {
+	g.tables.DeletePlayer(playerID)
+}
+
+//Tables returns the running tables.
+func (g *Server) Tables() (tables []*TableData) {
+	games := g.pubList.ReadGames()
+	list := g.pubList.Read()
+	tables = make([]*TableData, 0, len(games)/2)
+	for id, gameData := range games {
+		if id < gameData.Opp {
+			table := &TableData{PlayerIDs: [2]int{id, gameData.Opp}}
+			for i, playerID := range table.PlayerIDs {
+				pubData, isFound := list[strconv.Itoa(playerID)]
+				if isFound {
+					table.PlayerNames[i] = pubData.Name
+				}
+			}
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+//TableData is the information of a running table.
+type TableData struct {
+	PlayerIDs   [2]int
+	PlayerNames [2]string
+}
+
+//SaveTable pauses and saves the game of a player.
+//isFound is false if the player is not playing.
+func (g *Server) SaveTable(playerID int) (isFound bool) {
+	gameData, isFound := g.pubList.ReadGame(playerID)
+	if isFound {
+		select {
+		case gameData.PauseChCl.Channel <- &PauseChData{PlayerID: playerID, IsForce: true}:
+		case <-gameData.PauseChCl.Close:
+			isFound = false
+		}
+	}
+	return isFound
+}
+
+//Broadcast sends a system message to all players.
+func (g *Server) Broadcast(txt string) {
+	for _, pubData := range g.pubList.Read() {
+		mess := &MesData{SenderID: sysSenderID, SenderName: "System", Message: txt}
+		go func(pubData *PubData) {
+			select {
+			case pubData.MessageCh <- mess:
+			case <-pubData.DoneComCh:
+			}
+		}(pubData)
+	}
+}
+
+//Drain stops new games from starting. The drained channel is closed
+//when all running games is finished. The games still running after the
+//timeout is saved and offered to the players when they reconnect after
+//the restart, zero timeout waits for all games. Must only be called once.
+func (g *Server) Drain(timeout time.Duration) {
+	g.tables.Drain(timeout)
+}
+
+//DrainedCh returns the channel that is closed when the server is drained.
+func (g *Server) DrainedCh() <-chan struct{} {
+	return g.tables.DrainedCh()
+}
+
+//DbSize returns the size of the saved games database.
+func (g *Server) DbSize() (int64, error) {
+	return g.tables.savedGamesDb.Size()
+}
+
 //BackupHandleFunc writes the backup of saved games to the http
 func (g *Server) BackupHandleFunc(resp http.ResponseWriter, req *http.Request) {
 	g.tables.savedGamesDb.BackupHandleFunc(resp, req)
@@ -0,0 +1,212 @@
+package games
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	bg "github.com/rezder/go-battleline/v2/game"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+	"github.com/rezder/go-error/log"
+	"time"
+)
+
+var (
+	//matchIdleTIMEOUT the time a match is kept after a finished game,
+	//when no game of the match is running or saved.
+	matchIdleTIMEOUT = time.Hour
+	//matchBestOfMAX the maximum number of games in a match.
+	matchBestOfMAX = 9
+)
+
+//Match is a series of games between two players. The first player
+//to win more than half of the games wins the match.
+//The dealer alternate between the players.
+type Match struct {
+	ID        int64
+	PlayerIDs [2]int
+	BestOf    int
+	Wins      [2]int
+	NoGames   int
+}
+
+//NewMatch creates a new match.
+func NewMatch(playerIDs [2]int, bestOf int) (m *Match) {
+	m = new(Match)
+	m.ID = time.Now().UnixNano()
+	m.PlayerIDs = playerIDs
+	m.BestOf = bestOf
+	return m
+}
+
+//isBestOfValid returns true if the number of games of a match is odd and
+//not more than the maximum, zero is a single game.
+func isBestOfValid(bestOf int) bool {
+	return bestOf == 0 || bestOf%2 == 1 && bestOf <= matchBestOfMAX
+}
+
+//Copy copies a match.
+func (m *Match) Copy() *Match {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	return &c
+}
+
+//DealerID returns the player id of the dealer of the next game.
+func (m *Match) DealerID() int {
+	return m.PlayerIDs[m.NoGames%2]
+}
+
+//AddResult adds the result of a finished game. winnerID is the
+//player id of the winner.
+func (m *Match) AddResult(winnerID int) {
+	m.NoGames = m.NoGames + 1
+	for i, id := range m.PlayerIDs {
+		if id == winnerID {
+			m.Wins[i] = m.Wins[i] + 1
+		}
+	}
+}
+
+//IsOver returns true if a player have won the match.
+func (m *Match) IsOver() bool {
+	return m.Wins[0] > m.BestOf/2 || m.Wins[1] > m.BestOf/2
+}
+
+//matchList is the matches in progress. The matches is saved in the
+//saved games database so they survive a restart. A match is removed
+//when it is over, or when it is idle for longer than the match timeout.
+//Only the tables server uses the list.
+type matchList struct {
+	db      *bolt.DB
+	bucket  []byte
+	matches map[int64]*Match
+	idle    map[int64]time.Time
+}
+
+//newMatchList creates the match list and loads the matches.
+func newMatchList(db *bolt.DB) (l *matchList, err error) {
+	l = new(matchList)
+	l.db = db
+	l.bucket = []byte("MatchBucket")
+	l.matches = make(map[int64]*Match)
+	l.idle = make(map[int64]time.Time)
+	err = db.Update(func(tx *bolt.Tx) error {
+		bck, txErr := tx.CreateBucketIfNotExists(l.bucket)
+		if txErr != nil {
+			return errors.Wrapf(txErr, log.ErrNo(1)+"Creating bucket %v", string(l.bucket))
+		}
+		return bck.ForEach(func(k, v []byte) error {
+			match := new(Match)
+			jsonErr := json.Unmarshal(v, match)
+			if jsonErr != nil {
+				return errors.Wrapf(jsonErr, "Decoding match %v failed", k)
+			}
+			l.matches[match.ID] = match
+			return nil
+		})
+	})
+	return l, err
+}
+
+//put saves a match.
+func (l *matchList) put(match *Match) (err error) {
+	bs, err := json.Marshal(match)
+	if err != nil {
+		return errors.Wrapf(err, "Encoding match %v failed", match.ID)
+	}
+	err = l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(l.bucket).Put(matchKey(match.ID), bs)
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Saving match %v failed", match.ID)
+	}
+	return err
+}
+
+//remove removes a match.
+func (l *matchList) remove(matchID int64) (err error) {
+	delete(l.matches, matchID)
+	delete(l.idle, matchID)
+	err = l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(l.bucket).Delete(matchKey(matchID))
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Deleting match %v failed", matchID)
+	}
+	return err
+}
+
+//matchKey returns the database key of a match.
+func matchKey(matchID int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(matchID))
+	return key
+}
+
+//start finds the match of a starting game, a new match is created
+//if requested. A copy of the match is returned, nil if the game is
+//not part of a match.
+func (l *matchList) start(start *StartGameChData, savedGame *bg.Game) (*Match, error) {
+	var err error
+	var match *Match
+	if savedGame != nil {
+		match = l.matches[savedGame.Hist.MatchID]
+	} else if start.Match != nil {
+		if start.Match.ID == 0 {
+			if start.Match.BestOf > 1 {
+				match = NewMatch(start.PlayerIds, start.Match.BestOf)
+				l.matches[match.ID] = match
+				err = l.put(match)
+			}
+		} else {
+			match = l.matches[start.Match.ID]
+		}
+	}
+	if match != nil {
+		delete(l.idle, match.ID)
+	}
+	return match.Copy(), err
+}
+
+//finish updates the match score with a finished game. The match of
+//a saved game is kept until the game is resumed.
+func (l *matchList) finish(hist *bg.Hist, isSaved bool) (err error) {
+	match, isFound := l.matches[hist.MatchID]
+	if !isFound || isSaved {
+		return err
+	}
+	winner := hist.Winner()
+	if winner != dpos.NoPlayer {
+		match.AddResult(hist.PlayerIDs[winner])
+		if match.IsOver() {
+			return l.remove(match.ID)
+		}
+		err = l.put(match)
+	}
+	return err
+}
+
+//expire removes the idle matches. A match is idle when no game of the
+//match is running or saved, isActive reports if the players of a match
+//have a running or saved game.
+func (l *matchList) expire(now time.Time, isActive func(ids [2]int) bool) (err error) {
+	for id, match := range l.matches {
+		if isActive(match.PlayerIDs) {
+			delete(l.idle, id)
+			continue
+		}
+		idleTime, isIdle := l.idle[id]
+		if !isIdle {
+			l.idle[id] = now
+		} else if now.Sub(idleTime) > matchIdleTIMEOUT {
+			log.Printf(log.DebugMsg, "Removing idle match: %v", match)
+			if removeErr := l.remove(id); removeErr != nil {
+				err = removeErr
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package games
+
+import (
+	"github.com/boltdb/bolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	ids := [2]int{1, 2}
+	match := NewMatch(ids, 3)
+	if match.DealerID() != 1 {
+		t.Errorf("First dealer should be 1 got: %v", match.DealerID())
+	}
+	match.AddResult(2)
+	if match.DealerID() != 2 {
+		t.Errorf("Second dealer should be 2 got: %v", match.DealerID())
+	}
+	if match.IsOver() {
+		t.Error("Match over after one game")
+	}
+	match.AddResult(2)
+	if !match.IsOver() {
+		t.Error("Match not over after two wins")
+	}
+	if match.Wins != [2]int{0, 2} || match.NoGames != 2 {
+		t.Errorf("Wrong score: %v games: %v", match.Wins, match.NoGames)
+	}
+	for _, bestOf := range []int{0, 1, 3, matchBestOfMAX} {
+		if !isBestOfValid(bestOf) {
+			t.Errorf("Best of %v should be valid", bestOf)
+		}
+	}
+	for _, bestOf := range []int{-1, 2, 4, matchBestOfMAX + 2} {
+		if isBestOfValid(bestOf) {
+			t.Errorf("Best of %v should not be valid", bestOf)
+		}
+	}
+}
+
+func TestMatchList(t *testing.T) {
+	defer func(timeout time.Duration) { matchIdleTIMEOUT = timeout }(matchIdleTIMEOUT)
+	matchIdleTIMEOUT = time.Minute
+	dir, err := ioutil.TempDir("", "battmatch")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "savegames.db")
+	db, err := bolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatalf("Open database failed: %v", err)
+	}
+	matches, err := newMatchList(db)
+	if err != nil {
+		t.Fatalf("Creating match list failed: %v", err)
+	}
+	start := &StartGameChData{PlayerIds: [2]int{1, 2}, Match: &Match{BestOf: 3}}
+	match, err := matches.start(start, nil)
+	if err != nil || match == nil || match.ID == 0 || len(matches.matches) != 1 {
+		t.Fatalf("New match not created: %v", err)
+	}
+	match.AddResult(1)
+	start.Match = match
+	cont, _ := matches.start(start, nil)
+	if cont.ID != match.ID || cont.NoGames != 0 {
+		t.Errorf("Continued match should be the server copy got: %v", cont)
+	}
+	start.Match = nil
+	if single, _ := matches.start(start, nil); single != nil {
+		t.Error("Single game should not have a match")
+	}
+	if err = db.Close(); err != nil {
+		t.Fatalf("Closing database failed: %v", err)
+	}
+	db, err = bolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatalf("Open database failed: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	matches, err = newMatchList(db)
+	if err != nil {
+		t.Fatalf("Loading match list failed: %v", err)
+	}
+	if _, isFound := matches.matches[match.ID]; !isFound {
+		t.Fatal("The match should be loaded from the database")
+	}
+
+	now := time.Now()
+	isActive := true
+	active := func(ids [2]int) bool { return isActive }
+	if err = matches.expire(now, active); err != nil || len(matches.matches) != 1 {
+		t.Errorf("A active match should not expire: %v", err)
+	}
+	isActive = false
+	_ = matches.expire(now, active)
+	_ = matches.expire(now.Add(matchIdleTIMEOUT/2), active)
+	if len(matches.matches) != 1 {
+		t.Error("A match should be kept until the timeout")
+	}
+	_ = matches.expire(now.Add(2*matchIdleTIMEOUT), active)
+	if len(matches.matches) != 0 {
+		t.Error("A idle match should expire")
+	}
+	matches, err = newMatchList(db)
+	if err != nil || len(matches.matches) != 0 {
+		t.Errorf("The expired match should be deleted from the database: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package games
+
+import (
+	"fmt"
+	"github.com/rezder/go-battleline/v2/http/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//gameMetrics is the counts of a game server. A nil metrics ignores
+//the counts.
+type gameMetrics struct {
+	moves           *metrics.Counter
+	moveLatency     *metrics.Histogram
+	invites         *metrics.Counter
+	invitesAccepted *metrics.Counter
+	wrtOverruns     *metrics.Counter
+}
+
+func newGameMetrics() (m *gameMetrics) {
+	m = new(gameMetrics)
+	m.moves = metrics.NewCounter("battleline_moves_total",
+		"The number of moves on all tables.")
+	m.moveLatency = metrics.NewHistogram("battleline_move_latency_seconds",
+		"The time from the view is send to the mover until the move is received.",
+		[]float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300})
+	m.invites = metrics.NewCounter("battleline_invites_total",
+		"The number of invites send.")
+	m.invitesAccepted = metrics.NewCounter("battleline_invites_accepted_total",
+		"The number of invites accepted.")
+	m.wrtOverruns = metrics.NewCounter("battleline_write_buffer_overruns_total",
+		"The number of player connections broken because the write buffer was full.")
+	return m
+}
+
+//addMove counts a move with the time the mover used.
+func (m *gameMetrics) addMove(latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.moves.Inc()
+	m.moveLatency.Observe(latency.Seconds())
+}
+
+//addInvite counts a send invite.
+func (m *gameMetrics) addInvite() {
+	if m != nil {
+		m.invites.Inc()
+	}
+}
+
+//addInviteAccepted counts a accepted invite.
+func (m *gameMetrics) addInviteAccepted() {
+	if m != nil {
+		m.invitesAccepted.Inc()
+	}
+}
+
+//addWrtOverrun counts a write buffer overrun.
+func (m *gameMetrics) addWrtOverrun() {
+	if m != nil {
+		m.wrtOverruns.Inc()
+	}
+}
+
+//tableStats is the statistics of a running table.
+type tableStats struct {
+	start    time.Time
+	moves    int64
+	latency  int64 //The sum of the move latencies in nanoseconds.
+	watchers int64
+	metrics  *gameMetrics
+}
+
+func newTableStats() *tableStats {
+	return &tableStats{start: time.Now()}
+}
+
+//addMove adds a move with the time the mover used.
+func (s *tableStats) addMove(latency time.Duration) {
+	atomic.AddInt64(&s.moves, 1)
+	atomic.AddInt64(&s.latency, int64(latency))
+	s.metrics.addMove(latency)
+}
+
+//addWatchers adds to the number of watchers, negative to remove.
+func (s *tableStats) addWatchers(no int) {
+	atomic.AddInt64(&s.watchers, int64(no))
+}
+
+//tableStatsList is the statistics of the running tables.
+type tableStatsList struct {
+	mu      *sync.Mutex
+	tables  map[string]*tableStats
+	metrics *gameMetrics
+}
+
+func newTableStatsList(m *gameMetrics) *tableStatsList {
+	return &tableStatsList{mu: new(sync.Mutex), tables: make(map[string]*tableStats), metrics: m}
+}
+
+//tableKey returns the metrics label of a table.
+func tableKey(ids [2]int) string {
+	if ids[0] > ids[1] {
+		ids[0], ids[1] = ids[1], ids[0]
+	}
+	return fmt.Sprintf("%v-%v", ids[0], ids[1])
+}
+
+//add adds a table.
+func (l *tableStatsList) add(ids [2]int) (stats *tableStats) {
+	stats = newTableStats()
+	stats.metrics = l.metrics
+	l.mu.Lock()
+	l.tables[tableKey(ids)] = stats
+	l.mu.Unlock()
+	return stats
+}
+
+//remove removes a finished table.
+func (l *tableStatsList) remove(ids [2]int) {
+	l.mu.Lock()
+	delete(l.tables, tableKey(ids))
+	l.mu.Unlock()
+}
+
+//values returns a value of every table.
+func (l *tableStatsList) values(value func(*tableStats) float64) map[string]float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	values := make(map[string]float64, len(l.tables))
+	for key, stats := range l.tables {
+		values[key] = value(stats)
+	}
+	return values
+}
+
+//watchers returns the number of watchers on all tables.
+func (l *tableStatsList) watchers() (no float64) {
+	for _, v := range l.values(statsWatchers) {
+		no = no + v
+	}
+	return no
+}
+
+func statsMoves(s *tableStats) float64 {
+	return float64(atomic.LoadInt64(&s.moves))
+}
+
+func statsWatchers(s *tableStats) float64 {
+	return float64(atomic.LoadInt64(&s.watchers))
+}
+
+func statsMovesPerSecond(s *tableStats) float64 {
+	return statsMoves(s) / time.Since(s.start).Seconds()
+}
+
+//statsLatency returns the average move latency in seconds.
+func statsLatency(s *tableStats) float64 {
+	moves := atomic.LoadInt64(&s.moves)
+	if moves == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&s.latency) / moves).Seconds()
+}
+
+//RegisterMetrics registers the game server metrics.
+func (g *Server) RegisterMetrics(reg *metrics.Registry) {
+	stats := g.tables.stats
+	m := g.pubList.metrics
+	reg.Register(
+		m.moves,
+		m.moveLatency,
+		m.invites,
+		m.invitesAccepted,
+		m.wrtOverruns,
+		metrics.NewGaugeFunc("battleline_tables", "The number of running tables.",
+			func() float64 { return float64(len(g.pubList.ReadGames()) / 2) }),
+		metrics.NewGaugeFunc("battleline_watchers", "The number of watchers on all tables.",
+			stats.watchers),
+		metrics.NewGaugeVecFunc("battleline_table_watchers", "The number of watchers of a table.",
+			"table", func() map[string]float64 { return stats.values(statsWatchers) }),
+		metrics.NewCounterVecFunc("battleline_table_moves_total", "The number of moves of a table.",
+			"table", func() map[string]float64 { return stats.values(statsMoves) }),
+		metrics.NewGaugeVecFunc("battleline_table_moves_per_second", "The average moves per second of a table.",
+			"table", func() map[string]float64 { return stats.values(statsMovesPerSecond) }),
+		metrics.NewGaugeVecFunc("battleline_table_move_latency_seconds", "The average move latency of a table.",
+			"table", func() map[string]float64 { return stats.values(statsLatency) }),
+		metrics.NewGaugeFunc("battleline_archiver_queue_length", "The number of games waiting to be archived.",
+			func() float64 { return float64(g.tables.archiver.QueueLen()) }),
+	)
+}
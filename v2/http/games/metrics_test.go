@@ -0,0 +1,29 @@
+package games
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rezder/go-battleline/v2/http/metrics"
+)
+
+func TestGameMetricsPerServer(t *testing.T) {
+	lists := [2]*PubList{NewList(), NewList()}
+	lists[0].metrics.addInvite()
+	lists[0].metrics.addInvite()
+	for i, exp := range []string{"battleline_invites_total 2", "battleline_invites_total 0"} {
+		reg := metrics.NewRegistry()
+		reg.Register(lists[i].metrics.invites)
+		buf := new(bytes.Buffer)
+		if err := reg.Write(buf); err != nil {
+			t.Fatalf("Writing metrics failed: %v", err)
+		}
+		if !strings.Contains(buf.String(), exp) {
+			t.Errorf("Server %v expected %v got:\n%v", i, exp, buf.String())
+		}
+	}
+	var m *gameMetrics //A nil metrics ignores the counts.
+	m.addMove(0)
+	m.addWrtOverrun()
+}
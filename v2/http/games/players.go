@@ -6,35 +6,58 @@ import (
 	bg "github.com/rezder/go-battleline/v2/game"
 	dpos "github.com/rezder/go-battleline/v2/game/pos"
 	"github.com/rezder/go-error/log"
-	"golang.org/x/net/websocket"
 	"io"
 	"strconv"
 	"time"
+	"unicode/utf8"
 )
 
 const (
-	ACTIDMess       = 1
-	ACTIDInvite     = 2
-	ACTIDInvAccept  = 3
-	ACTIDInvDecline = 4
-	ACTIDInvRetract = 5
-	ACTIDMove       = 6
-	ACTIDQuit       = 7
-	ACTIDWatch      = 8
-	ACTIDWatchStop  = 9
-	ACTIDList       = 10
-	ACTIDSave       = 11
-
-	JTMess         = 1
-	JTInvite       = 2
-	JTPlaying      = 3
-	JTWatching     = 4
-	JTList         = 5
-	JTCloseCon     = 6
-	JTClearInvites = 7
+	ACTIDMess        = 1
+	ACTIDInvite      = 2
+	ACTIDInvAccept   = 3
+	ACTIDInvDecline  = 4
+	ACTIDInvRetract  = 5
+	ACTIDMove        = 6
+	ACTIDQuit        = 7
+	ACTIDWatch       = 8
+	ACTIDWatchStop   = 9
+	ACTIDList        = 10
+	ACTIDSave        = 11
+	ACTIDSaveAccept  = 12
+	ACTIDSaveDecline = 13
+	ACTIDRematch     = 14
+	ACTIDChat        = 15
+	ACTIDHello       = 16
+	ACTIDResync      = 17
+
+	JTMess          = 1
+	JTInvite        = 2
+	JTPlaying       = 3
+	JTWatching      = 4
+	JTList          = 5
+	JTCloseCon      = 6
+	JTClearInvites  = 7
+	JTSavedGames    = 8
+	JTChat          = 9
+	JTHello         = 10
+	JTError         = 11
+	JTPlayingDelta  = 12
+	JTWatchingDelta = 13
 
 	wrtBuffSIZE  = 10
 	wrtBuffLIMIT = 8
+
+	sysSenderID = -1
+)
+
+var (
+	//chatMessSIZE the maximum number of characters in a chat message.
+	chatMessSIZE = 500
+	//chatRateLIMIT the number of chat messages a player may send in
+	//the chatRateWINDOW.
+	chatRateLIMIT  = 5
+	chatRateWINDOW = 10 * time.Second
 )
 
 //PlayersServer a server that keep tract of the players.
@@ -43,23 +66,38 @@ type PlayersServer struct {
 	DisableCh     chan *PlayersDisData
 	pubList       *PubList
 	startGameChCl *StartGameChCl
+	savedGamesDb  *savedGameDb
+	restarts      *restartList
+	bots          []*ServerBot
 	finishedCh    chan struct{}
 }
 
 //NewPlayersServer create a Players server.
-func NewPlayersServer(pubList *PubList, startGameChCl *StartGameChCl) (s *PlayersServer) {
+func NewPlayersServer(
+	pubList *PubList,
+	startGameChCl *StartGameChCl,
+	savedGamesDb *savedGameDb,
+	restarts *restartList) (s *PlayersServer) {
+
 	s = new(PlayersServer)
 	s.pubList = pubList
 	s.startGameChCl = startGameChCl
+	s.savedGamesDb = savedGamesDb
+	s.restarts = restarts
 	s.JoinCh = make(chan *Player)
 	s.DisableCh = make(chan *PlayersDisData)
 	s.finishedCh = make(chan struct{})
 	return s
 }
 
+//AddBot adds a server bot, the bot joins when the server starts.
+func (s *PlayersServer) AddBot(bot *ServerBot) {
+	s.bots = append(s.bots, bot)
+}
+
 //Start starts the players server.
 func (s *PlayersServer) Start() {
-	go playersServe(s.JoinCh, s.DisableCh, s.pubList, s.startGameChCl, s.finishedCh)
+	go playersServe(s.JoinCh, s.DisableCh, s.pubList, s.startGameChCl, s.savedGamesDb, s.restarts, s.bots, s.finishedCh)
 }
 
 //Stop stops the players server may take a while all player have close there games
@@ -76,10 +114,20 @@ func playersServe(
 	joinCh <-chan *Player,
 	disableCh <-chan *PlayersDisData,
 	pubList *PubList, startGameChCl *StartGameChCl,
+	savedGamesDb *savedGameDb,
+	restarts *restartList,
+	bots []*ServerBot,
 	finishedCh chan struct{}) {
 
 	leaveCh := make(chan int)
 	list := make(map[int]*PlayerData)
+	for _, bot := range bots {
+		list[bot.id] = bot.playerData()
+		go bot.serve(leaveCh)
+	}
+	if len(bots) > 0 {
+		publishPlayers(list, pubList)
+	}
 	done := false
 	// disPlayers is need because is possible for a player to be logged in but
 	// not join the players server when he is booted out. Unlikely but possible.
@@ -97,7 +145,7 @@ Loop:
 			if open {
 				inviteCh := make(chan *Invite)
 				messCh := make(chan *MesData)
-				p.joinServer(inviteCh, messCh, leaveCh, pubList, startGameChCl)
+				p.joinServer(inviteCh, messCh, leaveCh, pubList, startGameChCl, savedGamesDb, restarts)
 				p.joinedCh <- p
 				list[p.id] = &PlayerData{
 					ID:        p.id,
@@ -105,7 +153,8 @@ Loop:
 					InviteCh:  inviteCh,
 					DoneComCh: p.doneComCh,
 					MessageCh: messCh,
-					BootCh:    p.bootCh}
+					BootCh:    p.bootCh,
+					IsBot:     p.isBot}
 				publishPlayers(list, pubList)
 				if disPlayers[p.id] {
 					close(p.bootCh)
@@ -156,25 +205,30 @@ func publishPlayers(list map[int]*PlayerData, pubList *PubList) {
 type Player struct {
 	id          int
 	name        string
+	isBot       bool
 	tableStChCl *StartGameChCl
+	savedDb     *savedGameDb
+	restarts    *restartList
 	leaveCh     chan<- int
 	pubList     *PubList
 	inviteCh    <-chan *Invite
 	doneComCh   chan struct{}
 	messCh      <-chan *MesData
-	ws          *websocket.Conn
+	conn        Conn
 	errCh       chan<- error
 	bootCh      chan struct{} //server boot channel. used to kick player out.
 	joinedCh    chan<- *Player
+	chatTimes   []time.Time //The send times of the chat messages in the chat rate window.
 }
 
-// NewPlayer creates a new player.
-func NewPlayer(id int, name string, ws *websocket.Conn, errCh chan<- error,
+// NewPlayer creates a new player, isBot is true for bot accounts.
+func NewPlayer(id int, name string, isBot bool, conn Conn, errCh chan<- error,
 	joinedCh chan<- *Player) (p *Player) {
 	p = new(Player)
 	p.id = id
 	p.name = name
-	p.ws = ws
+	p.isBot = isBot
+	p.conn = conn
 	p.doneComCh = make(chan struct{})
 	p.errCh = errCh
 	p.bootCh = make(chan struct{})
@@ -184,7 +238,10 @@ func NewPlayer(id int, name string, ws *websocket.Conn, errCh chan<- error,
 
 // joinServer add the players server information.
 func (player *Player) joinServer(inviteCh <-chan *Invite, messCh <-chan *MesData,
-	leaveCh chan<- int, pubList *PubList, startGameChCl *StartGameChCl) {
+	leaveCh chan<- int, pubList *PubList, startGameChCl *StartGameChCl, savedGamesDb *savedGameDb,
+	restarts *restartList) {
+	player.savedDb = savedGamesDb
+	player.restarts = restarts
 	player.leaveCh = leaveCh
 	player.pubList = pubList
 	player.inviteCh = inviteCh
@@ -200,10 +257,10 @@ func (player *Player) Serve() {
 	wrtBrookCh := make(chan struct{})
 	wrtDoneCh := make(chan struct{})
 	readList := player.pubList.Read()
-	go netWrite(player.ws, sendCh, player.errCh, wrtBrookCh, wrtDoneCh, player.id)
+	go netWrite(player.conn, sendCh, player.errCh, wrtBrookCh, wrtDoneCh, player.id, player.pubList.metrics)
 
 	c := make(chan *Action, 1)
-	go netRead(player.ws, c, player.doneComCh, player.errCh, player.id)
+	go netRead(player.conn, c, player.doneComCh, player.errCh, player.id)
 	var actChan <-chan *Action
 	actChan = c
 
@@ -220,18 +277,27 @@ func (player *Player) Serve() {
 	watchGames := make(map[int]*JoinWatchChCl)
 	sendCh <- readList
 	sendSysMess(sendCh, "Welcome back to Battleline!")
+	sendSavedGames(sendCh, player.savedDb, player.id, player.errCh)
+	gameData, isFound := player.pubList.ReadGame(player.id)
+	if isFound {
+		reconnectGame(gameData.ConnChCl, player.id, playerGameCh, player.doneComCh, sendCh)
+	}
+	var restartOfferCh <-chan time.Time
+	if len(player.restarts.opps(player.id)) > 0 {
+		restartOfferCh = time.After(restartOfferDELAY)
+	}
 Loop:
 	for {
 		select {
 		case <-player.bootCh:
 			log.Printf(log.DebugMsg, "Player %v boot.", player.id)
 			handleCloseDown(player.doneComCh, gameState, watchGames, player.id,
-				receivedInvites, sendInvites, sendCh, wrtDoneCh, false)
+				receivedInvites, sendInvites, sendCh, wrtDoneCh, false, false)
 			break Loop
 		case <-wrtBrookCh:
 			log.Printf(log.DebugMsg, "Player %v received write broken.", player.id)
 			handleCloseDown(player.doneComCh, gameState, watchGames, player.id,
-				receivedInvites, sendInvites, sendCh, wrtDoneCh, false)
+				receivedInvites, sendInvites, sendCh, wrtDoneCh, false, true)
 			break Loop
 		case act, open := <-actChan:
 			if open {
@@ -243,13 +309,16 @@ Loop:
 				}
 			} else {
 				handleCloseDown(player.doneComCh, gameState, watchGames, player.id,
-					receivedInvites, sendInvites, sendCh, wrtDoneCh, true)
+					receivedInvites, sendInvites, sendCh, wrtDoneCh, true, true)
 				break Loop
 			}
 
 		case playingChData := <-playerGameCh:
 			readList = handleGameReceive(playingChData, sendCh, player.pubList, readList, gameState,
 				receivedInvites, sendInvites, player.id)
+			if playingChData == nil {
+				sendSavedGames(sendCh, player.savedDb, player.id, player.errCh)
+			}
 
 		case wgd := <-watchGameCh:
 			if wgd.joinWatchChCl == nil { //set chan to nil for done
@@ -278,11 +347,26 @@ Loop:
 					close(response.PlayingCh)
 				}
 			}
+		case <-restartOfferCh:
+			readList = player.pubList.Read()
+			if _, isIn := readList[strconv.Itoa(player.id)]; !isIn {
+				restartOfferCh = time.After(restartOfferDELAY)
+			} else {
+				restartOfferCh = nil
+				if player.offerRestart(readList, sendCh, sendInvites, inviteResponseCh, gameState) {
+					readList = player.pubList.Read()
+					sendCh <- readList
+				}
+			}
 		case message := <-player.messCh:
-			readList = playerExist(player.pubList, readList, message.SenderID, sendCh)
-			_, found := readList[strconv.Itoa(message.SenderID)]
-			if found {
+			if message.SenderID == sysSenderID {
 				sendCh <- message
+			} else {
+				readList = playerExist(player.pubList, readList, message.SenderID, sendCh)
+				_, found := readList[strconv.Itoa(message.SenderID)]
+				if found {
+					sendCh <- message
+				}
 			}
 		}
 	}
@@ -304,11 +388,31 @@ func handlePlayerAction(
 	case ACTIDMess:
 		isUpd = actMessage(act, readList, sendCh, player.id, player.name)
 	case ACTIDInvite:
-		isUpd = actSendInvite(sendInvites, inviteResponseCh, player.doneComCh,
-			act, readList, sendCh, player.id, player.name, gameState)
+		if isBestOfValid(act.BestOf) {
+			var match *Match
+			if act.BestOf > 1 {
+				match = &Match{BestOf: act.BestOf}
+			}
+			isUpd = actSendInvite(sendInvites, inviteResponseCh, player.doneComCh,
+				act, readList, sendCh, player.id, player.name, gameState, match, player.savedDb, player.pubList.metrics, player.errCh)
+		} else {
+			errTxt := fmt.Sprintf("Match of best of %v games must be a odd number of games up to %v", act.BestOf, matchBestOfMAX)
+			sendErrMess(sendCh, act.ActType, errTxt)
+			player.errCh <- errors.Wrap(NewPlayerErr(errTxt, player.id), log.ErrNo(37))
+		}
+	case ACTIDRematch:
+		if gameState.lastOppID != 0 {
+			act.ID = gameState.lastOppID
+			isUpd = actSendInvite(sendInvites, inviteResponseCh, player.doneComCh,
+				act, readList, sendCh, player.id, player.name, gameState, gameState.rematch(), player.savedDb, player.pubList.metrics, player.errCh)
+		} else {
+			errTxt := "Requesting rematch with no previous game!"
+			sendErrMess(sendCh, act.ActType, errTxt)
+			player.errCh <- errors.Wrap(NewPlayerErr(errTxt, player.id), log.ErrNo(27))
+		}
 	case ACTIDInvAccept:
 		isUpd = actAccInvite(recievedInvites, act, sendCh, playerGameCh, player.doneComCh,
-			player.id, gameState)
+			player.id, gameState, player.pubList.metrics)
 	case ACTIDInvDecline:
 		isUpd = actDeclineInvite(recievedInvites, act, player.id)
 	case ACTIDInvRetract:
@@ -318,17 +422,28 @@ func handlePlayerAction(
 		actMove(act, gameState, sendCh, player.errCh, player.id)
 	case ACTIDSave:
 		if gameState.waitingForClient() || gameState.waitingForServer() {
-			gameState.closeChannel()
+			if gameState.pauseReqID == 0 {
+				gameState.pause(player.id, false)
+				sendSysMess(sendCh, "Pause requested, waiting for opponent to accept.")
+			}
 		} else {
 			errTxt := "Requesting game save with no game active!"
-			sendSysMess(sendCh, errTxt)
+			sendErrMess(sendCh, act.ActType, errTxt)
 			player.errCh <- errors.Wrap(NewPlayerErr(errTxt, player.id), log.ErrNo(19))
 		}
+	case ACTIDSaveAccept, ACTIDSaveDecline:
+		if gameState.pauseReqID != 0 && gameState.pauseReqID != player.id {
+			gameState.pause(player.id, act.ActType == ACTIDSaveDecline)
+		} else {
+			errTxt := "Answering a pause request that does not exist!"
+			sendErrMess(sendCh, act.ActType, errTxt)
+			player.errCh <- errors.Wrap(NewPlayerErr(errTxt, player.id), log.ErrNo(26))
+		}
 	case ACTIDQuit:
 		if gameState.waitingForClient() {
 			gameState.respCh <- SMQuit
 		} else {
-			sendSysMess(sendCh, "Quitting game out of turn is not possible.")
+			sendErrMess(sendCh, act.ActType, "Quitting game out of turn is not possible.")
 			player.errCh <- errors.Wrap(NewPlayerErr("Quitting game out of turn", player.id), log.ErrNo(20))
 		}
 	case ACTIDWatch:
@@ -336,9 +451,20 @@ func handlePlayerAction(
 			sendCh, player.id)
 	case ACTIDWatchStop:
 		actWatchStop(watchGames, act, sendCh, player.id)
+	case ACTIDChat:
+		actChat(act, readList, sendCh, player, gameState, watchGames)
 	case ACTIDList:
 		isUpd = true
+	case ACTIDHello:
+		if !actHello(act, sendCh, player.conn) {
+			errTxt := fmt.Sprintf("Protocol version %v is not supported", act.Version)
+			sendErrMess(sendCh, act.ActType, errTxt)
+			player.errCh <- errors.Wrap(NewPlayerErr(errTxt, player.id), log.ErrNo(33))
+		}
+	case ACTIDResync:
+		sendCh <- deltaResync(act.ID)
 	default:
+		sendErrMess(sendCh, act.ActType, fmt.Sprintf("Action type %v do not exist", act.ActType))
 		player.errCh <- errors.Wrap(NewPlayerErr("Action do not exist", player.id), log.ErrNo(21))
 	}
 	return isUpd
@@ -346,10 +472,17 @@ func handlePlayerAction(
 
 //GameState keeps track of the current game.
 type GameState struct {
-	respCh      chan<- int
-	lastViewPos *bg.ViewPos
-	isClosed    bool //chanel closed
-	hasMoved    bool
+	respCh        chan<- int
+	connChCl      *ConnChCl
+	pauseChCl     *PauseChCl
+	chatChCl      *ChatChCl
+	lastViewPos   *bg.ViewPos
+	isClosed      bool //chanel closed
+	hasMoved      bool
+	isOppConnLost bool
+	pauseReqID    int
+	lastOppID     int    //Opponent of the last game, kept when the game is removed.
+	lastMatch     *Match //Match of the last game, kept when the game is removed.
 }
 
 //waitingForServer the player is waiting for the server to return a move.
@@ -381,14 +514,29 @@ func (state *GameState) waitingForClient() (res bool) {
 //removeGame removes game.
 func (state *GameState) removeGame() {
 	state.respCh = nil
+	state.connChCl = nil
+	state.pauseChCl = nil
+	state.chatChCl = nil
 	state.lastViewPos = nil
 	state.isClosed = false
+	state.isOppConnLost = false
+	state.pauseReqID = 0
 }
 
 //addGame adds a game.
-func (state *GameState) addGame(data *PlayingChData) {
+func (state *GameState) addGame(data *PlayingChData, playerID int) {
+	state.lastOppID = data.PlayingIDs[0]
+	if state.lastOppID == playerID {
+		state.lastOppID = data.PlayingIDs[1]
+	}
+	state.lastMatch = data.Match
 	state.respCh = data.MoveCh
+	state.connChCl = data.ConnChCl
+	state.pauseChCl = data.PauseChCl
+	state.chatChCl = data.ChatChCl
 	state.lastViewPos = data.ViewPos
+	state.isOppConnLost = data.IsOppConnLost
+	state.pauseReqID = data.PauseReqID
 }
 
 //receiveNewViewPos receives a viewPos.
@@ -407,6 +555,20 @@ func (state *GameState) hasGame() bool {
 	return state.respCh != nil
 }
 
+//rematch returns the match of a rematch against the last opponent.
+//A unfinished match continues, a finished match is followed by a new match
+//of the same length, and a single game is followed by a single game.
+func (state *GameState) rematch() (match *Match) {
+	if state.lastMatch != nil {
+		if state.lastMatch.IsOver() {
+			match = &Match{BestOf: state.lastMatch.BestOf}
+		} else {
+			match = state.lastMatch.Copy()
+		}
+	}
+	return match
+}
+
 // closeChannel close the table move channel to signal save game.
 func (state *GameState) closeChannel() {
 	if state.waitingForClient() || state.waitingForServer() {
@@ -415,6 +577,30 @@ func (state *GameState) closeChannel() {
 	}
 }
 
+// pause request, accept or decline a pause of the game.
+// The table may be busy sending to the player so it is send in the background.
+func (state *GameState) pause(playerID int, isDecline bool) {
+	pauseChCl := state.pauseChCl
+	data := &PauseChData{PlayerID: playerID, IsDecline: isDecline}
+	go func() {
+		select {
+		case pauseChCl.Channel <- data:
+		case <-pauseChCl.Close:
+		}
+	}()
+}
+
+// connLost informs the table that the connection is lost, the table keeps
+// the game running while it waits for the player to reconnect.
+func (state *GameState) connLost(playerID int) {
+	if state.waitingForClient() || state.waitingForServer() {
+		select {
+		case state.connChCl.Channel <- &ConnChData{PlayerID: playerID}:
+		case <-state.connChCl.Close:
+		}
+	}
+}
+
 //actRetractInvite retract an invite
 func actRetractInvite(sendInvites map[int]*Invite, act *Action) {
 	invite, found := sendInvites[act.ID]
@@ -447,6 +633,9 @@ func handleInviteResponse(
 			startData := new(StartGameChData)
 			startData.PlayerIds = [2]int{playerID, response.Responder}
 			startData.PlayerChs = [2]chan<- *PlayingChData{playingRecCh, response.PlayingCh}
+			startData.Match = invite.Match
+			startData.IsResume = invite.SavedGame != nil
+			startData.WatchDelay = time.Duration(invite.WatchDelay) * time.Second
 			select {
 			case startGameChCl.Channel <- startData:
 				go gameListen(playerGameCh, playerDoneComCh, playingRecCh, sendCh, invite)
@@ -479,9 +668,11 @@ func handleGameReceive(
 		gameState.removeGame()
 		updReadList = pubList.Read()
 		sendCh <- updReadList
+	} else if playingChData.Chat != nil {
+		sendCh <- &TableChat{PlayingIDs: playingChData.PlayingIDs, ChatMess: playingChData.Chat}
 	} else {
 		if !gameState.hasGame() { //Init data
-			gameState.addGame(playingChData)
+			gameState.addGame(playingChData, playerID)
 			clearInvites(receivedInvites, sendInvites, playerID)
 			sendCh <- ClearInvites("All invites was clear as game starts.")
 			updReadList = pubList.Read()
@@ -491,6 +682,23 @@ func handleGameReceive(
 			if len(playingChData.ViewPos.Moves) > 0 {
 				gameState.receiveNewViewPos(playingChData.ViewPos)
 			}
+			gameState.lastMatch = playingChData.Match
+			if playingChData.PauseReqID != gameState.pauseReqID {
+				gameState.pauseReqID = playingChData.PauseReqID
+				if gameState.pauseReqID != 0 && gameState.pauseReqID != playerID {
+					sendSysMess(sendCh, "Opponent requests a pause of the game.")
+				} else if gameState.pauseReqID == 0 {
+					sendSysMess(sendCh, "Pause request was declined.")
+				}
+			}
+			if playingChData.IsOppConnLost != gameState.isOppConnLost {
+				gameState.isOppConnLost = playingChData.IsOppConnLost
+				if gameState.isOppConnLost {
+					sendSysMess(sendCh, "Opponent lost the connection, waiting for opponent to reconnect.")
+				} else {
+					sendSysMess(sendCh, "Opponent reconnected.")
+				}
+			}
 			sendCh <- playingChData
 		}
 	}
@@ -498,17 +706,25 @@ func handleGameReceive(
 }
 
 // handleCloseDown close down the player.
+// If the connection is lost the game is left running for the player to reconnect
+// else it is closed.
 //# receivedInvites
 //# sendInvites
 //# gameState
 func handleCloseDown(playerDoneComCh chan struct{}, gameState *GameState,
 	watchGames map[int]*JoinWatchChCl, playerID int, receivedInvites map[int]*Invite,
-	sendInvites map[int]*Invite, sendCh chan<- interface{}, wrtDoneCh chan struct{}, isPlayerCloseConn bool) {
+	sendInvites map[int]*Invite, sendCh chan<- interface{}, wrtDoneCh chan struct{},
+	isPlayerCloseConn, isConnLost bool) {
 	log.Printf(log.DebugMsg, "Closing player id: %v done comunication channel.", playerID)
 	close(playerDoneComCh)
 	if gameState != nil {
-		log.Printf(log.DebugMsg, "Player id: %v closing game.", playerID)
-		gameState.closeChannel()
+		if isConnLost {
+			log.Printf(log.DebugMsg, "Player id: %v lost connection to game.", playerID)
+			gameState.connLost(playerID)
+		} else {
+			log.Printf(log.DebugMsg, "Player id: %v closing game.", playerID)
+			gameState.closeChannel()
+		}
 	}
 	if len(watchGames) > 0 {
 		for watchingID, ch := range watchGames {
@@ -539,6 +755,8 @@ func actWatch(
 	if found {
 		txt := fmt.Sprintf("Start watching id: %v failed as you are already watching", act.ID)
 		sendSysMess(sendCh, txt)
+	} else if isTablePlayer(readList, act.ID, playerID) {
+		sendSysMess(sendCh, "You can not watch your own game")
 	} else {
 		joinWatchChCl := findJoinWatchCh(readList, act.ID)
 		if joinWatchChCl != nil {
@@ -562,6 +780,16 @@ func actWatch(
 	}
 	return isUpdList
 }
+
+//isTablePlayer checks if the player plays at the table of the watched player.
+func isTablePlayer(pubList map[string]*PubData, watchID, playerID int) bool {
+	if watchID == playerID {
+		return true
+	}
+	pubData, found := pubList[strconv.Itoa(watchID)]
+	return found && pubData.Opp == playerID
+}
+
 func findJoinWatchCh(pubList map[string]*PubData, watchID int) (ch *JoinWatchChCl) {
 	pubData, found := pubList[strconv.Itoa(watchID)]
 	if found {
@@ -595,12 +823,16 @@ func watchGameListen(
 			if !writeStop {
 				if open {
 					watchingData.WatchingID = watchID
+					var data interface{} = watchingData
+					if watchingData.Chat != nil {
+						data = &TableChat{PlayingIDs: watchingData.PlayingIDs, WatchingID: watchID, ChatMess: watchingData.Chat}
+					}
 					select {
 					case <-playerDoneComCh:
 						writeStop = true
 					default:
 						select {
-						case sendCh <- watchingData:
+						case sendCh <- data:
 						case <-playerDoneComCh:
 							writeStop = true
 						}
@@ -687,20 +919,21 @@ func actMove(act *Action, gameState *GameState, sendCh chan<- interface{}, errCh
 			gameState.sendMove(act.Moveix)
 		} else {
 			txt := "Illegal Move"
-			sendSysMess(sendCh, txt)
+			sendErrMess(sendCh, act.ActType, txt)
 			errCh <- errors.Wrap(NewPlayerErr("Illegal move", id), log.ErrNo(22))
 			gameState.sendMove(SMQuit)
 		}
 	} else {
 		txt := "Is not your time to move.!"
-		sendSysMess(sendCh, txt)
+		sendErrMess(sendCh, act.ActType, txt)
 		errCh <- errors.Wrap(NewPlayerErr("Move out of turn", id), log.ErrNo(23))
 	}
 }
 
 // actAccInvite accept a invite.
 func actAccInvite(recInvites map[int]*Invite, act *Action, sendCh chan<- interface{},
-	playerGameCh chan<- *PlayingChData, playerDoneComCh chan struct{}, id int, gameState *GameState) (isUpd bool) {
+	playerGameCh chan<- *PlayingChData, playerDoneComCh chan struct{}, id int, gameState *GameState,
+	metrics *gameMetrics) (isUpd bool) {
 
 	invite, found := recInvites[act.ID]
 	if found {
@@ -716,6 +949,7 @@ func actAccInvite(recInvites map[int]*Invite, act *Action, sendCh chan<- interfa
 			default:
 				select {
 				case invite.ResponseCh <- resp:
+					metrics.addInviteAccepted()
 					log.Printf(log.DebugMsg, "Player:%v send accept responds:%v to invite: %v start game listen", id, resp, invite)
 					go gameListen(playerGameCh, playerDoneComCh, playingRecCh, sendCh, invite)
 				case <-invite.DoneComCh:
@@ -739,7 +973,7 @@ func actAccInvite(recInvites map[int]*Invite, act *Action, sendCh chan<- interfa
 // gameListen listen for game moves.
 // If playerDoneComCh is closed and init state the game response channel is closed else
 // the listener keep listening until the channel is close but it do not resend the moves.
-//
+// The invite is nil when reconnecting to a game.
 func gameListen(playerGameCh chan<- *PlayingChData, playerDoneComCh chan struct{}, playingRecCh <-chan *PlayingChData,
 	sendCh chan<- interface{}, invite *Invite) {
 	initPlayingChData, initOpen := <-playingRecCh
@@ -765,12 +999,28 @@ func gameListen(playerGameCh chan<- *PlayingChData, playerDoneComCh chan struct{
 				}
 			}
 		}
-	} else {
+	} else if invite != nil {
 		invite.IsRejected = true
 		sendCh <- invite
 	}
 }
 
+// reconnectGame reconnects the player to a running game.
+func reconnectGame(
+	connChCl *ConnChCl,
+	playerID int,
+	playerGameCh chan<- *PlayingChData,
+	playerDoneComCh chan struct{},
+	sendCh chan<- interface{}) {
+	playingRecCh := make(chan *PlayingChData, 1)
+	select {
+	case connChCl.Channel <- &ConnChData{PlayerID: playerID, PlayerCh: playingRecCh}:
+		log.Printf(log.DebugMsg, "Player id: %v reconnected to game", playerID)
+		go gameListen(playerGameCh, playerDoneComCh, playingRecCh, sendCh, nil)
+	case <-connChCl.Close:
+	}
+}
+
 //Use to send pos view directly when the player do not need to take action, but the the views got out of order.
 /*func sendToClientPlayingData(
 	sendCh chan<- interface{},
@@ -802,9 +1052,13 @@ func sendToPlayerGameCh(
 }
 
 //actSendInvite send a invite.
+//If act.IsResume the invite is to resume the players saved game, the
+//invite is rejected if the players have no saved game. A invite to a
+//new game is rejected if the players have a saved game.
+//match is nil for a single game.
 func actSendInvite(invites map[int]*Invite, respCh chan<- *InviteResponse, playerDoneComCh chan struct{},
 	act *Action, readList map[string]*PubData, sendCh chan<- interface{}, id int, name string,
-	gameState *GameState) (isUpd bool) {
+	gameState *GameState, match *Match, savedGamesDb *savedGameDb, metrics *gameMetrics, errCh chan<- error) (isUpd bool) {
 	invite := new(Invite)
 	invite.InvitorID = id
 	invite.InvitorName = name
@@ -815,12 +1069,42 @@ func actSendInvite(invites map[int]*Invite, respCh chan<- *InviteResponse, playe
 			invite.ReceiverID = p.ID
 			invite.ReceiverName = p.Name
 			if !gameState.hasGame() {
+				savedGame, err := loadSavedGame(savedGamesDb, p.ID, id)
+				if err != nil {
+					errCh <- err
+				}
+				if act.IsResume && savedGame == nil {
+					invite.IsRejected = true
+					sendCh <- invite
+					m := fmt.Sprintf("Invite to %v failed no saved game to resume", p.Name)
+					sendSysMess(sendCh, m)
+					return isUpd
+				}
+				if !act.IsResume && savedGame != nil {
+					invite.IsRejected = true
+					sendCh <- invite
+					m := fmt.Sprintf("Invite to %v failed you have a saved game, resume it first", p.Name)
+					sendSysMess(sendCh, m)
+					return isUpd
+				}
+				if act.IsResume {
+					invite.SavedGame = savedGame
+				} else {
+					invite.Match = match
+				}
+				invite.WatchDelay = act.WatchDelay
+				if invite.WatchDelay < 0 {
+					invite.WatchDelay = 0
+				} else if time.Duration(invite.WatchDelay)*time.Second > watchDelayMAX {
+					invite.WatchDelay = int(watchDelayMAX / time.Second)
+				}
 				invite.ResponseCh = respCh
 				invite.RetractCh = make(chan struct{})
 				invite.DoneComCh = playerDoneComCh
 				select {
 				case p.InviteCh <- invite:
 					invites[p.ID] = invite
+					metrics.addInvite()
 				case <-p.DoneComCh:
 					invite.IsRejected = true
 					sendCh <- invite
@@ -871,6 +1155,62 @@ func actMessage(act *Action, readList map[string]*PubData, sendCh chan<- interfa
 	return isUpd
 }
 
+// actChat send a chat message to a table. If the player is playing and
+// the action id is the players id or zero the message is send to the players
+// table else to the watched game of the action id.
+func actChat(act *Action, readList map[string]*PubData, sendCh chan<- interface{},
+	player *Player, gameState *GameState, watchGames map[int]*JoinWatchChCl) {
+	if utf8.RuneCountInString(act.Mess) > chatMessSIZE {
+		errTxt := fmt.Sprintf("Chat message is longer than %v characters", chatMessSIZE)
+		sendErrMess(sendCh, act.ActType, errTxt)
+		player.errCh <- errors.Wrap(NewPlayerErr(errTxt, player.id), log.ErrNo(35))
+		return
+	}
+	if !player.isChatAllowed(time.Now()) {
+		errTxt := "Too many chat messages, wait before sending more"
+		sendErrMess(sendCh, act.ActType, errTxt)
+		player.errCh <- errors.Wrap(NewPlayerErr(errTxt, player.id), log.ErrNo(36))
+		return
+	}
+	var chatChCl *ChatChCl
+	if gameState.hasGame() && (act.ID == 0 || act.ID == player.id) {
+		chatChCl = gameState.chatChCl
+	} else if _, isWatching := watchGames[act.ID]; isWatching {
+		p, found := readList[strconv.Itoa(act.ID)]
+		if found {
+			chatChCl = p.ChatChCl
+		}
+	}
+	if chatChCl != nil {
+		data := &ChatChData{SenderID: player.id, SenderName: player.name, Message: act.Mess}
+		go func() { // The table may be busy sending to the player.
+			select {
+			case chatChCl.Channel <- data:
+			case <-chatChCl.Close:
+			}
+		}()
+	} else {
+		errTxt := fmt.Sprintf("Chat to game id: %v failed no active game", act.ID)
+		sendErrMess(sendCh, act.ActType, errTxt)
+		player.errCh <- errors.Wrap(NewPlayerErr(errTxt, player.id), log.ErrNo(28))
+	}
+}
+
+//isChatAllowed returns false if the player have send chatRateLIMIT chat
+//messages in the chatRateWINDOW, else the message is added to the window.
+func (player *Player) isChatAllowed(now time.Time) bool {
+	first := 0
+	for first < len(player.chatTimes) && now.Sub(player.chatTimes[first]) >= chatRateWINDOW {
+		first++
+	}
+	player.chatTimes = player.chatTimes[first:]
+	if len(player.chatTimes) >= chatRateLIMIT {
+		return false
+	}
+	player.chatTimes = append(player.chatTimes, now)
+	return true
+}
+
 // actDeclineInvite decline a invite.
 func actDeclineInvite(receivedInvites map[int]*Invite, act *Action, playerID int) (isUpd bool) {
 	invite, found := receivedInvites[act.ID]
@@ -915,16 +1255,56 @@ func playerExist(pubList *PubList, readList map[string]*PubData, id int,
 // it is assumed to be open. Use go instead if not sure.
 func sendSysMess(ch chan<- interface{}, txt string) {
 	mess := new(MesData)
-	mess.SenderID = -1
+	mess.SenderID = sysSenderID
 	mess.SenderName = "System"
 	mess.Message = txt
 	ch <- mess
 }
 
+//offerRestart offers the games interrupted by a server restart to the
+//opponents that is online, by sending a invite to resume the saved game.
+func (player *Player) offerRestart(
+	readList map[string]*PubData,
+	sendCh chan<- interface{},
+	sendInvites map[int]*Invite,
+	inviteResponseCh chan<- *InviteResponse,
+	gameState *GameState) (isUpd bool) {
+
+	for _, oppID := range player.restarts.opps(player.id) {
+		p, isOnline := readList[strconv.Itoa(oppID)]
+		if !isOnline || gameState.hasGame() {
+			continue
+		}
+		isFound, err := player.restarts.take([2]int{player.id, oppID})
+		if err != nil {
+			player.errCh <- err
+		}
+		if isFound {
+			sendSysMess(sendCh, fmt.Sprintf("Your game with %v was interrupted by a server restart, a invite to resume the game is send.", p.Name))
+			act := &Action{ActType: ACTIDInvite, ID: oppID, IsResume: true}
+			if actSendInvite(sendInvites, inviteResponseCh, player.doneComCh, act, readList, sendCh,
+				player.id, player.name, gameState, nil, player.savedDb, player.pubList.metrics, player.errCh) {
+				isUpd = true
+			}
+		}
+	}
+	return isUpd
+}
+
+// sendSavedGames sends the players saved games.
+func sendSavedGames(sendCh chan<- interface{}, savedGamesDb *savedGameDb, playerID int, errCh chan<- error) {
+	savedGames, err := loadSavedGames(savedGamesDb, playerID)
+	if err != nil {
+		errCh <- err
+		return
+	}
+	sendCh <- savedGames
+}
+
 // sendSysMessGo send a system message if possible.
 func sendSysMessGo(sendCh chan<- interface{}, playerDoneComCh chan struct{}, txt string) {
 	mess := new(MesData)
-	mess.SenderID = -1
+	mess.SenderID = sysSenderID
 	mess.SenderName = "System"
 	mess.Message = txt
 	select {
@@ -972,21 +1352,36 @@ type ClearInvites string
 // netWrite keep reading until overflow/broken line or done message is send.
 // overflow/broken line disable the write to net but keeps draining the pipe.
 func netWrite(
-	ws *websocket.Conn,
+	conn Conn,
 	dataCh <-chan interface{},
 	errCh chan<- error,
 	brokenConn chan struct{},
 	doneCh chan struct{},
-	playerID int) {
+	playerID int,
+	metrics *gameMetrics) {
 
 	broke := false
 	serverStop := false
 	playerStop := false
 	stop := false
 	var closeCon CloseCon
+	protocol := legacyProtocol()
+	deltas := newDeltaEncoder()
 Loop:
 	for {
 		data := <-dataCh
+		if clientProt, isProt := data.(*clientProtocol); isProt {
+			protocol = clientProt
+			if binaryConn, isBinary := conn.(BinaryConn); isBinary {
+				binaryConn.SetBinary(protocol.caps[CapBinary])
+			}
+			continue
+		}
+		if resync, isResync := data.(deltaResync); isResync {
+			if data = deltas.resync(int(resync)); data == nil {
+				continue
+			}
+		}
 		closeCon, stop = data.(CloseCon)
 		if stop {
 			if closeCon.isPlayer {
@@ -996,14 +1391,21 @@ Loop:
 			}
 		}
 		if !broke && !playerStop {
-			sendData := netWriteAddJSONType(data)
+			sendData, isSend := protocol.convert(netWriteAddJSONType(data))
+			if !isSend {
+				continue
+			}
+			if protocol.caps[CapDelta] {
+				sendData = deltas.encode(sendData)
+			}
 			log.Printf(log.DebugMsg, "Sends: %v to Player: %v ", sendData, playerID)
-			err := websocket.JSON.Send(ws, sendData)
+			err := conn.Send(sendData)
 			if err != nil {
-				errCh <- errors.Wrap(err, log.ErrNo(24)+"Websocket send")
+				errCh <- errors.Wrap(NewPlayerServerErr(err.Error(), playerID), log.ErrNo(24)+"Connection send")
 				broke = true
 			} else {
 				if len(dataCh) > wrtBuffLIMIT {
+					metrics.addWrtOverrun()
 					broke = true
 				}
 			}
@@ -1039,6 +1441,14 @@ func netWriteAddJSONType(data interface{}) (jdata *JsonData) {
 		jdata.JsonType = JTCloseCon
 	case ClearInvites:
 		jdata.JsonType = JTClearInvites
+	case SavedGames:
+		jdata.JsonType = JTSavedGames
+	case *TableChat:
+		jdata.JsonType = JTChat
+	case *Hello:
+		jdata.JsonType = JTHello
+	case *ErrMess:
+		jdata.JsonType = JTError
 	default:
 		txt := fmt.Sprintf("Message not implemented yet: %v\n", data)
 		panic(txt)
@@ -1046,12 +1456,12 @@ func netWriteAddJSONType(data interface{}) (jdata *JsonData) {
 	return jdata
 }
 
-//netRead reading data from a websocket.
+//netRead reading data from a connection.
 //Keep reading until eof,an error or done. Done can not breake the read stream
-//so to make sure to end loop websocket must be closed.
+//so to make sure to end loop the connection must be closed.
 //It always close the channel before leaving.
 func netRead(
-	ws *websocket.Conn,
+	conn Conn,
 	accCh chan<- *Action,
 	playerDoneComCh chan struct{},
 	errCh chan<- error,
@@ -1061,18 +1471,19 @@ Loop:
 	for {
 		var act Action
 		ts := time.Now().Add(10 * time.Minute)
-		err := ws.SetReadDeadline(ts)
-		if err == nil {
-			err = websocket.JSON.Receive(ws, &act)
-			log.Printf(log.DebugMsg, "Player: %v receive action: %v, Error: %v\n", playerID, act, err)
-		}
+		err := conn.Receive(&act, ts)
+		log.Printf(log.DebugMsg, "Player: %v receive action: %v, Error: %v\n", playerID, act, err)
 		if err == io.EOF {
 			break Loop
 		} else if err != nil {
 			select {
 			case <-playerDoneComCh:
 			default:
-				errCh <- errors.Wrap(err, log.ErrNo(25)+"Websocket receive")
+				if isJSONErr(err) {
+					errCh <- errors.Wrap(NewPlayerErr(err.Error(), playerID), log.ErrNo(25)+"Connection receive")
+				} else {
+					errCh <- errors.Wrap(NewPlayerServerErr(err.Error(), playerID), log.ErrNo(25)+"Connection receive")
+				}
 			}
 			break Loop //maybe to harsh
 		} else {
@@ -1089,10 +1500,15 @@ Loop:
 
 // Action the client action.
 type Action struct {
-	ActType int
-	ID      int
-	Moveix  int
-	Mess    string
+	ActType    int
+	ID         int
+	Moveix     int
+	Mess       string
+	BestOf     int      //Number of games in a match invite.
+	WatchDelay int      //Spectator delay in seconds of a invite.
+	Version    int      //Protocol version of a hello.
+	Caps       []string //Client capabilities of a hello.
+	IsResume   bool     //Invite to resume the saved game.
 }
 
 // NewAction creates a new action.
@@ -1115,11 +1531,19 @@ type playerWatchGameData struct {
 type PlayerErr struct {
 	PlayerID int
 	Txt      string
+	Kind     PlayerErrKind
 }
 
-//NewPlayerErr creates a new player specific error.
+//NewPlayerErr creates a new player specific error caused by the player
+//misusing the protocol.
 func NewPlayerErr(txt string, id int) (err error) {
-	return &PlayerErr{id, txt}
+	return &PlayerErr{id, txt, PlayerErrKindAll.Protocol}
+}
+
+//NewPlayerServerErr creates a new player specific error caused by the
+//server or the connection.
+func NewPlayerServerErr(txt string, id int) (err error) {
+	return &PlayerErr{id, txt, PlayerErrKindAll.Server}
 }
 func (err *PlayerErr) Error() string {
 	return fmt.Sprintf(err.Txt+" Error reported on player id: %v", err.PlayerID)
@@ -0,0 +1,339 @@
+package games
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rezder/go-battleline/v2/http/config"
+)
+
+//testPlayerWAIT the time a test player waits for a message.
+const testPlayerWAIT = 5 * time.Second
+
+//chanConn is a test connection, the messages is received on msgCh and
+//the actions is posted on actCh.
+type chanConn struct {
+	msgCh   chan *JsonData
+	actCh   chan *Action
+	closeCh chan struct{}
+	once    *sync.Once
+}
+
+func newChanConn() (c *chanConn) {
+	c = new(chanConn)
+	c.msgCh = make(chan *JsonData, 1000)
+	c.actCh = make(chan *Action)
+	c.closeCh = make(chan struct{})
+	c.once = new(sync.Once)
+	return c
+}
+
+func (c *chanConn) Send(data *JsonData) error {
+	select {
+	case c.msgCh <- data:
+	case <-c.closeCh:
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+func (c *chanConn) Receive(act *Action, deadline time.Time) error {
+	select {
+	case postAct := <-c.actCh:
+		*act = *postAct
+		return nil
+	case <-c.closeCh:
+		return io.EOF
+	}
+}
+
+func (c *chanConn) Close() error {
+	c.once.Do(func() { close(c.closeCh) })
+	return nil
+}
+
+//testPlayer is a player joined to a game server with a test connection.
+type testPlayer struct {
+	id   int
+	conn *chanConn
+	t    *testing.T
+}
+
+//joinTestPlayer joins a player to the game server.
+func joinTestPlayer(t *testing.T, g *Server, id int, name string, errCh chan<- error) (p *testPlayer) {
+	p = &testPlayer{id: id, conn: newChanConn(), t: t}
+	joinedCh := make(chan *Player)
+	go g.JoinClient(id, name, false, p.conn, errCh, joinedCh)
+	player := <-joinedCh
+	go player.Serve()
+	return p
+}
+
+//act sends a action.
+func (p *testPlayer) act(act *Action) {
+	select {
+	case p.conn.actCh <- act:
+	case <-time.After(testPlayerWAIT):
+		p.t.Fatalf("Player %v action %v timed out", p.id, act)
+	}
+}
+
+//waitFor waits for the first message accepted by the filter.
+func (p *testPlayer) waitFor(jsonType int, filter func(data interface{}) bool) interface{} {
+	timeout := time.After(testPlayerWAIT)
+	for {
+		select {
+		case jdata := <-p.conn.msgCh:
+			if jdata.JsonType == jsonType && (filter == nil || filter(jdata.Data)) {
+				return jdata.Data
+			}
+		case <-timeout:
+			p.t.Fatalf("Player %v timed out waiting for message type %v", p.id, jsonType)
+		}
+	}
+}
+
+//waitPlaying waits for the next game view.
+func (p *testPlayer) waitPlaying() *PlayingChData {
+	return p.waitFor(JTPlaying, func(data interface{}) bool {
+		return data.(*PlayingChData).Chat == nil
+	}).(*PlayingChData)
+}
+
+//waitOpp waits until the opponent is in the players list.
+func (p *testPlayer) waitOpp(oppID int) {
+	for i := 0; ; i++ {
+		p.act(NewAction(ACTIDList))
+		list := p.waitFor(JTList, nil).(map[string]*PubData)
+		if _, isFound := list[strconv.Itoa(oppID)]; isFound {
+			return
+		}
+		if i == 100 {
+			p.t.Fatalf("Player %v never saw opponent %v", p.id, oppID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+//startTestGame starts a game between two players, the invite is send by
+//the first player. The first game views is returned.
+func startTestGame(players [2]*testPlayer, invite *Action) (views [2]*PlayingChData) {
+	players[0].waitOpp(players[1].id)
+	invite.ActType = ACTIDInvite
+	invite.ID = players[1].id
+	players[0].act(invite)
+	players[1].waitFor(JTInvite, func(data interface{}) bool {
+		return data.(*Invite).InvitorID == players[0].id
+	})
+	accept := NewAction(ACTIDInvAccept)
+	accept.ID = players[0].id
+	players[1].act(accept)
+	for i, p := range players {
+		views[i] = p.waitPlaying()
+	}
+	return views
+}
+
+//playTestMoves makes the first legal move a number of times.
+func playTestMoves(players [2]*testPlayer, views [2]*PlayingChData, no int) [2]*PlayingChData {
+	for n := 0; n < no; n++ {
+		for i, p := range players {
+			if len(views[i].ViewPos.Moves) > 0 {
+				move := NewAction(ACTIDMove)
+				move.Moveix = 0
+				p.act(move)
+				break
+			}
+		}
+		for i, p := range players {
+			views[i] = p.waitPlaying()
+		}
+	}
+	return views
+}
+
+//newTestServer creates and starts a game server in the data directory.
+func newTestServer(t *testing.T, dataDir string, errCh chan<- error) *Server {
+	cfg := config.Default()
+	cfg.DataDir = dataDir
+	cfg.ArchPokePort = 0
+	g, err := New(cfg)
+	if err != nil {
+		t.Fatalf("Creating game server failed: %v", err)
+	}
+	g.Start(errCh)
+	return g
+}
+
+//waitSavedGame waits until the game of the players is saved.
+func waitSavedGame(t *testing.T, g *Server, ids [2]int) {
+	for i := 0; ; i++ {
+		savedGame, err := loadSavedGame(g.tables.savedGamesDb, ids[0], ids[1])
+		if err != nil {
+			t.Fatalf("Loading saved game failed: %v", err)
+		}
+		if savedGame != nil {
+			return
+		}
+		if i == 100 {
+			t.Fatalf("Game %v was never saved", ids)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestResumeSavedGame(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battresume")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	errCh := make(chan error, 100)
+	g := newTestServer(t, dir, errCh)
+	defer g.Stop()
+	players := [2]*testPlayer{joinTestPlayer(t, g, 1, "Alice", errCh), joinTestPlayer(t, g, 2, "Bob", errCh)}
+	views := playTestMoves(players, startTestGame(players, NewAction(ACTIDInvite)), 4)
+
+	players[0].act(NewAction(ACTIDSave))
+	players[1].waitFor(JTPlaying, func(data interface{}) bool {
+		return data.(*PlayingChData).PauseReqID == players[0].id
+	})
+	players[1].act(NewAction(ACTIDSaveAccept))
+	for _, p := range players {
+		p.waitFor(JTPlaying, func(data interface{}) bool {
+			return data.(*PlayingChData).ViewPos.LastMoveType.IsPause()
+		})
+	}
+	waitSavedGame(t, g, [2]int{1, 2})
+	invite := NewAction(ACTIDInvite)
+	invite.ID = players[1].id
+	players[0].act(invite)
+	players[0].waitFor(JTInvite, func(data interface{}) bool {
+		return data.(*Invite).IsRejected
+	})
+
+	resumed := startTestGame(players, &Action{IsResume: true})
+	for i := range players {
+		if resumed[i].ViewPos.CardPos != views[i].ViewPos.CardPos || resumed[i].ViewPos.ConePos != views[i].ViewPos.ConePos {
+			t.Errorf("Player %v resumed position differs from the saved position\n%v\n%v", players[i].id, resumed[i].ViewPos, views[i].ViewPos)
+		}
+	}
+	if savedGame, err := loadSavedGame(g.tables.savedGamesDb, 1, 2); err != nil || savedGame != nil {
+		t.Errorf("The resumed game should be removed from the saved games got: %v, %v", savedGame, err)
+	}
+	select {
+	case err = <-errCh:
+		t.Errorf("Unexpected error: %v", err)
+	default:
+	}
+}
+
+func TestDeletePlayer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battdelete")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	errCh := make(chan error, 100)
+	g := newTestServer(t, dir, errCh)
+	defer g.Stop()
+	players := [2]*testPlayer{joinTestPlayer(t, g, 1, "Alice", errCh), joinTestPlayer(t, g, 2, "Bob", errCh)}
+	pause := func() {
+		players[0].act(NewAction(ACTIDSave))
+		players[1].waitFor(JTPlaying, func(data interface{}) bool {
+			return data.(*PlayingChData).PauseReqID == players[0].id
+		})
+		players[1].act(NewAction(ACTIDSaveAccept))
+		for _, p := range players {
+			p.waitFor(JTPlaying, func(data interface{}) bool {
+				return data.(*PlayingChData).ViewPos.LastMoveType.IsPause()
+			})
+		}
+	}
+	playTestMoves(players, startTestGame(players, NewAction(ACTIDInvite)), 4)
+	pause()
+	waitSavedGame(t, g, [2]int{1, 2})
+	g.DeletePlayer(1)
+	g.DeletePlayer(3) //Wait for the tables server
+	if savedGames, err := loadSavedGames(g.tables.savedGamesDb, 2); err != nil || len(savedGames) != 0 {
+		t.Errorf("The saved game of a deleted player should be deleted got: %v, %v", savedGames, err)
+	}
+
+	playTestMoves(players, startTestGame(players, NewAction(ACTIDInvite)), 2)
+	g.DeletePlayer(1)
+	pause()
+	timeout := time.After(testPlayerWAIT)
+	for len(g.Tables()) != 0 {
+		select {
+		case <-timeout:
+			t.Fatal("The game never finished")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if savedGame, err := loadSavedGame(g.tables.savedGamesDb, 1, 2); err != nil || savedGame != nil {
+		t.Errorf("The game of a deleted player should not be saved got: %v, %v", savedGame, err)
+	}
+	select {
+	case err = <-errCh:
+		t.Errorf("Unexpected error: %v", err)
+	default:
+	}
+}
+
+func TestWatchOwnGame(t *testing.T) {
+	joinWatchChCl := NewJoinWatchChCl()
+	readList := map[string]*PubData{
+		"1": {ID: 1, Opp: 2, JoinWatchChCl: joinWatchChCl},
+		"2": {ID: 2, Opp: 1, JoinWatchChCl: joinWatchChCl},
+	}
+	sendCh := make(chan interface{}, 10)
+	for _, watchID := range []int{1, 2} {
+		act := NewAction(ACTIDWatch)
+		act.ID = watchID
+		actWatch(make(map[int]*JoinWatchChCl), act, make(chan *playerWatchGameData), make(chan struct{}), readList, sendCh, 1)
+		mess, isMess := (<-sendCh).(*MesData)
+		if !isMess || !strings.Contains(mess.Message, "own game") {
+			t.Errorf("Watching own game through %v should be rejected got: %v", watchID, mess)
+		}
+	}
+	select {
+	case watch := <-joinWatchChCl.Channel:
+		t.Errorf("The table should not receive the watch: %v", watch)
+	default:
+	}
+}
+
+func TestChatLimits(t *testing.T) {
+	errCh := make(chan error, 10)
+	player := &Player{id: 1, name: "Alice", errCh: errCh}
+	gameState := new(GameState)
+	gameState.respCh = make(chan int)
+	chatChCl := NewChatChCl()
+	gameState.chatChCl = chatChCl
+	sendCh := make(chan interface{}, 10)
+	act := NewAction(ACTIDChat)
+	act.Mess = strings.Repeat("x", chatMessSIZE+1)
+	actChat(act, nil, sendCh, player, gameState, nil)
+	if errMess, isErr := (<-sendCh).(*ErrMess); !isErr || len(errCh) != 1 {
+		t.Errorf("Long chat message should be rejected got: %v", errMess)
+	}
+	<-errCh
+	act.Mess = "Hi"
+	for i := 0; i < chatRateLIMIT; i++ {
+		actChat(act, nil, sendCh, player, gameState, nil)
+		<-chatChCl.Channel
+	}
+	actChat(act, nil, sendCh, player, gameState, nil)
+	if errMess, isErr := (<-sendCh).(*ErrMess); !isErr || len(errCh) != 1 {
+		t.Errorf("Chat over the rate limit should be rejected got: %v", errMess)
+	}
+	if !player.isChatAllowed(time.Now().Add(chatRateWINDOW)) {
+		t.Error("Chat should be allowed when the window have passed")
+	}
+}
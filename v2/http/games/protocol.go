@@ -0,0 +1,327 @@
+package games
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//ProtocolVERSION is the current protocol version. Clients that does
+	//not send a hello use version 1.
+	ProtocolVERSION = 3
+
+	//CapChat the table chat capability.
+	CapChat = "chat"
+	//CapErrors the error message capability, clients without it receives
+	//the errors as system messages.
+	CapErrors = "errors"
+	//CapDelta the delta capability, the playing and watching messages
+	//carries a sequence number and the view position is replaced with
+	//a delta between snapshots.
+	CapDelta = "delta"
+	//CapBinary the binary wire encoding capability, only websocket
+	//connections support it.
+	CapBinary = "binary"
+)
+
+//ProtocolCaps is the capabilities the server supports.
+var ProtocolCaps = []string{CapChat, CapErrors, CapDelta, CapBinary}
+
+//Hello is the server answer to a client hello action. Version is the
+//negotiated protocol version and Caps the accepted capabilities.
+type Hello struct {
+	Version       int
+	ServerVersion int
+	Caps          []string
+}
+
+//ErrMess is a error message send to the client when a action fails.
+type ErrMess struct {
+	ActType int
+	Message string
+}
+
+//sendErrMess sends a error message to the client.
+func sendErrMess(sendCh chan<- interface{}, actType int, txt string) {
+	sendCh <- &ErrMess{ActType: actType, Message: txt}
+}
+
+//clientProtocol is the protocol a client uses. The player server sends it
+//to netWrite after the hello so the messages is sent in the protocol.
+type clientProtocol struct {
+	version int
+	caps    map[string]bool
+}
+
+//newClientProtocol creates the negotiated protocol of a client. The
+//unknown capabilities is left out.
+func newClientProtocol(version int, caps []string) (p *clientProtocol) {
+	p = new(clientProtocol)
+	p.version = version
+	if p.version > ProtocolVERSION {
+		p.version = ProtocolVERSION
+	}
+	p.caps = make(map[string]bool)
+	for _, capability := range caps {
+		for _, serverCap := range ProtocolCaps {
+			if capability == serverCap {
+				p.caps[capability] = true
+			}
+		}
+	}
+	return p
+}
+
+//legacyProtocol is the protocol of clients without a hello.
+func legacyProtocol() *clientProtocol {
+	return newClientProtocol(1, nil)
+}
+
+//capList returns the capabilities in server order.
+func (p *clientProtocol) capList() (caps []string) {
+	caps = make([]string, 0, len(p.caps))
+	for _, capability := range ProtocolCaps {
+		if p.caps[capability] {
+			caps = append(caps, capability)
+		}
+	}
+	return caps
+}
+
+//convert converts a message to the client protocol. isSend is false if
+//the client does not support the message. Error messages is converted
+//to system messages.
+func (p *clientProtocol) convert(jdata *JsonData) (converted *JsonData, isSend bool) {
+	schema, isFound := messageSchemas[jdata.JsonType]
+	if !isFound || schema.Version <= p.version && (len(schema.Capability) == 0 || p.caps[schema.Capability]) {
+		return jdata, true
+	}
+	if errMess, isErr := jdata.Data.(*ErrMess); isErr {
+		mess := &MesData{SenderID: sysSenderID, SenderName: "System", Message: errMess.Message}
+		return &JsonData{JsonType: JTMess, Data: mess}, true
+	}
+	return jdata, false
+}
+
+//actHello handles the client hello, the negotiated protocol is send to
+//netWrite before the answer.
+func actHello(act *Action, sendCh chan<- interface{}, conn Conn) (isOk bool) {
+	if act.Version < 1 {
+		return false
+	}
+	p := newClientProtocol(act.Version, act.Caps)
+	if _, isBinary := conn.(BinaryConn); !isBinary {
+		delete(p.caps, CapBinary)
+	}
+	sendCh <- p
+	sendCh <- &Hello{Version: p.version, ServerVersion: ProtocolVERSION, Caps: p.capList()}
+	return true
+}
+
+//ProtocolSchema is the machine readable description of the protocol.
+//The messages is send from the server as JsonData and the actions
+//is send from the client. The data types is described in a json
+//schema subset.
+type ProtocolSchema struct {
+	Version      int
+	Capabilities []string
+	Messages     []*MessageSchema
+	Actions      []*MessageSchema
+	Definitions  map[string]*TypeSchema
+}
+
+//MessageSchema is the description of a message or action type.
+//Version is the protocol version the type was added and Capability
+//the capability the client needs to receive it, empty for all clients.
+type MessageSchema struct {
+	ID         int
+	Name       string
+	Version    int
+	Capability string `json:",omitempty"`
+	Data       *TypeSchema
+}
+
+//TypeSchema is a json schema of a type.
+type TypeSchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Items                *TypeSchema            `json:"items,omitempty"`
+	Properties           map[string]*TypeSchema `json:"properties,omitempty"`
+	AdditionalProperties *TypeSchema            `json:"additionalProperties,omitempty"`
+}
+
+//protocolMessage is the protocol information of a message type.
+type protocolMessage struct {
+	ID         int
+	Name       string
+	Version    int
+	Capability string
+	data       interface{}
+}
+
+var (
+	//messageSchemas the server messages by json type.
+	messageSchemas = map[int]*protocolMessage{
+		JTMess:          {JTMess, "Mess", 1, "", &MesData{}},
+		JTInvite:        {JTInvite, "Invite", 1, "", &Invite{}},
+		JTPlaying:       {JTPlaying, "Playing", 1, "", &PlayingChData{}},
+		JTWatching:      {JTWatching, "Watching", 1, "", &WatchingChData{}},
+		JTList:          {JTList, "List", 1, "", map[string]*PubData{}},
+		JTCloseCon:      {JTCloseCon, "CloseCon", 1, "", CloseCon{}},
+		JTClearInvites:  {JTClearInvites, "ClearInvites", 1, "", ClearInvites("")},
+		JTSavedGames:    {JTSavedGames, "SavedGames", 2, "", SavedGames{}},
+		JTChat:          {JTChat, "Chat", 2, CapChat, &TableChat{}},
+		JTHello:         {JTHello, "Hello", 2, "", &Hello{}},
+		JTError:         {JTError, "Error", 2, CapErrors, &ErrMess{}},
+		JTPlayingDelta:  {JTPlayingDelta, "PlayingDelta", 3, CapDelta, &PlayingChData{}},
+		JTWatchingDelta: {JTWatchingDelta, "WatchingDelta", 3, CapDelta, &WatchingChData{}},
+	}
+	//actionSchemas the client actions, all actions is a Action.
+	actionSchemas = []*protocolMessage{
+		{ACTIDMess, "Mess", 1, "", nil},
+		{ACTIDInvite, "Invite", 1, "", nil},
+		{ACTIDInvAccept, "InvAccept", 1, "", nil},
+		{ACTIDInvDecline, "InvDecline", 1, "", nil},
+		{ACTIDInvRetract, "InvRetract", 1, "", nil},
+		{ACTIDMove, "Move", 1, "", nil},
+		{ACTIDQuit, "Quit", 1, "", nil},
+		{ACTIDWatch, "Watch", 1, "", nil},
+		{ACTIDWatchStop, "WatchStop", 1, "", nil},
+		{ACTIDList, "List", 1, "", nil},
+		{ACTIDSave, "Save", 1, "", nil},
+		{ACTIDSaveAccept, "SaveAccept", 2, "", nil},
+		{ACTIDSaveDecline, "SaveDecline", 2, "", nil},
+		{ACTIDRematch, "Rematch", 2, "", nil},
+		{ACTIDChat, "Chat", 2, CapChat, nil},
+		{ACTIDHello, "Hello", 2, "", nil},
+		{ACTIDResync, "Resync", 3, CapDelta, nil},
+	}
+	schemaOnce = new(sync.Once)
+	schema     *ProtocolSchema
+)
+
+//Schema returns the protocol schema.
+func Schema() *ProtocolSchema {
+	schemaOnce.Do(func() {
+		schema = createSchema()
+	})
+	return schema
+}
+
+func createSchema() (s *ProtocolSchema) {
+	s = new(ProtocolSchema)
+	s.Version = ProtocolVERSION
+	s.Capabilities = ProtocolCaps
+	s.Definitions = make(map[string]*TypeSchema)
+	ids := make([]int, 0, len(messageSchemas))
+	for id := range messageSchemas {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		m := messageSchemas[id]
+		s.Messages = append(s.Messages, &MessageSchema{
+			ID:         m.ID,
+			Name:       m.Name,
+			Version:    m.Version,
+			Capability: m.Capability,
+			Data:       typeSchema(reflect.TypeOf(m.data), s.Definitions),
+		})
+	}
+	actSchema := typeSchema(reflect.TypeOf(Action{}), s.Definitions)
+	for _, a := range actionSchemas {
+		s.Actions = append(s.Actions, &MessageSchema{
+			ID:         a.ID,
+			Name:       a.Name,
+			Version:    a.Version,
+			Capability: a.Capability,
+			Data:       actSchema,
+		})
+	}
+	return s
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+//typeSchema returns the json schema of a type, named structs is added
+//to the definitions and referenced.
+func typeSchema(t reflect.Type, defs map[string]*TypeSchema) (s *TypeSchema) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &TypeSchema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &TypeSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &TypeSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &TypeSchema{Type: "number"}
+	case reflect.String:
+		return &TypeSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &TypeSchema{Type: "array", Items: typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return &TypeSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), defs)}
+	case reflect.Struct:
+		if t.Implements(marshalerType) {
+			return &TypeSchema{}
+		}
+		name := t.Name()
+		if len(name) == 0 {
+			return structSchema(t, defs)
+		}
+		if _, isFound := defs[name]; !isFound {
+			defs[name] = nil //Recursive types.
+			defs[name] = structSchema(t, defs)
+		}
+		return &TypeSchema{Ref: "#/definitions/" + name}
+	}
+	return &TypeSchema{}
+}
+
+//structSchema returns the schema of the exported json fields of a struct.
+func structSchema(t reflect.Type, defs map[string]*TypeSchema) (s *TypeSchema) {
+	s = &TypeSchema{Type: "object", Properties: make(map[string]*TypeSchema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" || len(field.PkgPath) != 0 && !field.Anonymous {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && len(tag) == 0 && fieldType.Kind() == reflect.Struct {
+			for name, prop := range structSchema(fieldType, defs).Properties {
+				s.Properties[name] = prop
+			}
+			continue
+		}
+		if len(field.PkgPath) != 0 {
+			continue
+		}
+		switch fieldType.Kind() {
+		case reflect.Chan, reflect.Func:
+			continue
+		}
+		name := field.Name
+		if len(tag) != 0 {
+			name = tag
+		}
+		s.Properties[name] = typeSchema(field.Type, defs)
+	}
+	return s
+}
@@ -0,0 +1,103 @@
+package games
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type testConn struct {
+	sent []*JsonData
+}
+
+func (c *testConn) Send(data *JsonData) error {
+	c.sent = append(c.sent, data)
+	return nil
+}
+func (c *testConn) Receive(act *Action, deadline time.Time) error { return nil }
+func (c *testConn) Close() error                                  { return nil }
+
+func runNetWrite(messages ...interface{}) []*JsonData {
+	conn := new(testConn)
+	dataCh := make(chan interface{}, len(messages)+1)
+	doneCh := make(chan struct{})
+	for _, mess := range messages {
+		dataCh <- mess
+	}
+	dataCh <- CloseCon{isPlayer: false, Reason: "Done"}
+	go netWrite(conn, dataCh, make(chan error, 1), make(chan struct{}), doneCh, 1, nil)
+	<-doneCh
+	return conn.sent
+}
+
+func TestProtocolLegacyClient(t *testing.T) {
+	sent := runNetWrite(
+		&MesData{SenderID: 2, Message: "Hi"},
+		SavedGames{},
+		&TableChat{},
+		&ErrMess{ActType: ACTIDMove, Message: "Illegal Move"},
+	)
+	if len(sent) != 3 {
+		t.Fatalf("Expected mess, error and close got: %v", sent)
+	}
+	if sent[1].JsonType != JTMess || sent[1].Data.(*MesData).SenderID != sysSenderID {
+		t.Errorf("Error should be a system message got: %v", sent[1])
+	}
+	if sent[2].JsonType != JTCloseCon {
+		t.Errorf("Expected close got: %v", sent[2])
+	}
+}
+
+func TestProtocolHello(t *testing.T) {
+	sendCh := make(chan interface{}, 10)
+	act := NewAction(ACTIDHello)
+	act.Version = ProtocolVERSION + 1
+	act.Caps = []string{CapErrors, "future"}
+	if !actHello(act, sendCh, new(testConn)) {
+		t.Fatal("Hello failed")
+	}
+	sendCh <- &TableChat{}
+	sendCh <- &ErrMess{ActType: ACTIDMove, Message: "Illegal Move"}
+	close(sendCh)
+	messages := make([]interface{}, 0, 4)
+	for mess := range sendCh {
+		messages = append(messages, mess)
+	}
+	sent := runNetWrite(messages...)
+	if len(sent) != 3 {
+		t.Fatalf("Expected hello, error and close got: %v", sent)
+	}
+	hello := sent[0].Data.(*Hello)
+	if sent[0].JsonType != JTHello || hello.Version != ProtocolVERSION ||
+		len(hello.Caps) != 1 || hello.Caps[0] != CapErrors {
+		t.Errorf("Unexpected hello: %v", hello)
+	}
+	if sent[1].JsonType != JTError {
+		t.Errorf("Expected error message got: %v", sent[1])
+	}
+	if actHello(NewAction(ACTIDHello), make(chan interface{}, 2), new(testConn)) {
+		t.Error("Hello without version should fail")
+	}
+}
+
+func TestProtocolSchema(t *testing.T) {
+	schema := Schema()
+	if len(schema.Messages) != len(messageSchemas) {
+		t.Errorf("Expected %v messages got: %v", len(messageSchemas), len(schema.Messages))
+	}
+	for i, m := range schema.Messages {
+		if m.Data == nil || i > 0 && m.ID <= schema.Messages[i-1].ID {
+			t.Errorf("Message %v is missing data or out of order: %v", m.ID, m)
+		}
+	}
+	if len(schema.Actions) != ACTIDResync {
+		t.Errorf("Expected %v actions got: %v", ACTIDResync, len(schema.Actions))
+	}
+	chat := schema.Definitions["TableChat"]
+	if chat == nil || chat.Properties["Message"] == nil || chat.Properties["PlayingIDs"] == nil {
+		t.Errorf("Table chat schema missing fields: %v", chat)
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		t.Errorf("Marshal schema failed: %v", err)
+	}
+}
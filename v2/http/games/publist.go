@@ -20,6 +20,8 @@ type PubList struct {
 	games   map[int]*GameData
 	players map[int]*PlayerData
 	list    map[string]*PubData
+	events  *EventLog
+	metrics *gameMetrics
 }
 
 //NewList create a list.
@@ -29,6 +31,9 @@ func NewList() (list *PubList) {
 	list.games = make(map[int]*GameData)
 	list.players = make(map[int]*PlayerData)
 	list.list = make(map[string]*PubData)
+	list.events = NewEventLog()
+	list.events.names = list.playerNames
+	list.metrics = newGameMetrics()
 	return list
 }
 
@@ -45,11 +50,46 @@ func (list *PubList) UpdateGames(newgames map[int]*GameData) {
 //newplayers is used directly so it must be a copy.
 func (list *PubList) UpdatePlayers(newplayers map[int]*PlayerData) {
 	list.lock.Lock()
+	list.events.addPlayers(list.players, newplayers)
 	list.players = newplayers
 	list.update()
 	list.lock.Unlock()
 }
 
+//Events returns the public event log.
+func (list *PubList) Events() *EventLog {
+	return list.events
+}
+
+//playerNames returns the names of the players.
+func (list *PubList) playerNames(ids [2]int) (names [2]string) {
+	list.lock.RLock()
+	for i, id := range ids {
+		if player, isFound := list.players[id]; isFound {
+			names[i] = player.Name
+		}
+	}
+	list.lock.RUnlock()
+	return names
+}
+
+//ReadGame get the current game data of a player.
+func (list *PubList) ReadGame(playerID int) (gdata *GameData, isFound bool) {
+	list.lock.RLock()
+	gdata, isFound = list.games[playerID]
+	list.lock.RUnlock()
+	return gdata, isFound
+}
+
+//ReadGames get the current games. The map is a multible used map.
+//So no change.
+func (list *PubList) ReadGames() (games map[int]*GameData) {
+	list.lock.RLock()
+	games = list.games
+	list.lock.RUnlock()
+	return games
+}
+
 //Read get the current public list. The list is a multible used map.
 //So no change.
 func (list *PubList) Read() (publist map[string]*PubData) {
@@ -74,6 +114,7 @@ func (list *PubList) update() {
 		data.InviteCh = v.InviteCh
 		data.DoneComCh = v.DoneComCh
 		data.MessageCh = v.MessageCh
+		data.IsBot = v.IsBot
 		gdata, isGameFound = list.games[key]
 		if isGameFound {
 			opp, isOppFound = list.players[gdata.Opp] // opponent may have left
@@ -81,6 +122,7 @@ func (list *PubList) update() {
 				data.Opp = gdata.Opp
 				data.OppName = opp.Name
 				data.JoinWatchChCl = gdata.JoinWatchChCl
+				data.ChatChCl = gdata.ChatChCl
 			}
 		}
 		publist[strconv.Itoa(key)] = data
@@ -98,6 +140,8 @@ type PubData struct {
 	Opp           int
 	OppName       string
 	JoinWatchChCl *JoinWatchChCl `json:"-"`
+	ChatChCl      *ChatChCl      `json:"-"`
+	IsBot         bool
 }
 
 //GameData is the game information in the game map.
@@ -105,6 +149,83 @@ type PubData struct {
 type GameData struct {
 	Opp           int
 	JoinWatchChCl *JoinWatchChCl
+	ConnChCl      *ConnChCl
+	PauseChCl     *PauseChCl
+	ChatChCl      *ChatChCl
+}
+
+//ConnChData is the information send to a table when a player
+//lose the connection or reconnects.
+type ConnChData struct {
+	PlayerID int
+	PlayerCh chan<- *PlayingChData //nil when the connection is lost.
+}
+
+//ConnChCl the table connection channel and its close channel.
+type ConnChCl struct {
+	Channel chan *ConnChData
+	Close   chan struct{}
+}
+
+//NewConnChCl creates a new connection channel.
+func NewConnChCl() (c *ConnChCl) {
+	c = new(ConnChCl)
+	c.Channel = make(chan *ConnChData)
+	c.Close = make(chan struct{})
+	return c
+}
+
+//PauseChData is the information send to a table to request, accept
+//or decline a pause of the game. A request when a pause is already
+//requested by the opponent is an accept. A forced pause is from the server.
+type PauseChData struct {
+	PlayerID  int
+	IsDecline bool
+	IsForce   bool
+}
+
+//PauseChCl the table pause channel and its close channel.
+type PauseChCl struct {
+	Channel chan *PauseChData
+	Close   chan struct{}
+}
+
+//NewPauseChCl creates a new pause channel.
+func NewPauseChCl() (p *PauseChCl) {
+	p = new(PauseChCl)
+	p.Channel = make(chan *PauseChData)
+	p.Close = make(chan struct{})
+	return p
+}
+
+//ChatChData is a chat message send to a table. If the sender is not
+//one of the players the message is a spectator message.
+type ChatChData struct {
+	SenderID   int
+	SenderName string
+	Message    string
+}
+
+//ChatChCl the table chat channel and its close channel.
+type ChatChCl struct {
+	Channel chan *ChatChData
+	Close   chan struct{}
+}
+
+//NewChatChCl creates a new chat channel.
+func NewChatChCl() (c *ChatChCl) {
+	c = new(ChatChCl)
+	c.Channel = make(chan *ChatChData)
+	c.Close = make(chan struct{})
+	return c
+}
+
+//TableChat is a table chat message send to the client.
+//WatchingID is zero when the player is playing the game.
+type TableChat struct {
+	PlayingIDs [2]int
+	WatchingID int
+	*bg.ChatMess
 }
 
 //JoinWatchChData is the information send to a table to start
@@ -134,6 +255,13 @@ type WatchingChData struct {
 	PlayingIDs [2]int
 	WatchingID int
 	GameTs     time.Time
+	IsConnLost [2]bool
+	Match      *Match
+	Chat       *bg.ChatMess     //When set the data is only a chat message.
+	Hist       *WatchHist       //The catch-up history, only set on the first data.
+	Seq        int64            `json:",omitempty"` //The stream sequence number of delta clients.
+	Delta      *bg.ViewPosDelta `json:",omitempty"` //Replaces the view position in a delta message.
+	histAdd    *WatchHist       //The history added since the last data, the bench keeps it for the catch-up.
 }
 
 //PlayerData the public list player information.
@@ -144,6 +272,7 @@ type PlayerData struct {
 	DoneComCh chan struct{}   //Used by all send to player
 	MessageCh chan<- *MesData //never closed
 	BootCh    chan struct{}   //For server to boot player
+	IsBot     bool
 }
 
 //Invite the invite data.
@@ -156,6 +285,9 @@ type Invite struct {
 	ReceiverID   int
 	ReceiverName string
 	IsRejected   bool                   //TODO MAYBE add reason
+	SavedGame    *SavedGame             //The saved game to resume, nil for a new game.
+	Match        *Match                 //The match, nil for a single game.
+	WatchDelay   int                    //The spectator delay in seconds.
 	ResponseCh   chan<- *InviteResponse `json:"-"` //Common for all invitaion
 	RetractCh    chan struct{}          `json:"-"` //Per invite
 	DoneComCh    chan struct{}          `json:"-"`
@@ -178,11 +310,20 @@ type InviteResponse struct {
 
 //PlayingChData the information send from the table to the players.
 //The players view of the game position and the move channel
-// only set ones on the first send.
+// only set ones on the first send, or when the player reconnects.
 type PlayingChData struct {
 	ViewPos          *bg.ViewPos
 	PlayingIDs       [2]int
 	GameTs           time.Time
 	FailedClaimedExs [9][]card.Card
-	MoveCh           chan<- int `json:"-"`
+	IsOppConnLost    bool
+	PauseReqID       int              //The player that requested a pause, zero if none.
+	Match            *Match           //The match, nil for a single game.
+	Chat             *bg.ChatMess     //When set the data is only a chat message.
+	Seq              int64            `json:",omitempty"` //The stream sequence number of delta clients.
+	Delta            *bg.ViewPosDelta `json:",omitempty"` //Replaces the view position in a delta message.
+	MoveCh           chan<- int       `json:"-"`
+	ConnChCl         *ConnChCl        `json:"-"`
+	PauseChCl        *PauseChCl       `json:"-"`
+	ChatChCl         *ChatChCl        `json:"-"`
 }
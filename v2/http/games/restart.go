@@ -0,0 +1,85 @@
+package games
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/db/dbhist"
+	"github.com/rezder/go-error/log"
+	"sync"
+	"time"
+)
+
+var (
+	//restartOfferDELAY the time a reconnecting player waits before
+	//offering the interrupted games, so the players list is updated.
+	restartOfferDELAY = time.Second
+)
+
+//restartList is the restart markers of the games saved when the server
+//was drained. The games is offered to the players when they reconnect
+//after the restart. The markers is saved in the saved games database.
+type restartList struct {
+	mu     *sync.Mutex
+	db     *bolt.DB
+	bucket []byte
+	pairs  playerPairs
+}
+
+//newRestartList creates the restart list and loads the markers.
+func newRestartList(db *bolt.DB) (r *restartList, err error) {
+	r = new(restartList)
+	r.mu = new(sync.Mutex)
+	r.db = db
+	r.bucket = []byte("RestartBucket")
+	r.pairs = make(playerPairs)
+	err = db.Update(func(tx *bolt.Tx) error {
+		bck, txErr := tx.CreateBucketIfNotExists(r.bucket)
+		if txErr != nil {
+			return errors.Wrapf(txErr, log.ErrNo(1)+"Creating bucket %v", string(r.bucket))
+		}
+		return bck.ForEach(func(k, v []byte) error {
+			r.pairs.add(keyIDs(k))
+			return nil
+		})
+	})
+	return r, err
+}
+
+//add adds a restart marker.
+func (r *restartList) add(ids [2]int) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(r.bucket).Put(dbhist.KeyPlayerIDs(ids), []byte{1})
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Saving restart marker for players %v failed", ids)
+	}
+	r.pairs.add(ids)
+	return err
+}
+
+//opps returns the opponents of the player with a restart marker.
+func (r *restartList) opps(playerID int) (oppIDs []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pairs.opps(playerID)
+}
+
+//take removes a restart marker, isFound is false if the marker
+//does not exist.
+func (r *restartList) take(ids [2]int) (isFound bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	isFound = r.pairs.remove(ids)
+	if !isFound {
+		return isFound, err
+	}
+	err = r.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(r.bucket).Delete(dbhist.KeyPlayerIDs(ids))
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "Deleting restart marker for players %v failed", ids)
+	}
+	return isFound, err
+}
@@ -0,0 +1,122 @@
+package games
+
+import (
+	"github.com/boltdb/bolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRestartList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battrestart")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "savegames.db")
+	db, err := bolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatalf("Open database failed: %v", err)
+	}
+	restarts, err := newRestartList(db)
+	if err != nil {
+		t.Fatalf("Creating restart list failed: %v", err)
+	}
+	if err = restarts.add([2]int{3, 1}); err != nil {
+		t.Fatalf("Adding marker failed: %v", err)
+	}
+	if err = restarts.add([2]int{1, 5}); err != nil {
+		t.Fatalf("Adding marker failed: %v", err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatalf("Closing database failed: %v", err)
+	}
+	db, err = bolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatalf("Open database failed: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+	restarts, err = newRestartList(db)
+	if err != nil {
+		t.Fatalf("Loading restart list failed: %v", err)
+	}
+	if opps := restarts.opps(1); len(opps) != 2 {
+		t.Errorf("Player 1 should have two restart games got: %v", opps)
+	}
+	if opps := restarts.opps(3); len(opps) != 1 || opps[0] != 1 {
+		t.Errorf("Player 3 should have a restart game with 1 got: %v", opps)
+	}
+	isFound, err := restarts.take([2]int{1, 3})
+	if err != nil || !isFound {
+		t.Errorf("Take marker failed: %v, %v", isFound, err)
+	}
+	isFound, _ = restarts.take([2]int{3, 1})
+	if isFound {
+		t.Error("Marker should only be taken once")
+	}
+	if opps := restarts.opps(3); len(opps) != 0 {
+		t.Errorf("Player 3 should not have restart games got: %v", opps)
+	}
+}
+
+func TestRestartOffer(t *testing.T) {
+	defer func(delay time.Duration) { restartOfferDELAY = delay }(restartOfferDELAY)
+	restartOfferDELAY = 10 * time.Millisecond
+	dir, err := ioutil.TempDir("", "battrestart")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	errCh := make(chan error, 100)
+	g := newTestServer(t, dir, errCh)
+	players := [2]*testPlayer{joinTestPlayer(t, g, 1, "Alice", errCh), joinTestPlayer(t, g, 2, "Bob", errCh)}
+	views := playTestMoves(players, startTestGame(players, NewAction(ACTIDInvite)), 4)
+	g.Drain(10 * time.Millisecond)
+	select {
+	case <-g.DrainedCh():
+	case <-time.After(testPlayerWAIT):
+		t.Fatal("Drain timed out")
+	}
+	g.Stop()
+
+	g = newTestServer(t, dir, errCh)
+	defer g.Stop()
+	players = [2]*testPlayer{joinTestPlayer(t, g, 1, "Alice", errCh), joinTestPlayer(t, g, 2, "Bob", errCh)}
+	var invite *Invite
+	timeout := time.After(testPlayerWAIT)
+	for invite == nil {
+		var jdata *JsonData
+		select {
+		case jdata = <-players[0].conn.msgCh:
+		case jdata = <-players[1].conn.msgCh:
+		case <-timeout:
+			t.Fatal("The interrupted game was not offered")
+		}
+		if jdata.JsonType == JTInvite && !jdata.Data.(*Invite).IsRejected {
+			invite = jdata.Data.(*Invite)
+		}
+	}
+	if invite.SavedGame == nil {
+		t.Fatalf("The restart offer should resume the saved game got: %v", invite)
+	}
+	accept := NewAction(ACTIDInvAccept)
+	accept.ID = invite.InvitorID
+	for _, p := range players {
+		if p.id == invite.ReceiverID {
+			p.act(accept)
+		}
+	}
+	for i, p := range players {
+		resumed := p.waitPlaying()
+		if resumed.ViewPos.CardPos != views[i].ViewPos.CardPos || resumed.ViewPos.ConePos != views[i].ViewPos.ConePos {
+			t.Errorf("Player %v resumed position differs from the drained position\n%v\n%v", p.id, resumed.ViewPos, views[i].ViewPos)
+		}
+	}
+	select {
+	case err = <-errCh:
+		t.Errorf("Unexpected error: %v", err)
+	default:
+	}
+}
@@ -0,0 +1,119 @@
+package games
+
+import (
+	"encoding/binary"
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/db/dbhist"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"sync"
+)
+
+//savedGameDb is the saved games database. It keeps a index of the
+//opponents of the saved games so the saved games of a player can be
+//loaded without scanning the database.
+type savedGameDb struct {
+	*dbhist.Db
+	mu    *sync.Mutex
+	pairs playerPairs
+}
+
+//newSavedGameDb creates the saved games database and loads the index.
+func newSavedGameDb(db *bolt.DB) (s *savedGameDb, err error) {
+	s = new(savedGameDb)
+	s.Db = dbhist.New(dbhist.KeyPlayers, db, 500)
+	s.mu = new(sync.Mutex)
+	s.pairs = make(playerPairs)
+	err = s.Init()
+	if err != nil {
+		return s, err
+	}
+	keys, err := s.Keys()
+	if err != nil {
+		err = errors.Wrap(err, "Loading saved games index failed")
+		return s, err
+	}
+	for _, key := range keys {
+		s.pairs.add(keyIDs(key))
+	}
+	return s, err
+}
+
+//Put saves a game.
+func (s *savedGameDb) Put(hist *bg.Hist) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.Db.Put(hist)
+	if err == nil {
+		s.pairs.add(hist.PlayerIDs)
+	}
+	return err
+}
+
+//Delete deletes a saved game.
+func (s *savedGameDb) Delete(key []byte) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.Db.Delete(key)
+	if err == nil {
+		s.pairs.remove(keyIDs(key))
+	}
+	return err
+}
+
+//opps returns the opponents of the player with a saved game.
+func (s *savedGameDb) opps(playerID int) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pairs.opps(playerID)
+}
+
+//isSaved returns true if the players have a saved game.
+func (s *savedGameDb) isSaved(ids [2]int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pairs[ids[0]][ids[1]]
+}
+
+//playerPairs is the opponents of the players.
+type playerPairs map[int]map[int]bool
+
+func (pairs playerPairs) add(ids [2]int) {
+	for i, id := range ids {
+		opps, isFound := pairs[id]
+		if !isFound {
+			opps = make(map[int]bool)
+			pairs[id] = opps
+		}
+		opps[ids[opp(i)]] = true
+	}
+}
+
+//remove removes a pair, isFound is false if the pair does not exist.
+func (pairs playerPairs) remove(ids [2]int) (isFound bool) {
+	if !pairs[ids[0]][ids[1]] {
+		return isFound
+	}
+	isFound = true
+	for i, id := range ids {
+		delete(pairs[id], ids[opp(i)])
+		if len(pairs[id]) == 0 {
+			delete(pairs, id)
+		}
+	}
+	return isFound
+}
+
+func (pairs playerPairs) opps(playerID int) (oppIDs []int) {
+	for oppID := range pairs[playerID] {
+		oppIDs = append(oppIDs, oppID)
+	}
+	return oppIDs
+}
+
+//keyIDs decodes a player ids key.
+func keyIDs(key []byte) (ids [2]int) {
+	ids[0] = int(binary.BigEndian.Uint64(key[:8]))
+	ids[1] = int(binary.BigEndian.Uint64(key[8:]))
+	return ids
+}
@@ -0,0 +1,60 @@
+package games
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/rezder/go-battleline/v2/db/dbhist"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSavedGameDb(t *testing.T) {
+	dir, err := ioutil.TempDir("", "battsaved")
+	if err != nil {
+		t.Fatalf("Creating temp dir failed: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	file := filepath.Join(dir, "savegames.db")
+	db, err := bolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatalf("Open database failed: %v", err)
+	}
+	sdb, err := newSavedGameDb(db)
+	if err != nil {
+		t.Fatalf("Creating saved games database failed: %v", err)
+	}
+	for _, ids := range [][2]int{{3, 1}, {1, 5}, {1, 3}} {
+		if err = sdb.Put(&bg.Hist{PlayerIDs: ids}); err != nil {
+			t.Fatalf("Saving game failed: %v", err)
+		}
+	}
+	if err = sdb.Close(); err != nil {
+		t.Fatalf("Closing database failed: %v", err)
+	}
+	db, err = bolt.Open(file, 0600, nil)
+	if err != nil {
+		t.Fatalf("Open database failed: %v", err)
+	}
+	sdb, err = newSavedGameDb(db)
+	if err != nil {
+		t.Fatalf("Loading saved games database failed: %v", err)
+	}
+	defer func() { _ = sdb.Close() }()
+	if opps := sdb.opps(1); len(opps) != 2 {
+		t.Errorf("Player 1 should have two saved games got: %v", opps)
+	}
+	if opps := sdb.opps(5); len(opps) != 1 || opps[0] != 1 {
+		t.Errorf("Player 5 should have a saved game with 1 got: %v", opps)
+	}
+	if err = sdb.Delete(dbhist.KeyPlayerIDs([2]int{1, 3})); err != nil {
+		t.Fatalf("Deleting saved game failed: %v", err)
+	}
+	if opps := sdb.opps(3); len(opps) != 0 {
+		t.Errorf("Player 3 should not have saved games got: %v", opps)
+	}
+	if opps := sdb.opps(1); len(opps) != 1 || opps[0] != 5 {
+		t.Errorf("Player 1 should have a saved game with 5 got: %v", opps)
+	}
+}
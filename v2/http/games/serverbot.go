@@ -0,0 +1,260 @@
+package games
+
+import (
+	"fmt"
+	"github.com/rezder/go-battleline/v2/bot/prob"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-error/log"
+	"math/rand"
+)
+
+const (
+	//botIdleLIMIT the number of passes and empty claims in a row before
+	//a server bot breaks the stall.
+	botIdleLIMIT = 6
+)
+
+var (
+	//BotStrengthAll is the server bot strength domain.
+	BotStrengthAll BotStrengthAllST
+)
+
+func init() {
+	BotStrengthAll = newBotStrengthAllST()
+}
+
+//BotStrengthAllST is the server bot strength singleton.
+type BotStrengthAllST struct {
+	Easy BotStrength
+	Hard BotStrength
+	All  []BotStrength
+}
+
+func newBotStrengthAllST() (b BotStrengthAllST) {
+	b.Easy = 1
+	b.Hard = 2
+	b.All = []BotStrength{b.Easy, b.Hard}
+	return b
+}
+
+//BotStrength the server bot strength domain value.
+type BotStrength int
+
+func (b BotStrength) String() (txt string) {
+	switch b {
+	case BotStrengthAll.Easy:
+		txt = "Easy"
+	case BotStrengthAll.Hard:
+		txt = "Hard"
+	default:
+		panic(fmt.Sprintf("Bot strength: %v does not exist ", int(b)))
+	}
+	return txt
+}
+
+//ServerBot is a bot player that is hosted in the game server.
+//The bot plays directly against the table without a connection.
+type ServerBot struct {
+	id        int
+	name      string
+	strength  BotStrength
+	inviteCh  <-chan *Invite
+	messCh    <-chan *MesData
+	doneComCh chan struct{}
+	bootCh    chan struct{}
+	noIdles   int     //The number of passes and empty claims in a row with no change of the position.
+	idlePos   *bg.Pos //The position of the last pass or empty claim.
+}
+
+//NewServerBot creates a server bot, id must be the id of the bot account.
+func NewServerBot(id int, name string, strength BotStrength) (bot *ServerBot) {
+	bot = new(ServerBot)
+	bot.id = id
+	bot.name = name
+	bot.strength = strength
+	bot.doneComCh = make(chan struct{})
+	bot.bootCh = make(chan struct{})
+	return bot
+}
+
+//playerData creates the players server list data and joins the bot
+//to the players server.
+func (bot *ServerBot) playerData() (data *PlayerData) {
+	inviteCh := make(chan *Invite)
+	messCh := make(chan *MesData)
+	bot.inviteCh = inviteCh
+	bot.messCh = messCh
+	data = &PlayerData{
+		ID:        bot.id,
+		Name:      bot.name,
+		InviteCh:  inviteCh,
+		DoneComCh: bot.doneComCh,
+		MessageCh: messCh,
+		BootCh:    bot.bootCh,
+		IsBot:     true,
+	}
+	return data
+}
+
+//serve serves the bot until it is booted. The bot accepts all invites
+//when it is not playing and accepts all pause requests.
+func (bot *ServerBot) serve(leaveCh chan<- int) {
+	playerGameCh := make(chan *PlayingChData)
+	rejectCh := make(chan interface{}, 1)
+	gameState := new(GameState)
+	isInvited := false
+Loop:
+	for {
+		select {
+		case <-bot.bootCh:
+			log.Printf(log.DebugMsg, "Server bot %v boot.", bot.name)
+			close(bot.doneComCh)
+			gameState.closeChannel()
+			break Loop
+		case invite := <-bot.inviteCh:
+			if !isInvited && !gameState.hasGame() {
+				isInvited = bot.acceptInvite(invite, playerGameCh, rejectCh)
+			} else {
+				declineInvite(invite, bot.id)
+			}
+		case <-bot.messCh:
+		case <-rejectCh:
+			isInvited = false
+		case playingChData := <-playerGameCh:
+			if playingChData == nil {
+				log.Printf(log.DebugMsg, "Server bot %v game done", bot.name)
+				gameState.removeGame()
+			} else if playingChData.Chat == nil {
+				isInvited = false
+				bot.handleGameReceive(playingChData, gameState)
+			}
+		}
+	}
+	leaveCh <- bot.id
+}
+
+//acceptInvite accepts a invite.
+func (bot *ServerBot) acceptInvite(
+	invite *Invite,
+	playerGameCh chan<- *PlayingChData,
+	rejectCh chan<- interface{}) (isAccepted bool) {
+
+	resp := new(InviteResponse)
+	resp.Responder = bot.id
+	resp.Name = bot.name
+	playingRecCh := make(chan *PlayingChData, 1)
+	resp.PlayingCh = playingRecCh
+	select {
+	case <-invite.RetractCh:
+	default:
+		select {
+		case invite.ResponseCh <- resp:
+			log.Printf(log.DebugMsg, "Server bot %v accept invite: %v", bot.name, invite)
+			go gameListen(playerGameCh, bot.doneComCh, playingRecCh, rejectCh, invite)
+			isAccepted = true
+		case <-invite.DoneComCh:
+		}
+	}
+	return isAccepted
+}
+
+//handleGameReceive handles a game view from the table.
+//The table resends the view when the opponent connection or pause state
+//changes, the bot only moves once per view.
+func (bot *ServerBot) handleGameReceive(playingChData *PlayingChData, gameState *GameState) {
+	isNewView := false
+	if !gameState.hasGame() {
+		gameState.addGame(playingChData, bot.id)
+		bot.resetStall()
+		isNewView = len(playingChData.ViewPos.Moves) > 0
+	} else {
+		gameState.pauseReqID = playingChData.PauseReqID
+		if len(playingChData.ViewPos.Moves) > 0 && !playingChData.ViewPos.IsEqual(gameState.lastViewPos) {
+			gameState.receiveNewViewPos(playingChData.ViewPos)
+			isNewView = true
+		}
+	}
+	if gameState.pauseReqID != 0 && gameState.pauseReqID != bot.id {
+		gameState.pause(bot.id, false)
+	} else if isNewView {
+		moveix := bot.breakStall(gameState.lastViewPos, botMove(gameState.lastViewPos, bot.strength))
+		log.Printf(log.Debug, "Server bot %v move index: %v", bot.name, moveix)
+		gameState.sendMove(moveix)
+	}
+}
+
+//breakStall breaks a stalled game. The game is stalled when both players
+//only pass or make empty claims, the position does not change between
+//the bot moves. After botIdleLIMIT passes or empty claims in a row the bot
+//plays a card or claims all flags, and after twice the limit it gives up.
+func (bot *ServerBot) breakStall(viewPos *bg.ViewPos, moveix int) int {
+	move := viewPos.Moves[moveix]
+	isIdle := len(move.Moves) == 0 &&
+		(move.MoveType == bg.MoveTypeAll.Hand || move.MoveType == bg.MoveTypeAll.Cone)
+	if !isIdle {
+		bot.resetStall()
+		return moveix
+	}
+	if bot.idlePos == nil || bot.idlePos.CardPos != viewPos.CardPos || bot.idlePos.ConePos != viewPos.ConePos {
+		bot.noIdles = 0 //The opponent is not idle.
+	}
+	idlePos := *viewPos.Pos
+	bot.idlePos = &idlePos
+	bot.noIdles++
+	if bot.noIdles > 2*botIdleLIMIT {
+		bot.resetStall()
+		return SMQuit
+	}
+	if bot.noIdles > botIdleLIMIT {
+		if move.MoveType == bg.MoveTypeAll.Hand && len(viewPos.Moves) > 1 {
+			moveix = rand.Intn(len(viewPos.Moves) - 1) //The pass is the last move.
+		} else if move.MoveType == bg.MoveTypeAll.Cone {
+			for i, claim := range viewPos.Moves {
+				if len(claim.Moves) > len(viewPos.Moves[moveix].Moves) {
+					moveix = i
+				}
+			}
+		}
+	}
+	return moveix
+}
+
+//resetStall resets the stall count.
+func (bot *ServerBot) resetStall() {
+	bot.noIdles = 0
+	bot.idlePos = nil
+}
+
+//botMove finds the bot move. The easy bot claims flags but
+//makes random card moves.
+func botMove(viewPos *bg.ViewPos, strength BotStrength) (moveix int) {
+	firstMove := viewPos.Moves[0]
+	switch firstMove.MoveType {
+	case bg.MoveTypeAll.Cone:
+		moveix = prob.MoveClaim(viewPos)
+	case bg.MoveTypeAll.Scout2, bg.MoveTypeAll.Scout3, bg.MoveTypeAll.Deck:
+		if strength == BotStrengthAll.Hard {
+			moveix = prob.MoveDeck(viewPos)
+		} else {
+			moveix = randomMove(viewPos)
+		}
+	case bg.MoveTypeAll.ScoutReturn:
+		if strength == BotStrengthAll.Hard {
+			moveix = prob.MoveScoutReturn(viewPos)
+		} else {
+			moveix = randomMove(viewPos)
+		}
+	default:
+		if strength == BotStrengthAll.Hard {
+			moveix = prob.MoveHand(viewPos)
+		} else {
+			moveix = randomMove(viewPos)
+		}
+	}
+	return moveix
+}
+
+//randomMove picks a random move.
+func randomMove(viewPos *bg.ViewPos) (moveix int) {
+	return rand.Intn(len(viewPos.Moves))
+}
@@ -0,0 +1,83 @@
+package games
+
+import (
+	"testing"
+	"time"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+)
+
+func TestServerBotGame(t *testing.T) {
+	ids := [2]int{1, 2}
+	bots := [2]*ServerBot{
+		NewServerBot(ids[0], "BotEasy1", BotStrengthAll.Easy),
+		NewServerBot(ids[1], "BotHard1", BotStrengthAll.Hard),
+	}
+	var recChs [2]chan *PlayingChData
+	var playerChs [2]chan<- *PlayingChData
+	var gameStates [2]*GameState
+	for i := range recChs {
+		recChs[i] = make(chan *PlayingChData, 1)
+		playerChs[i] = recChs[i]
+		gameStates[i] = new(GameState)
+	}
+	finishCh := make(chan *bg.Game, 1)
+	go tableServe(ids, playerChs, NewJoinWatchChCl(), NewConnChCl(), NewPauseChCl(), NewChatChCl(), nil, nil, 0, time.Minute, newTableStats(), nil, finishCh, make(chan error, 10))
+	for i := range bots {
+		go func(i int) {
+			for data := range recChs[i] {
+				bots[i].handleGameReceive(data, gameStates[i])
+			}
+		}(i)
+	}
+	select {
+	case game := <-finishCh:
+		if game.Pos.LastMoveType.IsPause() {
+			t.Error("Bot game was paused")
+		}
+		if game.Hist.Winner() == dpos.NoPlayer {
+			t.Error("Bot game finished without a winner")
+		}
+	case <-time.After(time.Minute):
+		t.Fatal("Bot game did not finish")
+	}
+}
+
+func TestServerBotBreakStall(t *testing.T) {
+	bot := NewServerBot(1, "BotEasy1", BotStrengthAll.Easy)
+	claim := bg.NewMove(0, bg.MoveTypeAll.Cone)
+	claim.Moves = []*bg.BoardPieceMove{{Index: 3, NewPos: 1, BoardPiece: bg.BoardPieceAll.Cone}}
+	viewPos := &bg.ViewPos{Pos: new(bg.Pos), Moves: []*bg.Move{bg.NewMove(0, bg.MoveTypeAll.Cone), claim}}
+	for i := 0; i < botIdleLIMIT; i++ {
+		if moveix := bot.breakStall(viewPos, 0); moveix != 0 {
+			t.Fatalf("Empty claim %v should not be changed got: %v", i, moveix)
+		}
+	}
+	if moveix := bot.breakStall(viewPos, 0); moveix != 1 {
+		t.Errorf("Stalled bot should claim got: %v", moveix)
+	}
+	for i := 0; i < botIdleLIMIT-1; i++ {
+		bot.breakStall(viewPos, 0)
+	}
+	if moveix := bot.breakStall(viewPos, 0); moveix != SMQuit {
+		t.Errorf("Stalled bot should give up got: %v", moveix)
+	}
+	if moveix := bot.breakStall(viewPos, 1); moveix != 1 || bot.noIdles != 0 {
+		t.Errorf("Claim should reset the stall got: %v, %v", moveix, bot.noIdles)
+	}
+	for i := 0; i < botIdleLIMIT; i++ {
+		bot.breakStall(viewPos, 0)
+	}
+	oppViewPos := &bg.ViewPos{Pos: new(bg.Pos), Moves: viewPos.Moves}
+	oppViewPos.CardPos[5] = dpos.CardAll.Players[1].Flags[2]
+	if moveix := bot.breakStall(oppViewPos, 0); moveix != 0 || bot.noIdles != 1 {
+		t.Errorf("Opponent move should reset the stall got: %v, %v", moveix, bot.noIdles)
+	}
+	bot.noIdles = botIdleLIMIT
+	data := &PlayingChData{ViewPos: &bg.ViewPos{Pos: new(bg.Pos)}, MoveCh: make(chan int, 1)}
+	bot.handleGameReceive(data, new(GameState))
+	if bot.noIdles != 0 || bot.idlePos != nil {
+		t.Errorf("Game start should reset the stall got: %v", bot.noIdles)
+	}
+}
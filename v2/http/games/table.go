@@ -19,11 +19,28 @@ const (
 
 //tableServe runs a table with a game.
 //If resumeGame is nil a new game is started.
+//A player that lost the connection have the connLostGrace time to reconnect
+//on the connChCl before the game is paused.
+//A game is only paused on request if the opponent accepts.
+//match is nil if the game is not part of a match.
+//Chat from the players is send to players and spectators, chat from
+//spectators only to spectators. The chat is saved with the game.
+//The spectators receives the game with the watchDelay.
+//The moves and watchers is counted in the stats.
+//The public game events is added to the events log after the watchDelay.
 func tableServe(
 	ids [2]int,
 	playerChs [2]chan<- *PlayingChData,
 	joinWatchChCl *JoinWatchChCl,
+	connChCl *ConnChCl,
+	pauseChCl *PauseChCl,
+	chatChCl *ChatChCl,
 	resumeGame *bg.Game,
+	match *Match,
+	watchDelay time.Duration,
+	connLostGrace time.Duration,
+	stats *tableStats,
+	events *EventLog,
 	finishCh chan *bg.Game,
 	errCh chan<- error) {
 
@@ -31,31 +48,118 @@ func tableServe(
 	moveixChs[0] = make(chan int)
 	moveixChs[1] = make(chan int)
 	benchCh := make(chan *WatchingChData, 1)
-	go benchServe(joinWatchChCl, benchCh)
+	go benchServe(joinWatchChCl, benchCh, watchDelay, resumeGame != nil, stats, events)
 	game := resumeGame
 	if game == nil {
 		game = bg.NewGame()
 		dealer := rand.Intn(2)
+		if match != nil {
+			dealer = 1
+			if match.DealerID() == ids[0] {
+				dealer = 0
+			}
+		}
 		game.Start(ids, dealer)
+		if match != nil {
+			game.Hist.MatchID = match.ID
+		}
 	}
-	playingChDatas, watchingChData, moves := initChData(game.Pos, game.Hist.PlayerIDs, game.Hist.Time, moveixChs)
+	var connLost [2]bool
+	pauseReqID := 0
+	playingChDatas, watchingChData, moves := initChData(game.Pos, game.Hist.PlayerIDs, game.Hist.Time, moveixChs, connChCl, pauseChCl, chatChCl, match)
 	playerChs[0] <- playingChDatas[0]
 	playerChs[1] <- playingChDatas[1]
+	watchingChData.histAdd = newWatchHist(game.Hist)
 	benchCh <- watchingChData
+	viewTime := time.Now()
 	var moveix int
 	var isOpen bool
 	var mover int
+	var graceDeadlines [2]time.Time
+	var graceTimer *time.Timer
+	var graceCh <-chan time.Time
 	winner := dpos.NoPlayer
 
 	for winner == dpos.NoPlayer {
 		var failedClaimedExs [9][]card.Card
 		mover = moves[0].Mover
 		log.Printf(log.DebugMsg, "Waiting for mover ix: %v id: %v", mover, ids[mover])
-		moveix, isOpen = <-moveixChs[mover]
-		if isOpen {
-			log.Printf(log.DebugMsg, "Recived move ix:%v from mover ix: %v id:%v", moveix, mover, ids[mover])
-		} else {
-			log.Print(log.DebugMsg, "Recived move channel closed")
+		select {
+		case moveix, isOpen = <-moveixChs[mover]:
+			if isOpen {
+				stats.addMove(time.Since(viewTime))
+				log.Printf(log.DebugMsg, "Recived move ix:%v from mover ix: %v id:%v", moveix, mover, ids[mover])
+			} else {
+				log.Print(log.DebugMsg, "Recived move channel closed")
+			}
+		case conn := <-connChCl.Channel:
+			playerix := 0
+			if conn.PlayerID == ids[1] {
+				playerix = 1
+			}
+			if conn.PlayerCh == nil {
+				log.Printf(log.DebugMsg, "Player id: %v lost connection", conn.PlayerID)
+				connLost[playerix] = true
+				if graceDeadlines[playerix].IsZero() {
+					graceDeadlines[playerix] = time.Now().Add(connLostGrace)
+				}
+			} else {
+				log.Printf(log.DebugMsg, "Player id: %v reconnected", conn.PlayerID)
+				close(playerChs[playerix])
+				playerChs[playerix] = conn.PlayerCh
+				connLost[playerix] = false
+				graceDeadlines[playerix] = time.Time{}
+			}
+			graceTimer, graceCh = resetGraceTimer(graceTimer, graceDeadlines)
+			playingChDatas, watchingChData, _ = createChData(game.Pos, game.Hist.PlayerIDs, game.Hist.Time, winner, failedClaimedExs, connLost, pauseReqID, match)
+			if conn.PlayerCh != nil {
+				playingChDatas[playerix].MoveCh = moveixChs[playerix]
+				playingChDatas[playerix].ConnChCl = connChCl
+				playingChDatas[playerix].PauseChCl = pauseChCl
+				playingChDatas[playerix].ChatChCl = chatChCl
+			}
+			playerChs[0] <- playingChDatas[0]
+			playerChs[1] <- playingChDatas[1]
+			benchCh <- watchingChData
+			continue
+		case pause := <-pauseChCl.Channel:
+			if pause.IsForce {
+				log.Printf(log.DebugMsg, "Server pause game: %v", ids)
+				isOpen = false
+			} else if pauseReqID != 0 && pauseReqID != pause.PlayerID && !pause.IsDecline {
+				log.Printf(log.DebugMsg, "Player id: %v accepted pause", pause.PlayerID)
+				isOpen = false
+			} else {
+				if pause.IsDecline {
+					pauseReqID = 0
+				} else {
+					pauseReqID = pause.PlayerID
+				}
+				playingChDatas, _, _ = createChData(game.Pos, game.Hist.PlayerIDs, game.Hist.Time, winner, failedClaimedExs, connLost, pauseReqID, match)
+				playerChs[0] <- playingChDatas[0]
+				playerChs[1] <- playingChDatas[1]
+				continue
+			}
+		case chat := <-chatChCl.Channel:
+			mess := &bg.ChatMess{
+				Time:        time.Now(),
+				SenderID:    chat.SenderID,
+				SenderName:  chat.SenderName,
+				Message:     chat.Message,
+				IsSpectator: chat.SenderID != ids[0] && chat.SenderID != ids[1],
+			}
+			game.Hist.AddChat(mess)
+			if !mess.IsSpectator {
+				playerChs[0] <- &PlayingChData{PlayingIDs: game.Hist.PlayerIDs, GameTs: game.Hist.Time, Chat: mess}
+				playerChs[1] <- &PlayingChData{PlayingIDs: game.Hist.PlayerIDs, GameTs: game.Hist.Time, Chat: mess}
+			}
+			benchCh <- &WatchingChData{PlayingIDs: game.Hist.PlayerIDs, GameTs: game.Hist.Time, Chat: mess}
+			continue
+		case <-graceCh:
+			log.Printf(log.DebugMsg, "Players: %v did not reconnect in time, deadlines: %v", ids, graceDeadlines)
+			graceTimer = nil
+			graceCh = nil
+			isOpen = false
 		}
 		if !isOpen {
 			winner = game.Pause(moves)
@@ -64,20 +168,51 @@ func tableServe(
 		} else {
 			winner, failedClaimedExs = game.Move(moves[moveix])
 		}
-		playingChDatas, watchingChData, moves = createChData(game.Pos, game.Hist.PlayerIDs, game.Hist.Time, winner, failedClaimedExs)
+		if match != nil && winner != dpos.NoPlayer {
+			match.AddResult(game.Hist.PlayerIDs[winner])
+		}
+		playingChDatas, watchingChData, moves = createChData(game.Pos, game.Hist.PlayerIDs, game.Hist.Time, winner, failedClaimedExs, connLost, pauseReqID, match)
 		log.Printf(log.DebugMsg, "Sending view to playerid: %v\n%v\n%v", ids[0], playingChDatas[0].ViewPos, failedClaimedExs)
 		playerChs[0] <- playingChDatas[0]
 		log.Printf(log.DebugMsg, "Sending view to playerid: %v\n%v\n%v", ids[1], playingChDatas[1].ViewPos, failedClaimedExs)
 		playerChs[1] <- playingChDatas[1]
+		watchingChData.histAdd = &WatchHist{Moves: []*bg.Move{spectatorMove(game.Hist.LastMove())}}
 		benchCh <- watchingChData
+		viewTime = time.Now()
 		if !isOpen {
 			break
 		}
 	}
+	if graceTimer != nil {
+		graceTimer.Stop()
+	}
+	close(connChCl.Close) //stop reconnects
+	close(pauseChCl.Close)
+	close(chatChCl.Close)
+	finishCh <- game // saves the game before the players is informed
 	close(playerChs[0])
 	close(playerChs[1])
 	close(benchCh)
-	finishCh <- game // may be buffered tables will close bench before closing tables
+}
+
+//resetGraceTimer stops the grace timer and starts a new timer for the
+//first reconnect deadline of the players, zero deadline is a connected
+//player. The timer is nil if no player lost the connection.
+func resetGraceTimer(timer *time.Timer, deadlines [2]time.Time) (*time.Timer, <-chan time.Time) {
+	if timer != nil {
+		timer.Stop()
+	}
+	var first time.Time
+	for _, deadline := range deadlines {
+		if !deadline.IsZero() && (first.IsZero() || deadline.Before(first)) {
+			first = deadline
+		}
+	}
+	if first.IsZero() {
+		return nil, nil
+	}
+	timer = time.NewTimer(time.Until(first))
+	return timer, timer.C
 }
 
 //createChData
@@ -86,13 +221,19 @@ func createChData(
 	ids [2]int,
 	gameTs time.Time,
 	winner int,
-	failedClaimedExs [9][]card.Card) (playingChDatas [2]*PlayingChData, watchingChData *WatchingChData, moves []*bg.Move) {
+	failedClaimedExs [9][]card.Card,
+	connLost [2]bool,
+	pauseReqID int,
+	match *Match) (playingChDatas [2]*PlayingChData, watchingChData *WatchingChData, moves []*bg.Move) {
 	for i := range ids {
 		playingChDatas[i] = &PlayingChData{
 			ViewPos:          bg.NewViewPos(pos, bg.ViewAll.Players[i], winner),
 			PlayingIDs:       ids,
 			GameTs:           gameTs,
 			FailedClaimedExs: failedClaimedExs,
+			IsOppConnLost:    connLost[opp(i)],
+			PauseReqID:       pauseReqID,
+			Match:            match.Copy(),
 		}
 		if len(playingChDatas[i].ViewPos.Moves) > 0 {
 			moves = playingChDatas[i].ViewPos.Moves
@@ -103,6 +244,8 @@ func createChData(
 		ViewPos:    bg.NewViewPos(pos, bg.ViewAll.Spectator, winner),
 		PlayingIDs: ids,
 		GameTs:     gameTs,
+		IsConnLost: connLost,
+		Match:      match.Copy(),
 	}
 
 	return playingChDatas, watchingChData, moves
@@ -113,13 +256,29 @@ func initChData(
 	pos *bg.Pos,
 	ids [2]int,
 	gameTs time.Time,
-	moveChs [2]chan int) (playingChDatas [2]*PlayingChData, watchingChData *WatchingChData, moves []*bg.Move) {
+	moveChs [2]chan int,
+	connChCl *ConnChCl,
+	pauseChCl *PauseChCl,
+	chatChCl *ChatChCl,
+	match *Match) (playingChDatas [2]*PlayingChData, watchingChData *WatchingChData, moves []*bg.Move) {
 
 	var failedClaimedExs [9][]card.Card
-	playingChDatas, watchingChData, moves = createChData(pos, ids, gameTs, dpos.NoPlayer, failedClaimedExs)
+	var connLost [2]bool
+	playingChDatas, watchingChData, moves = createChData(pos, ids, gameTs, dpos.NoPlayer, failedClaimedExs, connLost, 0, match)
 	for i, data := range playingChDatas {
 		data.MoveCh = moveChs[i]
+		data.ConnChCl = connChCl
+		data.PauseChCl = pauseChCl
+		data.ChatChCl = chatChCl
 	}
 
 	return playingChDatas, watchingChData, moves
 }
+
+//opp returns the opponent player index.
+func opp(playerix int) int {
+	if playerix == 0 {
+		return 1
+	}
+	return 0
+}
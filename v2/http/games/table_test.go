@@ -0,0 +1,203 @@
+package games
+
+import (
+	"testing"
+	"time"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+)
+
+func TestTableReconnect(t *testing.T) {
+	ids := [2]int{1, 2}
+	var recChs [2]chan *PlayingChData
+	var playerChs [2]chan<- *PlayingChData
+	for i := range recChs {
+		recChs[i] = make(chan *PlayingChData, 1)
+		playerChs[i] = recChs[i]
+	}
+	joinWatchChCl := NewJoinWatchChCl()
+	connChCl := NewConnChCl()
+	finishCh := make(chan *bg.Game, 1)
+	errCh := make(chan error, 10)
+	go tableServe(ids, playerChs, joinWatchChCl, connChCl, NewPauseChCl(), NewChatChCl(), nil, nil, 0, time.Minute, newTableStats(), nil, finishCh, errCh)
+	var inits [2]*PlayingChData
+	for i, ch := range recChs {
+		inits[i] = <-ch
+	}
+	mover := 0
+	if len(inits[1].ViewPos.Moves) > 0 {
+		mover = 1
+	}
+	lost := opp(mover)
+	connChCl.Channel <- &ConnChData{PlayerID: ids[lost]}
+	data := <-recChs[mover]
+	if !data.IsOppConnLost {
+		t.Error("Opponent connection lost not reported to mover")
+	}
+	<-recChs[lost]
+	reconCh := make(chan *PlayingChData, 1)
+	connChCl.Channel <- &ConnChData{PlayerID: ids[lost], PlayerCh: reconCh}
+	data = <-recChs[mover]
+	if data.IsOppConnLost {
+		t.Error("Opponent reconnect not reported to mover")
+	}
+	if _, open := <-recChs[lost]; open {
+		t.Error("Old player channel should be closed on reconnect")
+	}
+	data = <-reconCh
+	if data.MoveCh == nil || data.ConnChCl != connChCl {
+		t.Error("Reconnected player did not receive the table channels")
+	}
+	close(inits[mover].MoveCh)
+	select {
+	case game := <-finishCh:
+		if !game.Pos.LastMoveType.IsPause() {
+			t.Errorf("Game should be paused got: %v", game.Pos.LastMoveType)
+		}
+	case <-time.After(time.Second):
+		t.Error("Table did not finish")
+	}
+}
+
+func TestTableConnLostGrace(t *testing.T) {
+	ids := [2]int{1, 2}
+	var playerChs [2]chan<- *PlayingChData
+	for i := range playerChs {
+		playerChs[i] = make(chan *PlayingChData, 100)
+	}
+	connChCl := NewConnChCl()
+	finishCh := make(chan *bg.Game, 1)
+	go tableServe(ids, playerChs, NewJoinWatchChCl(), connChCl, NewPauseChCl(), NewChatChCl(), nil, nil, 0, 10*time.Millisecond, newTableStats(), nil, finishCh, make(chan error, 10))
+	connChCl.Channel <- &ConnChData{PlayerID: ids[0]}
+	select {
+	case game := <-finishCh:
+		if !game.Pos.LastMoveType.IsPause() {
+			t.Errorf("Game should be paused got: %v", game.Pos.LastMoveType)
+		}
+	case <-time.After(time.Second):
+		t.Error("Table did not pause the game after the grace period")
+	}
+}
+
+func TestTableConnLostGracePerPlayer(t *testing.T) {
+	grace := 100 * time.Millisecond
+	ids := [2]int{1, 2}
+	var playerChs [2]chan<- *PlayingChData
+	for i := range playerChs {
+		playerChs[i] = make(chan *PlayingChData, 100)
+	}
+	connChCl := NewConnChCl()
+	finishCh := make(chan *bg.Game, 1)
+	go tableServe(ids, playerChs, NewJoinWatchChCl(), connChCl, NewPauseChCl(), NewChatChCl(), nil, nil, 0, grace, newTableStats(), nil, finishCh, make(chan error, 10))
+	start := time.Now()
+	connChCl.Channel <- &ConnChData{PlayerID: ids[0]}
+	time.Sleep(grace / 2)
+	connChCl.Channel <- &ConnChData{PlayerID: ids[1]}
+	connChCl.Channel <- &ConnChData{PlayerID: ids[0], PlayerCh: make(chan *PlayingChData, 100)}
+	select {
+	case <-finishCh:
+		if time.Since(start) < grace+grace/2 {
+			t.Errorf("Game paused at the deadline of the reconnected player after: %v", time.Since(start))
+		}
+	case <-time.After(time.Second):
+		t.Error("Table did not pause the game after the grace period of the second player")
+	}
+}
+
+func TestTablePause(t *testing.T) {
+	ids := [2]int{1, 2}
+	var recChs [2]chan *PlayingChData
+	var playerChs [2]chan<- *PlayingChData
+	for i := range recChs {
+		recChs[i] = make(chan *PlayingChData, 1)
+		playerChs[i] = recChs[i]
+	}
+	pauseChCl := NewPauseChCl()
+	finishCh := make(chan *bg.Game, 1)
+	go tableServe(ids, playerChs, NewJoinWatchChCl(), NewConnChCl(), pauseChCl, NewChatChCl(), nil, nil, 0, time.Minute, newTableStats(), nil, finishCh, make(chan error, 10))
+	for _, ch := range recChs {
+		<-ch
+	}
+	pauseChCl.Channel <- &PauseChData{PlayerID: ids[0]}
+	for _, ch := range recChs {
+		if data := <-ch; data.PauseReqID != ids[0] {
+			t.Errorf("Pause request id expected %v got %v", ids[0], data.PauseReqID)
+		}
+	}
+	pauseChCl.Channel <- &PauseChData{PlayerID: ids[1], IsDecline: true}
+	for _, ch := range recChs {
+		if data := <-ch; data.PauseReqID != 0 {
+			t.Errorf("Pause request should be declined got %v", data.PauseReqID)
+		}
+	}
+	pauseChCl.Channel <- &PauseChData{PlayerID: ids[1]}
+	for _, ch := range recChs {
+		<-ch
+	}
+	pauseChCl.Channel <- &PauseChData{PlayerID: ids[0]}
+	select {
+	case game := <-finishCh:
+		if !game.Pos.LastMoveType.IsPause() {
+			t.Errorf("Game should be paused got: %v", game.Pos.LastMoveType)
+		}
+	case <-time.After(time.Second):
+		t.Error("Table did not pause the game on accept")
+	}
+}
+
+func TestTableChat(t *testing.T) {
+	ids := [2]int{1, 2}
+	var recChs [2]chan *PlayingChData
+	var playerChs [2]chan<- *PlayingChData
+	for i := range recChs {
+		recChs[i] = make(chan *PlayingChData, 1)
+		playerChs[i] = recChs[i]
+	}
+	joinWatchChCl := NewJoinWatchChCl()
+	chatChCl := NewChatChCl()
+	finishCh := make(chan *bg.Game, 1)
+	go tableServe(ids, playerChs, joinWatchChCl, NewConnChCl(), NewPauseChCl(), chatChCl, nil, nil, 0, time.Minute, newTableStats(), nil, finishCh, make(chan error, 10))
+	var inits [2]*PlayingChData
+	for i, ch := range recChs {
+		inits[i] = <-ch
+	}
+	watchCh := make(chan *WatchingChData, 10)
+	joinWatchChCl.Channel <- &JoinWatchChData{ID: 9, SendCh: watchCh}
+	if data := <-watchCh; data.Chat != nil {
+		t.Error("Watcher should receive the game view first")
+	}
+	chatChCl.Channel <- &ChatChData{SenderID: ids[0], Message: "Good luck"}
+	for _, ch := range recChs {
+		data := <-ch
+		if data.Chat == nil || data.Chat.IsSpectator {
+			t.Errorf("Player should receive player chat got: %v", data)
+		}
+	}
+	if data := <-watchCh; data.Chat == nil || data.Chat.Message != "Good luck" {
+		t.Errorf("Watcher should receive player chat got: %v", data)
+	}
+	chatChCl.Channel <- &ChatChData{SenderID: 9, Message: "Nice move"}
+	if data := <-watchCh; data.Chat == nil || !data.Chat.IsSpectator {
+		t.Errorf("Watcher should receive spectator chat got: %v", data)
+	}
+	for _, ch := range recChs {
+		select {
+		case data := <-ch:
+			t.Errorf("Player should not receive spectator chat got: %v", data)
+		default:
+		}
+	}
+	mover := 0
+	if len(inits[1].ViewPos.Moves) > 0 {
+		mover = 1
+	}
+	close(inits[mover].MoveCh)
+	select {
+	case game := <-finishCh:
+		if len(game.Hist.Chat) != 2 {
+			t.Errorf("Game history should have 2 chat messages got: %v", len(game.Hist.Chat))
+		}
+	case <-time.After(time.Second):
+		t.Error("Table did not finish")
+	}
+}
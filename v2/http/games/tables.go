@@ -6,12 +6,10 @@ import (
 	arch "github.com/rezder/go-battleline/v2/archiver/client"
 	"github.com/rezder/go-battleline/v2/db/dbhist"
 	bg "github.com/rezder/go-battleline/v2/game"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+	"github.com/rezder/go-battleline/v2/http/config"
 	"github.com/rezder/go-error/log"
-)
-
-const (
-	//histsDbFile  unfinished games hitory data base file.
-	histDbFILE = "server/data/savegames.db"
+	"time"
 )
 
 //TablesServer the battleline tables server
@@ -21,38 +19,53 @@ type TablesServer struct {
 	StartGameChCl *StartGameChCl
 	pubList       *PubList
 	doneCh        chan struct{}
-	savedGamesDb  *dbhist.Db
+	savedGamesDb  *savedGameDb
 	archiver      *arch.Client
+	drainCh       chan time.Duration
+	drainedCh     chan struct{}
+	deleteCh      chan int
+	restarts      *restartList
+	matches       *matchList
+	stats         *tableStatsList
+	connLostGrace time.Duration
 }
 
 //NewTablesServer creates a battleline tables server.
-//archAddr maybe empty or nil
-func NewTablesServer(
-	pubList *PubList,
-	archPokePort int,
-	archAddr string,
-) (s *TablesServer, err error) {
-
+//The saved games database is opened in the configuration data directory,
+//it also keeps the archiver outbox. The archiver address of the
+//configuration maybe empty.
+func NewTablesServer(pubList *PubList, cfg *config.Config) (s *TablesServer, err error) {
 	s = new(TablesServer)
 	s.pubList = pubList
+	s.stats = newTableStatsList(pubList.metrics)
+	s.connLostGrace = cfg.ConnLostGrace.D()
 	s.StartGameChCl = NewStartGameChCl()
 	s.doneCh = make(chan struct{})
-	db, err := bolt.Open(histDbFILE, 0600, nil)
+	s.drainCh = make(chan time.Duration, 1)
+	s.drainedCh = make(chan struct{})
+	s.deleteCh = make(chan int)
+	dbFile := cfg.SavedGamesDbFile()
+	db, err := bolt.Open(dbFile, 0600, nil)
 	if err != nil {
-		err = errors.Wrapf(err, "Open data base file %v failed", histDbFILE)
+		err = errors.Wrapf(err, "Open data base file %v failed", dbFile)
 		return s, err
 	}
-	s.savedGamesDb = dbhist.New(dbhist.KeyPlayers, db, 500)
-	err = s.savedGamesDb.Init()
+	s.savedGamesDb, err = newSavedGameDb(db)
 	if err != nil {
 		_ = db.Close()
 		return s, err
 	}
+	s.restarts, err = newRestartList(db)
 	if err != nil {
 		_ = db.Close()
 		return s, err
 	}
-	s.archiver, err = arch.New(archPokePort, archAddr)
+	s.matches, err = newMatchList(db)
+	if err != nil {
+		_ = db.Close()
+		return s, err
+	}
+	s.archiver, err = arch.New(cfg.ArchPokePort, cfg.ArchAddr, db)
 	return s, err
 }
 
@@ -64,7 +77,29 @@ func (s *TablesServer) CloseDb() error {
 
 //Start starts the tables server.
 func (s *TablesServer) Start(errCh chan<- error) {
-	go startTables(s.StartGameChCl, s.pubList, s.doneCh, errCh, s.savedGamesDb, s.archiver)
+	go startTables(s.StartGameChCl, s.pubList, s.doneCh, s.drainCh, s.drainedCh, s.deleteCh, errCh, s.savedGamesDb, s.restarts, s.matches, s.archiver, s.stats, s.connLostGrace)
+}
+
+//Drain stops the tables server from starting new games, when all running
+//games is finished the drained channel is closed. Games still running
+//after the timeout is paused and saved with a restart marker, zero timeout
+//waits for all games to finish. Must only be called once.
+func (s *TablesServer) Drain(timeout time.Duration) {
+	s.drainCh <- timeout
+}
+
+//DrainedCh returns the channel that is closed when the tables server is drained.
+func (s *TablesServer) DrainedCh() <-chan struct{} {
+	return s.drainedCh
+}
+
+//DeletePlayer deletes the saved games of a deleted player, a game in
+//progress is not saved when it is paused.
+func (s *TablesServer) DeletePlayer(playerID int) {
+	select {
+	case s.deleteCh <- playerID:
+	case <-s.doneCh:
+	}
 }
 
 //Stop stops the tables server.
@@ -77,16 +112,32 @@ func (s *TablesServer) Stop() {
 
 //Start tables server.
 //doneCh closing this channel will close down the tables server.
+//drainCh receiving the drain timeout stops new games, drainedCh is closed
+//when no games is running. The games running at the timeout is saved
+//with a restart marker.
+//deleteCh receiving a player id deletes the saved games of the player.
 func startTables(
 	startGameChCl *StartGameChCl,
 	pubList *PubList, doneCh chan struct{},
+	drainCh <-chan time.Duration,
+	drainedCh chan struct{},
+	deleteCh <-chan int,
 	errCh chan<- error,
-	savedGamesDb *dbhist.Db,
-	archiver *arch.Client) {
+	savedGamesDb *savedGameDb,
+	restarts *restartList,
+	matches *matchList,
+	archiver *arch.Client,
+	stats *tableStatsList,
+	connLostGrace time.Duration) {
 
 	finishTableCh := make(chan *bg.Game)
 	startCh := startGameChCl.Channel
 	var isDone bool
+	var isDraining bool
+	var drainTimer *time.Timer
+	var drainTimeoutCh <-chan time.Time
+	restartIDs := make(map[int]bool)
+	deletedIDs := make(map[int]bool)
 	games := make(map[int]*GameData)
 	archiver.Start()
 Loop:
@@ -95,36 +146,106 @@ Loop:
 		case game := <-finishTableCh:
 			delete(games, game.Hist.PlayerIDs[0])
 			delete(games, game.Hist.PlayerIDs[1])
-			if game.Pos.LastMoveType.IsPause() {
+			stats.remove(game.Hist.PlayerIDs)
+			isSaved := false
+			if deletedIDs[game.Hist.PlayerIDs[0]] || deletedIDs[game.Hist.PlayerIDs[1]] {
+				log.Printf(log.DebugMsg, "Dropping stopped game of deleted player: %v,%v", game.Hist.PlayerIDs, game.Hist.Time)
+			} else if game.Pos.LastMoveType.IsPause() {
 				log.Printf(log.DebugMsg, "Saving stopped game: %v,%v", game.Hist.PlayerIDs, game.Hist.Time)
 				err := savedGamesDb.Put(game.Hist)
 				if err != nil {
 					errTxt := "Save game player ids: %v failed."
 					err = errors.Wrapf(err, errTxt, game.Hist.PlayerIDs)
 					errCh <- err
+				} else {
+					isSaved = true
+					if restartIDs[game.Hist.PlayerIDs[0]] {
+						err = restarts.add(game.Hist.PlayerIDs)
+						if err != nil {
+							errCh <- err
+						}
+					}
 				}
 			} else {
 				log.Printf(log.DebugMsg, "Archiving game: %v,%v", game.Hist.PlayerIDs, game.Hist.Time)
 				archiver.Archive(game.Hist)
 			}
+			err := matches.finish(game.Hist, isSaved)
+			if err != nil {
+				errCh <- err
+			}
+			err = matches.expire(time.Now(), func(ids [2]int) bool {
+				return isMatchActive(ids, games, savedGamesDb)
+			})
+			if err != nil {
+				errCh <- err
+			}
 
+			delete(restartIDs, game.Hist.PlayerIDs[0])
+			delete(restartIDs, game.Hist.PlayerIDs[1])
+			delete(deletedIDs, game.Hist.PlayerIDs[0])
+			delete(deletedIDs, game.Hist.PlayerIDs[1])
+			if isDraining && len(games) == 0 {
+				close(drainedCh)
+				isDraining = false
+				if drainTimer != nil {
+					drainTimer.Stop()
+					drainTimeoutCh = nil
+				}
+			}
 			if isDone && len(games) == 0 {
 				break Loop
 			}
 			publishTables(games, pubList)
+		case playerID := <-deleteCh:
+			log.Printf(log.DebugMsg, "Deleting saved games of player id: %v", playerID)
+			if _, isFound := games[playerID]; isFound {
+				deletedIDs[playerID] = true
+			}
+			deleteSavedGames(savedGamesDb, restarts, playerID, errCh)
+		case timeout := <-drainCh:
+			log.Printf(log.DebugMsg, "Tables server draining timeout: %v", timeout)
+			drainCh = nil
+			isDraining = true
+			if len(games) == 0 {
+				close(drainedCh)
+				isDraining = false
+			} else if timeout > 0 {
+				drainTimer = time.NewTimer(timeout)
+				drainTimeoutCh = drainTimer.C
+			}
+		case <-drainTimeoutCh:
+			drainTimeoutCh = nil
+			log.Printf(log.DebugMsg, "Tables server drain timeout, saving %v games", len(games)/2)
+			for id, gameData := range games {
+				restartIDs[id] = true
+				if id < gameData.Opp {
+					go forcePause(gameData.PauseChCl, id)
+				}
+			}
 		case start := <-startCh:
-			if isPlaying(start.PlayerIds, games) {
+			if isPlaying(start.PlayerIds, games) || drainCh == nil {
 				log.Printf(log.DebugMsg, "Close requested start game: %v", start)
 				close(start.PlayerChs[0])
 				close(start.PlayerChs[1])
 			} else {
 				log.Printf(log.DebugMsg, "Tables starts game: %v", start)
 				var savedGame *bg.Game
-				savedGame, start = getOldGame(start, savedGamesDb, errCh)
+				savedGame, start = getOldGame(start, savedGamesDb, restarts, errCh)
+				if savedGame != nil && !start.IsResume {
+					log.Printf(log.DebugMsg, "Resuming saved game instead of a new game: %v", start.PlayerIds)
+				}
+				match, err := matches.start(start, savedGame)
+				if err != nil {
+					errCh <- err
+				}
 				joinWatchCh := NewJoinWatchChCl()
-				go tableServe(start.PlayerIds, start.PlayerChs, joinWatchCh, savedGame, finishTableCh, errCh)
-				games[start.PlayerIds[0]] = NewGameData(start.PlayerIds[1], joinWatchCh)
-				games[start.PlayerIds[1]] = NewGameData(start.PlayerIds[0], joinWatchCh)
+				connCh := NewConnChCl()
+				pauseCh := NewPauseChCl()
+				chatCh := NewChatChCl()
+				go tableServe(start.PlayerIds, start.PlayerChs, joinWatchCh, connCh, pauseCh, chatCh, savedGame, match, start.WatchDelay, connLostGrace, stats.add(start.PlayerIds), pubList.Events(), finishTableCh, errCh)
+				games[start.PlayerIds[0]] = NewGameData(start.PlayerIds[1], joinWatchCh, connCh, pauseCh, chatCh)
+				games[start.PlayerIds[1]] = NewGameData(start.PlayerIds[0], joinWatchCh, connCh, pauseCh, chatCh)
 				publishTables(games, pubList)
 			}
 
@@ -137,16 +258,28 @@ Loop:
 			}
 		}
 	} //loop
+	archiver.Stop() //The archiver outbox is in the saved games database.
 	err := savedGamesDb.Close()
 	if err != nil {
 		errCh <- err
 	}
-	archiver.Stop()
 	close(doneCh)
 }
+
+//forcePause pauses a table, the table saves the game.
+func forcePause(pauseChCl *PauseChCl, playerID int) {
+	select {
+	case pauseChCl.Channel <- &PauseChData{PlayerID: playerID, IsForce: true}:
+	case <-pauseChCl.Close:
+	}
+}
+
+//getOldGame loads the saved game of the players and removes it from the
+//saved games, the restart marker is also removed.
 func getOldGame(
 	start *StartGameChData,
-	hdb *dbhist.Db,
+	hdb *savedGameDb,
+	restarts *restartList,
 	errCh chan<- error) (*bg.Game, *StartGameChData) {
 
 	var game *bg.Game
@@ -162,6 +295,10 @@ func getOldGame(
 			err = errors.Wrapf(err, "Failed deleting history for %v", start.PlayerIds)
 			errCh <- err
 		}
+		_, err = restarts.take(start.PlayerIds)
+		if err != nil {
+			errCh <- err
+		}
 		game = bg.NewGame()
 		game.LoadHist(hist)
 		_ = game.Resume() //Assumes we do not save finsihed game
@@ -173,6 +310,101 @@ func getOldGame(
 	}
 	return game, start
 }
+
+//deleteSavedGames deletes the saved games of a player and
+//their restart markers.
+func deleteSavedGames(hdb *savedGameDb, restarts *restartList, playerID int, errCh chan<- error) {
+	for _, oppID := range hdb.opps(playerID) {
+		ids := [2]int{playerID, oppID}
+		err := hdb.Delete(dbhist.KeyPlayerIDs(ids))
+		if err != nil {
+			errCh <- errors.Wrapf(err, "Failed deleting history for %v", ids)
+			continue
+		}
+		_, err = restarts.take(ids)
+		if err != nil {
+			errCh <- err
+		}
+	}
+}
+
+//SavedGame is the lobby information of a saved game.
+//The position is the players view of the game.
+type SavedGame struct {
+	OppID   int
+	GameTs  time.Time
+	MoverID int
+	ViewPos *bg.ViewPos
+}
+
+//SavedGames the saved games of a player.
+type SavedGames []*SavedGame
+
+//newSavedGame creates the saved game information from a history.
+func newSavedGame(hist *bg.Hist, playerID int) (savedGame *SavedGame) {
+	game := bg.NewGame()
+	game.LoadHist(hist.Copy())
+	_ = game.Resume()
+	playerix := 0
+	if hist.PlayerIDs[1] == playerID {
+		playerix = 1
+	}
+	savedGame = new(SavedGame)
+	savedGame.OppID = hist.PlayerIDs[opp(playerix)]
+	savedGame.GameTs = hist.Time
+	savedGame.ViewPos = bg.NewViewPos(game.Pos, bg.ViewAll.Players[playerix], dpos.NoPlayer)
+	moves := game.Pos.CalcMoves()
+	if len(moves) > 0 {
+		savedGame.MoverID = hist.PlayerIDs[moves[0].Mover]
+	}
+	savedGame.ViewPos.Moves = nil
+	return savedGame
+}
+
+//loadSavedGames loads the saved games of a player.
+func loadSavedGames(hdb *savedGameDb, playerID int) (savedGames SavedGames, err error) {
+	oppIDs := hdb.opps(playerID)
+	keys := make([][]byte, len(oppIDs))
+	for i, oppID := range oppIDs {
+		keys[i] = dbhist.KeyPlayerIDs([2]int{playerID, oppID})
+	}
+	hists, err := hdb.Gets(keys)
+	if err != nil {
+		err = errors.Wrapf(err, "Loading saved games for player id: %v failed", playerID)
+		return savedGames, err
+	}
+	savedGames = make(SavedGames, 0, len(hists))
+	for _, hist := range hists {
+		if hist != nil {
+			savedGames = append(savedGames, newSavedGame(hist, playerID))
+		}
+	}
+	return savedGames, err
+}
+
+//loadSavedGame loads the saved game between two players if it exist.
+func loadSavedGame(hdb *savedGameDb, playerID, oppID int) (savedGame *SavedGame, err error) {
+	hist, err := hdb.Get(dbhist.KeyPlayerIDs([2]int{playerID, oppID}))
+	if err != nil {
+		err = errors.Wrapf(err, "Loading saved game for players: %v,%v failed", playerID, oppID)
+		return savedGame, err
+	}
+	if hist != nil {
+		savedGame = newSavedGame(hist, playerID)
+	}
+	return savedGame, err
+}
+
+//isMatchActive returns true if the players of a match have a running
+//or saved game.
+func isMatchActive(ids [2]int, games map[int]*GameData, savedGamesDb *savedGameDb) bool {
+	gameData, isFound := games[ids[0]]
+	if isFound && gameData.Opp == ids[1] {
+		return true
+	}
+	return savedGamesDb.isSaved(ids)
+}
+
 func isPlaying(ids [2]int, games map[int]*GameData) bool {
 	_, isFound := games[ids[0]]
 	if !isFound {
@@ -192,17 +424,27 @@ func publishTables(games map[int]*GameData, pubList *PubList) {
 }
 
 //NewGameData create a new GameData pointer.
-func NewGameData(opp int, watch *JoinWatchChCl) (g *GameData) {
+func NewGameData(opp int, watch *JoinWatchChCl, conn *ConnChCl, pause *PauseChCl, chat *ChatChCl) (g *GameData) {
 	g = new(GameData)
 	g.Opp = opp
 	g.JoinWatchChCl = watch
+	g.ConnChCl = conn
+	g.PauseChCl = pause
+	g.ChatChCl = chat
 	return g
 }
 
 // StartGameChData is the information need to start a game.
+// If IsResume the players saved game is resumed, a saved game is
+// also resumed if it was saved after a new game invite.
+// Match is nil for a single game, a match without id is a new match.
+// WatchDelay is the spectator broadcast delay.
 type StartGameChData struct {
-	PlayerIds [2]int
-	PlayerChs [2]chan<- *PlayingChData
+	PlayerIds  [2]int
+	PlayerChs  [2]chan<- *PlayingChData
+	IsResume   bool
+	Match      *Match
+	WatchDelay time.Duration
 }
 
 // StartGameChCl the start game channel.
@@ -0,0 +1,363 @@
+package games
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/pkg/errors"
+	bg "github.com/rezder/go-battleline/v2/game"
+	"github.com/rezder/go-battleline/v2/game/card"
+	"github.com/rezder/go-battleline/v2/game/pos"
+	"golang.org/x/net/websocket"
+)
+
+//The binary wire frame is:
+// byte 0: the json type.
+// byte 1: the flags wireVIEWPOS and wireDELTA.
+// the binary view position if wireVIEWPOS.
+// the binary view position delta if wireDELTA.
+// the rest is the json data without the view position and delta.
+//A view position is the view, 71 card positions and 10 cone positions
+//as bytes followed by the counters and moves as a delta without pieces.
+//Integers is varints and lists is a uvarint count followed by the items.
+//The binary action is the integer fields as varints and the strings
+//length prefixed.
+const (
+	wireVIEWPOS = 1 << iota
+	wireDELTA
+)
+
+//WireFrame is a decoded binary frame, ViewPos and Delta is set when
+//they was binary encoded and they are missing from the json Data.
+type WireFrame struct {
+	JsonType int
+	Data     json.RawMessage
+	ViewPos  *bg.ViewPos
+	Delta    *bg.ViewPosDelta
+}
+
+//WireCodec is the websocket codec of the binary wire encoding.
+//Binary frames is decoded as binary and text frames as json, so the
+//receiver can handle both encodings.
+//The messages is *JsonData and the actions *Action.
+var WireCodec = websocket.Codec{Marshal: wireMarshal, Unmarshal: wireUnmarshal}
+
+func wireMarshal(v interface{}) (frame []byte, payloadType byte, err error) {
+	switch data := v.(type) {
+	case *JsonData:
+		frame, err = EncodeWire(data)
+	case *Action:
+		frame = EncodeWireAction(data)
+	default:
+		err = errors.Errorf("Binary encoding of %T not supported", v)
+	}
+	return frame, websocket.BinaryFrame, err
+}
+
+func wireUnmarshal(frame []byte, payloadType byte, v interface{}) (err error) {
+	if payloadType != websocket.BinaryFrame {
+		return json.Unmarshal(frame, v)
+	}
+	switch data := v.(type) {
+	case *WireFrame:
+		var decoded *WireFrame
+		decoded, err = DecodeWire(frame)
+		if err == nil {
+			*data = *decoded
+		}
+	case *Action:
+		err = DecodeWireAction(frame, data)
+	default:
+		err = errors.Errorf("Binary decoding of %T not supported", v)
+	}
+	return err
+}
+
+//EncodeWire encodes a message in the binary wire frame.
+func EncodeWire(jdata *JsonData) (frame []byte, err error) {
+	var viewPos *bg.ViewPos
+	var delta *bg.ViewPosDelta
+	data := jdata.Data
+	switch d := data.(type) {
+	case *PlayingChData:
+		playingData := *d
+		viewPos, delta = playingData.ViewPos, playingData.Delta
+		playingData.ViewPos, playingData.Delta = nil, nil
+		data = &playingData
+	case *WatchingChData:
+		watchingData := *d
+		viewPos, delta = watchingData.ViewPos, watchingData.Delta
+		watchingData.ViewPos, watchingData.Delta = nil, nil
+		data = &watchingData
+	}
+	frame = make([]byte, 2, 256)
+	frame[0] = byte(jdata.JsonType)
+	if viewPos != nil {
+		frame[1] |= wireVIEWPOS
+		frame = appendViewPos(frame, viewPos)
+	}
+	if delta != nil {
+		frame[1] |= wireDELTA
+		frame = appendDelta(frame, delta)
+	}
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	frame = append(frame, js...)
+	return frame, err
+}
+
+//DecodeWire decodes a binary wire frame.
+func DecodeWire(frame []byte) (w *WireFrame, err error) {
+	if len(frame) < 2 {
+		return nil, errors.New("Binary frame too short")
+	}
+	w = new(WireFrame)
+	w.JsonType = int(frame[0])
+	flags := frame[1]
+	r := &wireReader{buf: frame[2:]}
+	if flags&wireVIEWPOS != 0 {
+		w.ViewPos = r.readViewPos()
+	}
+	if flags&wireDELTA != 0 {
+		w.Delta = r.readDelta()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	w.Data = json.RawMessage(r.buf)
+	return w, err
+}
+
+//EncodeWireAction encodes a action in binary.
+func EncodeWireAction(act *Action) (frame []byte) {
+	frame = make([]byte, 0, 16)
+	for _, i := range []int{act.ActType, act.ID, act.Moveix, act.BestOf, act.WatchDelay, act.Version} {
+		frame = appendVarint(frame, int64(i))
+	}
+	frame = appendString(frame, act.Mess)
+	frame = appendUvarint(frame, uint64(len(act.Caps)))
+	for _, capability := range act.Caps {
+		frame = appendString(frame, capability)
+	}
+	return frame
+}
+
+//DecodeWireAction decodes a binary action.
+func DecodeWireAction(frame []byte, act *Action) (err error) {
+	r := &wireReader{buf: frame}
+	for _, i := range []*int{&act.ActType, &act.ID, &act.Moveix, &act.BestOf, &act.WatchDelay, &act.Version} {
+		*i = r.readInt()
+	}
+	act.Mess = r.readString()
+	noCaps := r.readCount()
+	act.Caps = nil
+	for i := 0; i < noCaps; i++ {
+		act.Caps = append(act.Caps, r.readString())
+	}
+	if r.err == nil && len(r.buf) != 0 {
+		r.err = errors.New("Binary action has trailing bytes")
+	}
+	return r.err
+}
+
+func appendVarint(b []byte, i int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], i)]...)
+}
+
+func appendUvarint(b []byte, i uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], i)]...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendViewPos(b []byte, v *bg.ViewPos) []byte {
+	b = append(b, byte(v.View))
+	for _, cardPos := range v.CardPos {
+		b = append(b, byte(cardPos))
+	}
+	for _, conePos := range v.ConePos {
+		b = append(b, byte(conePos))
+	}
+	counters := &bg.ViewPosDelta{
+		PlayerReturned: v.PlayerReturned,
+		CardsReturned:  v.CardsReturned,
+		LastMoveType:   v.LastMoveType,
+		LastMover:      v.LastMover,
+		LastMoveIx:     v.LastMoveIx,
+		Winner:         v.Winner,
+		NoTacs:         v.NoTacs,
+		NoTroops:       v.NoTroops,
+		Moves:          v.Moves,
+	}
+	return appendDelta(b, counters)
+}
+
+func appendDelta(b []byte, d *bg.ViewPosDelta) []byte {
+	b = appendPieces(b, d.Pieces)
+	b = appendVarint(b, int64(d.PlayerReturned))
+	b = append(b, byte(d.CardsReturned[0]), byte(d.CardsReturned[1]), byte(d.LastMoveType))
+	for _, i := range []int{d.LastMover, d.LastMoveIx, d.Winner,
+		d.NoTacs[0], d.NoTacs[1], d.NoTroops[0], d.NoTroops[1]} {
+		b = appendVarint(b, int64(i))
+	}
+	b = appendUvarint(b, uint64(len(d.Moves)))
+	for _, move := range d.Moves {
+		b = appendVarint(b, int64(move.Mover))
+		b = append(b, byte(move.MoveType))
+		b = appendPieces(b, move.Moves)
+	}
+	return b
+}
+
+func appendPieces(b []byte, pieces []*bg.BoardPieceMove) []byte {
+	b = appendUvarint(b, uint64(len(pieces)))
+	for _, piece := range pieces {
+		b = appendUvarint(b, uint64(piece.Index))
+		b = append(b, piece.NewPos, piece.OldPos, byte(piece.BoardPiece))
+	}
+	return b
+}
+
+//wireReader reads the binary encoding, the first error is kept and
+//the following reads returns zero values.
+type wireReader struct {
+	buf []byte
+	err error
+}
+
+func (r *wireReader) fail() {
+	if r.err == nil {
+		r.err = errors.New("Binary frame too short")
+	}
+	r.buf = nil
+}
+
+func (r *wireReader) readByte() (b byte) {
+	if len(r.buf) == 0 {
+		r.fail()
+		return 0
+	}
+	b = r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *wireReader) readInt() int {
+	i, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return int(i)
+}
+
+//readCount reads a list count, a count larger than the remaining bytes
+//is a error.
+func (r *wireReader) readCount() int {
+	i, n := binary.Uvarint(r.buf)
+	if n <= 0 || i > uint64(len(r.buf)) {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return int(i)
+}
+
+func (r *wireReader) readString() (s string) {
+	size := r.readCount()
+	if size > len(r.buf) {
+		r.fail()
+		return s
+	}
+	s = string(r.buf[:size])
+	r.buf = r.buf[size:]
+	return s
+}
+
+func (r *wireReader) readViewPos() (v *bg.ViewPos) {
+	v = new(bg.ViewPos)
+	v.Pos = new(bg.Pos)
+	v.View = bg.View(r.readByte())
+	for i := range v.CardPos {
+		v.CardPos[i] = pos.Card(r.readByte())
+	}
+	for i := range v.ConePos {
+		v.ConePos[i] = pos.Cone(r.readByte())
+	}
+	return v.ApplyDelta(r.readDelta())
+}
+
+func (r *wireReader) readDelta() (d *bg.ViewPosDelta) {
+	d = new(bg.ViewPosDelta)
+	d.Pieces = r.readPieces(false)
+	d.PlayerReturned = r.readInt()
+	d.CardsReturned[0] = card.Card(r.readByte())
+	d.CardsReturned[1] = card.Card(r.readByte())
+	d.LastMoveType = bg.MoveType(r.readByte())
+	for _, i := range []*int{&d.LastMover, &d.LastMoveIx, &d.Winner,
+		&d.NoTacs[0], &d.NoTacs[1], &d.NoTroops[0], &d.NoTroops[1]} {
+		*i = r.readInt()
+	}
+	noMoves := r.readCount()
+	for i := 0; i < noMoves; i++ {
+		move := bg.NewMove(r.readInt(), bg.MoveType(r.readByte()))
+		move.Moves = r.readPieces(true)
+		d.Moves = append(d.Moves, move)
+	}
+	return d
+}
+
+//readPieces reads board piece moves, the card pieces of moves may be
+//the back of a card.
+func (r *wireReader) readPieces(isMove bool) (pieces []*bg.BoardPieceMove) {
+	noPieces := r.readCount()
+	for i := 0; i < noPieces; i++ {
+		piece := new(bg.BoardPieceMove)
+		piece.Index = int(r.readUint())
+		piece.NewPos = r.readByte()
+		piece.OldPos = r.readByte()
+		piece.BoardPiece = bg.BoardPiece(r.readByte())
+		if r.err == nil && !isPieceValid(piece, isMove) {
+			r.err = errors.Errorf("Binary frame has a invalid board piece %v index %v", uint8(piece.BoardPiece), piece.Index)
+			r.buf = nil
+		}
+		if r.err != nil {
+			return nil
+		}
+		pieces = append(pieces, piece)
+	}
+	return pieces
+}
+
+//isPieceValid returns true if the board piece is a card or a cone with
+//a index of the view position or the back of a card for a move.
+func isPieceValid(piece *bg.BoardPieceMove, isMove bool) bool {
+	var p bg.Pos
+	switch {
+	case piece.IsCard():
+		if isMove && card.Card(piece.Index).IsBack() {
+			return true
+		}
+		return piece.Index >= 0 && piece.Index < len(p.CardPos)
+	case piece.IsCone():
+		return piece.Index >= 0 && piece.Index < len(p.ConePos)
+	}
+	return false
+}
+
+func (r *wireReader) readUint() uint64 {
+	i, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return i
+}
@@ -0,0 +1,157 @@
+package games
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	bg "github.com/rezder/go-battleline/v2/game"
+	dpos "github.com/rezder/go-battleline/v2/game/pos"
+	"golang.org/x/net/websocket"
+)
+
+//testWireData returns the playing data of a game in progress.
+func testWireData() (data *PlayingChData, last *bg.ViewPos) {
+	game := bg.NewGame()
+	game.Start([2]int{1, 2}, 0)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		moves := game.Pos.CalcMoves()
+		last = bg.NewViewPos(game.Pos, bg.ViewAll.Players[0], dpos.NoPlayer)
+		if winner, _ := game.Move(moves[r.Intn(len(moves))]); winner != dpos.NoPlayer {
+			break
+		}
+	}
+	moves := game.Pos.CalcMoves()
+	viewPos := bg.NewViewPos(game.Pos, bg.ViewAll.Players[moves[0].Mover], dpos.NoPlayer)
+	data = &PlayingChData{ViewPos: viewPos, PlayingIDs: [2]int{1, 2}, GameTs: time.Now(), PauseReqID: 2}
+	return data, last
+}
+
+func TestWire(t *testing.T) {
+	data, last := testWireData()
+	delta := &PlayingChData{Delta: bg.NewViewPosDelta(last, data.ViewPos), Seq: 7}
+	for _, jdata := range []*JsonData{{JTPlaying, data}, {JTPlayingDelta, delta}, {JTMess, &MesData{SenderID: 3, Message: "Hi"}}} {
+		frame, err := EncodeWire(jdata)
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		jsFrame, _, _ := websocket.JSON.Marshal(jdata)
+		t.Logf("Type: %v binary: %v bytes json: %v bytes", jdata.JsonType, len(frame), len(jsFrame))
+		var w WireFrame
+		if err = WireCodec.Unmarshal(frame, websocket.BinaryFrame, &w); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		if w.JsonType != jdata.JsonType {
+			t.Errorf("Expected type %v got: %v", jdata.JsonType, w.JsonType)
+		}
+		switch expData := jdata.Data.(type) {
+		case *PlayingChData:
+			var decoded PlayingChData
+			if err = json.Unmarshal(w.Data, &decoded); err != nil {
+				t.Fatalf("Unmarshal data failed: %v", err)
+			}
+			if decoded.PauseReqID != expData.PauseReqID || decoded.Seq != expData.Seq {
+				t.Errorf("Json data differs: %v", decoded)
+			}
+			if expData.ViewPos != nil && !w.ViewPos.IsEqual(expData.ViewPos) {
+				t.Errorf("View position differs\nExpected:\n%v\nGot:\n%v", expData.ViewPos.Pos, w.ViewPos.Pos)
+			}
+			if expData.Delta != nil && len(w.Delta.Pieces) != len(expData.Delta.Pieces) {
+				t.Errorf("Delta differs: %v", w.Delta)
+			}
+		case *MesData:
+			var decoded MesData
+			if err = json.Unmarshal(w.Data, &decoded); err != nil || decoded != *expData {
+				t.Errorf("Message differs: %v, %v", decoded, err)
+			}
+		}
+	}
+	if _, err := DecodeWire([]byte{JTPlaying, wireVIEWPOS, 1, 2}); err == nil {
+		t.Error("Short frame should fail")
+	}
+	piece := delta.Delta.Pieces[0]
+	for _, invalid := range []bg.BoardPieceMove{
+		{Index: 71, BoardPiece: bg.BoardPieceAll.Card},
+		{Index: 10, BoardPiece: bg.BoardPieceAll.Cone},
+		{Index: 1, BoardPiece: bg.BoardPiece(9)},
+	} {
+		*piece = invalid
+		frame, err := EncodeWire(&JsonData{JTPlayingDelta, delta})
+		if err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+		if _, err = DecodeWire(frame); err == nil {
+			t.Errorf("Invalid piece %v index %v should fail", uint8(invalid.BoardPiece), invalid.Index)
+		}
+	}
+}
+
+func TestWireAction(t *testing.T) {
+	act := &Action{ActType: ACTIDHello, ID: -1, Moveix: 300, Mess: "Hello", Version: 3, Caps: []string{CapBinary, CapDelta}}
+	frame, payloadType, err := WireCodec.Marshal(act)
+	if err != nil || payloadType != websocket.BinaryFrame {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded Action
+	if err = WireCodec.Unmarshal(frame, payloadType, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.ActType != act.ActType || decoded.ID != act.ID || decoded.Moveix != act.Moveix ||
+		decoded.Mess != act.Mess || decoded.Version != act.Version || len(decoded.Caps) != 2 || decoded.Caps[1] != CapDelta {
+		t.Errorf("Expected %v got: %v", act, decoded)
+	}
+	if err = WireCodec.Unmarshal([]byte(`{"ActType":6,"Moveix":2}`), websocket.TextFrame, &decoded); err != nil || decoded.Moveix != 2 {
+		t.Errorf("Text frames should be json got: %v, %v", decoded, err)
+	}
+	if err = DecodeWireAction(frame[:len(frame)-1], &decoded); err == nil {
+		t.Error("Short action should fail")
+	}
+}
+
+func BenchmarkWireJSON(b *testing.B) {
+	data, _ := testWireData()
+	jdata := &JsonData{JsonType: JTPlaying, Data: data}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		frame, _, err := websocket.JSON.Marshal(jdata)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var temp struct {
+			JsonType int
+			Data     json.RawMessage
+		}
+		var decoded PlayingChData
+		if err = json.Unmarshal(frame, &temp); err == nil {
+			err = json.Unmarshal(temp.Data, &decoded)
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(frame)))
+	}
+}
+
+func BenchmarkWireBinary(b *testing.B) {
+	data, _ := testWireData()
+	jdata := &JsonData{JsonType: JTPlaying, Data: data}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		frame, _, err := WireCodec.Marshal(jdata)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var w WireFrame
+		var decoded PlayingChData
+		if err = WireCodec.Unmarshal(frame, websocket.BinaryFrame, &w); err == nil {
+			err = json.Unmarshal(w.Data, &decoded)
+			decoded.ViewPos = w.ViewPos
+		}
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(len(frame)))
+	}
+}
@@ -2,15 +2,22 @@
 package http
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/http/config"
 	"github.com/rezder/go-battleline/v2/http/games"
 	"github.com/rezder/go-battleline/v2/http/login"
+	"github.com/rezder/go-battleline/v2/http/metrics"
 	"github.com/rezder/go-error/log"
 	"golang.org/x/net/websocket"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,24 +26,29 @@ type Server struct {
 	errServer   *ErrServer
 	netListener *net.TCPListener
 	clients     *Clients
-	port        string
-	backupPort  string
+	cfg         *config.Config
+	metrics     *metrics.Registry
 	doneCh      chan struct{}
-	rootDir     string
+	drainedCh   <-chan struct{}
+	tlsConfig   *tls.Config
 }
 
-//New creates a new Server.
-func New(port, backupPort string, archPokePort int, archAddr, rootDir string) (s *Server, err error) {
+//New creates a new Server from the configuration.
+//The databases is created in the configuration data directory.
+//If the certificate file is set the server serves https.
+func New(cfg *config.Config) (s *Server, err error) {
 	s = new(Server)
-	s.backupPort = backupPort
-	s.rootDir = rootDir
-	s.port = port
-	tcpPort := port
-	if len(port) == 0 {
-		tcpPort = ":80"
-	}
-	s.port = port
-	laddr, err := net.ResolveTCPAddr("tcp", tcpPort) //TODO CHECK this look strange
+	s.cfg = cfg
+	s.tlsConfig, err = loadTLSConfig(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return s, err
+	}
+	err = os.MkdirAll(cfg.DataDir, 0700)
+	if err != nil {
+		err = errors.Wrapf(err, "Create data directory %v failed", cfg.DataDir)
+		return s, err
+	}
+	laddr, err := net.ResolveTCPAddr("tcp", ":"+strconv.Itoa(cfg.Port))
 	if err != nil {
 		err = errors.Wrap(err, log.ErrNo(3)+"Resolve address")
 		return s, err
@@ -49,22 +61,38 @@ func New(port, backupPort string, archPokePort int, archAddr, rootDir string) (s
 	}
 	s.netListener = netListener
 	var gameServer *games.Server
-	gameServer, err = games.New(archPokePort, archAddr)
+	gameServer, err = games.New(cfg)
 	if err != nil {
+		_ = netListener.Close()
 		return s, err
 	}
 	var clients *Clients
-	clients, err = NewClients(gameServer)
+	clients, err = NewClients(gameServer, cfg.ClientsDbFile(), cfg.SessionExpiry.D())
 	if err != nil {
+		_ = netListener.Close()
 		return s, err
 	}
 	s.clients = clients
+	s.drainedCh = gameServer.DrainedCh()
+	err = clients.AddBots(cfg.Bots)
+	if err != nil {
+		_ = netListener.Close()
+		_ = clients.Close()
+		_ = clients.CancelGameServer()
+		return s, err
+	}
+	s.metrics = newMetrics(clients, gameServer)
 	s.doneCh = make(chan struct{})
-	s.errServer = NewErrServer(clients)
+	s.errServer = NewErrServer(clients, cfg.ErrLimit, cfg.ErrWindow.D())
 
 	return s, err
 }
 
+//Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.netListener.Addr()
+}
+
 //Cancel cancels the server, if the server is created without errors
 // it must be canceled or started.
 func (s *Server) Cancel() (err error) {
@@ -81,12 +109,21 @@ func (s *Server) Cancel() (err error) {
 func (s *Server) Start() {
 	s.errServer.Start()
 	s.clients.gameServer.Start(s.errServer.Ch())
-	go start(s.errServer.Ch(), s.netListener, s.clients, s.doneCh, s.port, s.rootDir)
-	if len(s.backupPort) > 0 {
-		go backUpServe(s.clients.gameServer, s.clients.cdb, s.backupPort)
+	go start(s.errServer, s.netListener, s.tlsConfig, s.clients, s.doneCh, s.cfg)
+	if s.cfg.BackupPort != 0 {
+		go backUpServe(s.clients.gameServer, s.clients.cdb, s.cfg.BackupPort)
+	}
+	if s.cfg.MetricsPort != 0 {
+		go metricsServe(s.metrics, s.cfg.MetricsPort)
 	}
 }
 
+//DrainedCh returns a channel that is closed when the server is drained
+//after a admin drain request. The server should then be stopped.
+func (s *Server) DrainedCh() <-chan struct{} {
+	return s.drainedCh
+}
+
 //Stop stops the server.
 func (s *Server) Stop() {
 	gameServer := s.clients.SetGameServer(nil) //Prevent new players
@@ -111,28 +148,47 @@ func (s *Server) Stop() {
 
 // Start the server.
 func start(
-	errCh chan<- error,
+	errServer *ErrServer,
 	netListener *net.TCPListener,
+	tlsConfig *tls.Config,
 	clients *Clients,
 	doneCh chan struct{},
-	port string,
-	rootDir string) {
-	http.Handle("/post/login", &logInPostHandler{clients, errCh})
-	http.Handle("/post/client", &clientPostHandler{clients, errCh})
-	http.Handle("/in/gamews", *createWsHandler(clients, errCh))
-	http.Handle("/ping", &pingHandler{clients, errCh})
-	http.Handle("/", http.FileServer(http.Dir(rootDir)))
-
-	server := &http.Server{Addr: "game.rezder.com" + port} //address is not used
-	err := server.Serve(tcpKeepAliveListener{netListener})
+	cfg *config.Config) {
+	errCh := errServer.Ch()
+	mux := http.NewServeMux()
+	mux.Handle("/post/login", &logInPostHandler{clients, errCh})
+	mux.Handle("/post/botlogin", &botLogInPostHandler{clients, errCh})
+	mux.Handle("/post/client", &clientPostHandler{clients, errCh})
+	mux.Handle("/in/gamews", *createWsHandler(clients, cfg.Origins, errCh))
+	mux.Handle("/in/poll/", &pollHandler{clients, errCh})
+	mux.Handle("/in/logout", &logOutHandler{clients, errCh})
+	mux.Handle("/ping", &pingHandler{clients, errCh})
+	mux.Handle("/protocol", &protocolHandler{errCh})
+	mux.Handle("/events", &eventsHandler{clients, errCh})
+	mux.Handle("/in/admin/", &adminHandler{clients, errServer, errCh, cfg.DrainTimeout.D()})
+	mux.Handle("/in/account/", &accountHandler{clients, errCh})
+	mux.Handle("/", http.FileServer(http.Dir(cfg.RootDir)))
+
+	server := &http.Server{Handler: mux}
+	var listener net.Listener = tcpKeepAliveListener{netListener}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	err := server.Serve(listener)
 	err = errors.Wrap(err, log.ErrNo(4)+"Http server serves")
 	errCh <- err
 	close(doneCh)
 }
 
 //createWsHandler create the websocket handler.
-func createWsHandler(clients *Clients, errCh chan<- error) (server *websocket.Server) {
+//The handshake only accept the origins of the server host and the allowed origins.
+func createWsHandler(clients *Clients, origins []string, errCh chan<- error) (server *websocket.Server) {
 	wsHandshake := func(ws *websocket.Config, r *http.Request) (err error) {
+		if !isOriginOk(ws.Origin, r, origins) {
+			err = errors.New(fmt.Sprintf(log.ErrNo(31)+"Websocket handshake origin %v rejected! Ip: %v", ws.Origin, r.RemoteAddr))
+			errCh <- err
+			return err
+		}
 		name, sid, err := getCookies(r)
 		if err != nil {
 			err = errors.Wrap(err, log.ErrNo(5)+"Websocket handshake")
@@ -154,7 +210,7 @@ func createWsHandler(clients *Clients, errCh chan<- error) (server *websocket.Se
 	wsHandler := func(ws *websocket.Conn) {
 		joinedCh := make(chan *games.Player)
 		name, sid, err := getCookies(ws.Request())
-		ok, isJoined := clients.JoinGameServer(name, sid, ws, errCh, joinedCh)
+		ok, isJoined := clients.JoinGameServer(name, sid, games.NewWsConn(ws), errCh, joinedCh)
 		if ok {
 			player := <-joinedCh
 			player.Serve()
@@ -179,6 +235,25 @@ func createWsHandler(clients *Clients, errCh chan<- error) (server *websocket.Se
 	return server
 }
 
+//isOriginOk checks the websocket origin. The origin must be the host
+//of the request or one of the allowed origins.
+func isOriginOk(origin *url.URL, r *http.Request, origins []string) (ok bool) {
+	if origin == nil {
+		return false
+	}
+	if origin.Host == r.Host {
+		return true
+	}
+	originTxt := origin.Scheme + "://" + origin.Host
+	for _, allowed := range origins {
+		if strings.TrimSuffix(allowed, "/") == originTxt {
+			ok = true
+			break
+		}
+	}
+	return ok
+}
+
 //getCookies extract the name and session cookies.
 func getCookies(r *http.Request) (name string, sid string, err error) {
 	nameC, err := r.Cookie("name")
@@ -211,6 +286,19 @@ func (p *pingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	p.errCh <- err
 }
 
+//protocolHandler serves the client protocol schema.
+// ex: curl http://localhost:8282/protocol
+type protocolHandler struct {
+	errCh chan<- error
+}
+
+func (p *protocolHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := httpWrite(games.Schema(), w)
+	if err != nil {
+		p.errCh <- err
+	}
+}
+
 //logInPostHandler the login post handler.
 type logInPostHandler struct {
 	clients *Clients
@@ -220,12 +308,21 @@ type logInPostHandler struct {
 func (g *logInPostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	name := r.FormValue("txtUserName")
 	pw := r.FormValue("pwdPassword")
-	status, sid, err := g.clients.LogIn(name, pw)
+	ip := remoteIP(r)
+	var status login.Status
+	var sid string
+	var err error
+	if isThrottled(w, g.clients.throttle, ip, name) {
+		status = login.StatusAll.Retry
+	} else {
+		status, sid, err = g.clients.LogIn(name, pw)
+		g.clients.throttle.finish(ip, name, status, err)
+	}
 	if err != nil {
 		g.errCh <- err
 		status = login.StatusAll.Err
 	} else if status.IsOk() {
-		setCookies(w, name, sid)
+		setCookies(w, r, name, sid, g.clients.sessionExpiry)
 	} else if status.IsInValid() {
 		err = errors.New(fmt.Sprintf(log.ErrNo(10)+"Login failed! %v Ip: %v", status, r.RemoteAddr))
 		g.errCh <- err
@@ -235,6 +332,53 @@ func (g *logInPostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		g.errCh <- err
 	}
 }
+
+//botLogInPostHandler the bot login post handler, bots log in with a api token.
+type botLogInPostHandler struct {
+	clients *Clients
+	errCh   chan<- error
+}
+
+func (g *botLogInPostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.FormValue("txtUserName")
+	token := r.FormValue("txtToken")
+	ip := remoteIP(r)
+	var status login.Status
+	var sid string
+	var err error
+	if isThrottled(w, g.clients.throttle, ip, name) {
+		status = login.StatusAll.Retry
+	} else {
+		status, sid, err = g.clients.LogInToken(name, token)
+		g.clients.throttle.finish(ip, name, status, err)
+	}
+	if err != nil {
+		g.errCh <- err
+		status = login.StatusAll.Err
+	} else if status.IsOk() {
+		setCookies(w, r, name, sid, g.clients.sessionExpiry)
+	} else if status.IsInValid() {
+		err = errors.New(fmt.Sprintf(log.ErrNo(10)+"Bot login failed! %v Ip: %v", status, r.RemoteAddr))
+		g.errCh <- err
+	}
+	err = httpWrite(struct{ LogInStatus login.Status }{LogInStatus: status}, w)
+	if err != nil {
+		g.errCh <- err
+	}
+}
+
+//isThrottled checks if the log-in of the account or ip is locked,
+//if locked the Retry-After header is set. If not locked the attempt
+//is reserved and must be finished with the throttle.
+func isThrottled(w http.ResponseWriter, t *throttle, ip, name string) bool {
+	wait := t.reserve(ip, name)
+	if wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+		return true
+	}
+	return false
+}
+
 func httpWrite(v interface{}, w http.ResponseWriter) (err error) {
 	js, err := json.Marshal(v)
 	if err != nil {
@@ -255,19 +399,55 @@ func httpWrite(v interface{}, w http.ResponseWriter) (err error) {
 }
 
 //setCookies set the name and session id cookies.
-func setCookies(w http.ResponseWriter, name string, sid string) {
+//The session cookie is not readable from javascript, the name cookie is
+//used by the web page. The cookies is only send over https when the
+//request is https.
+func setCookies(w http.ResponseWriter, r *http.Request, name string, sid string, expiry time.Duration) {
+	isSecure := r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+	maxAge := int(expiry / time.Second)
 	nameC := new(http.Cookie)
 	nameC.Name = "name"
 	nameC.Value = name
 	nameC.Path = "/in"
+	nameC.MaxAge = maxAge
+	nameC.Secure = isSecure
+	nameC.SameSite = http.SameSiteStrictMode
 	sidC := new(http.Cookie)
 	sidC.Name = "sid"
 	sidC.Value = sid
 	sidC.Path = "/in"
+	sidC.MaxAge = maxAge
+	sidC.Secure = isSecure
+	sidC.HttpOnly = true
+	sidC.SameSite = http.SameSiteStrictMode
 	http.SetCookie(w, sidC)
 	http.SetCookie(w, nameC)
 }
 
+//logOutHandler the logout handler, the session is revoked and
+//the cookies deleted.
+type logOutHandler struct {
+	clients *Clients
+	errCh   chan<- error
+}
+
+func (handler *logOutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	isFound := false
+	name, sid, err := getCookies(r)
+	if err == nil {
+		isFound = handler.clients.LogOutSession(name, sid)
+	}
+	setCookies(w, r, "", "", -time.Second)
+	err = httpWrite(struct{ IsLogOut bool }{IsLogOut: isFound}, w)
+	if err != nil {
+		handler.errCh <- err
+	}
+}
+
 //clientPostHandler the new client post handler.
 type clientPostHandler struct {
 	clients *Clients
@@ -287,7 +467,7 @@ func (handler *clientPostHandler) ServeHTTP(w http.ResponseWriter, r *http.Reque
 			errSize := errors.New(log.ErrNo(13) + errTxt)
 			handler.errCh <- errSize
 		} else if status.IsOk() {
-			setCookies(w, name, sid)
+			setCookies(w, r, name, sid, handler.clients.sessionExpiry)
 		}
 	}
 	err = httpWrite(struct{ LogInStatus login.Status }{LogInStatus: status}, w)
@@ -318,16 +498,17 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 //backUpServe serves http backups
 // ex: curl --output testclients.db http://localhost:5555/backup/clients
 //     wget -O testgames.db http://localhost:5555/backup/games
-func backUpServe(gamesServer *games.Server, cdb *CDb, port string) {
-	http.HandleFunc("/backup/games",
+func backUpServe(gamesServer *games.Server, cdb *CDb, port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/backup/games",
 		func(resp http.ResponseWriter, req *http.Request) {
 			gamesServer.BackupHandleFunc(resp, req)
 		})
-	http.HandleFunc("/backup/clients",
+	mux.HandleFunc("/backup/clients",
 		func(resp http.ResponseWriter, req *http.Request) {
 			cdb.BackupHandleFunc(resp, req)
 		})
-	err := http.ListenAndServe(":"+port, nil)
+	err := http.ListenAndServe(":"+strconv.Itoa(port), mux)
 	if err != nil {
 		err = errors.Wrap(err, "Backup http server failed")
 		log.PrintErr(err)
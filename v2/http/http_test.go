@@ -0,0 +1,56 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/rezder/go-battleline/v2/http/config"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServersSideBySide(t *testing.T) {
+	servers := make([]*Server, 2)
+	for i := range servers {
+		dir, err := ioutil.TempDir("", "battserver")
+		if err != nil {
+			t.Fatalf("Creating temp dir failed: %v", err)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		cfg := config.Default()
+		cfg.DataDir = dir
+		cfg.RootDir = dir
+		cfg.Port = 0
+		cfg.ArchPokePort = 0
+		servers[i], err = New(cfg)
+		if err != nil {
+			t.Fatalf("Creating server %v failed: %v", i, err)
+		}
+		servers[i].Start()
+		defer servers[i].Stop()
+	}
+	for i, server := range servers {
+		resp, err := http.Get("http://" + server.Addr().String() + "/ping")
+		if err != nil {
+			t.Fatalf("Ping server %v failed: %v", i, err)
+		}
+		var ping struct{ IsUp bool }
+		err = json.NewDecoder(resp.Body).Decode(&ping)
+		_ = resp.Body.Close()
+		if err != nil || !ping.IsUp {
+			t.Errorf("Server %v should be up got: %v, %v", i, ping, err)
+		}
+		buf := new(bytes.Buffer)
+		err = server.metrics.Write(buf)
+		if err != nil {
+			t.Fatalf("Writing metrics failed: %v", err)
+		}
+		for _, sample := range []string{"battleline_clients_logged_in 0", "battleline_tables 0", `battleline_db_size_bytes{db="clients"}`} {
+			if !strings.Contains(buf.String(), sample) {
+				t.Errorf("Metrics missing: %v got:\n%v", sample, buf.String())
+			}
+		}
+	}
+}
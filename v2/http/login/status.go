@@ -22,6 +22,7 @@ type StatusAllST struct {
 	Exist    Status
 	Disabled Status
 	Err      Status
+	Retry    Status
 }
 
 func newStatusAllST() (l StatusAllST) {
@@ -32,6 +33,7 @@ func newStatusAllST() (l StatusAllST) {
 	l.Disabled = 4
 	l.Exist = 5
 	l.Err = 6
+	l.Retry = 7
 	return l
 }
 
@@ -52,6 +54,10 @@ func (l Status) String() (txt string) {
 		txt = "Account disabled"
 	case StatusAll.Exist:
 		txt = "Double access"
+	case StatusAll.Err:
+		txt = "Server error"
+	case StatusAll.Retry:
+		txt = "Too many failed attempts, retry later"
 	default:
 		panic(fmt.Sprintf("Login status: %v does not exist ", int(l)))
 	}
@@ -72,3 +78,9 @@ func (l Status) IsExist() bool {
 func (l Status) IsDisable() bool {
 	return l == StatusAll.Disabled
 }
+
+//IsRetry the log-in is locked because of too many failed attempts,
+//the client must retry later.
+func (l Status) IsRetry() bool {
+	return l == StatusAll.Retry
+}
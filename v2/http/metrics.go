@@ -0,0 +1,66 @@
+package http
+
+import (
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/http/games"
+	"github.com/rezder/go-battleline/v2/http/metrics"
+	"github.com/rezder/go-error/log"
+	"net/http"
+	"strconv"
+)
+
+//newMetrics creates the server metrics.
+func newMetrics(clients *Clients, gameServer *games.Server) (reg *metrics.Registry) {
+	reg = metrics.NewRegistry()
+	reg.Register(
+		metrics.NewGaugeFunc("battleline_clients_logged_in", "The number of logged-in clients.",
+			func() float64 {
+				logIns, _, _ := clients.Counts()
+				return float64(logIns)
+			}),
+		metrics.NewGaugeFunc("battleline_websockets", "The number of clients with a websocket.",
+			func() float64 {
+				_, websockets, _ := clients.Counts()
+				return float64(websockets)
+			}),
+		metrics.NewGaugeFunc("battleline_poll_connections", "The number of clients with a long-poll connection.",
+			func() float64 {
+				_, _, polls := clients.Counts()
+				return float64(polls)
+			}),
+		metrics.NewGaugeVecFunc("battleline_db_size_bytes", "The size of the bolt databases.", "db",
+			func() map[string]float64 { return dbSizes(clients.cdb, gameServer) }),
+	)
+	gameServer.RegisterMetrics(reg)
+	return reg
+}
+
+//dbSizes returns the database sizes, a database that fails is left out.
+func dbSizes(cdb *CDb, gameServer *games.Server) (sizes map[string]float64) {
+	sizes = make(map[string]float64)
+	size, err := cdb.Size()
+	if err != nil {
+		log.PrintErr(errors.Wrap(err, "Reading clients database size failed"))
+	} else {
+		sizes["clients"] = float64(size)
+	}
+	size, err = gameServer.DbSize()
+	if err != nil {
+		log.PrintErr(errors.Wrap(err, "Reading saved games database size failed"))
+	} else {
+		sizes["savegames"] = float64(size)
+	}
+	return sizes
+}
+
+//metricsServe serves the metrics in the Prometheus text format.
+// ex: curl http://localhost:9100/metrics
+func metricsServe(reg *metrics.Registry, port int) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", reg)
+	err := http.ListenAndServe(":"+strconv.Itoa(port), mux)
+	if err != nil {
+		err = errors.Wrap(err, "Metrics http server failed")
+		log.PrintErr(err)
+	}
+}
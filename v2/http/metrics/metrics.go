@@ -0,0 +1,232 @@
+//Package metrics contains a small metrics registry that is written
+//in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	//ContentType the Prometheus text format content type.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+//Metric is a metric that can be registered.
+type Metric interface {
+	write(w *bufio.Writer)
+}
+
+//Registry is a list of metrics.
+type Registry struct {
+	mu      *sync.Mutex
+	metrics []Metric
+}
+
+//NewRegistry creates a empty registry.
+func NewRegistry() (r *Registry) {
+	r = new(Registry)
+	r.mu = new(sync.Mutex)
+	return r
+}
+
+//Register adds metrics to the registry.
+func (r *Registry) Register(metrics ...Metric) {
+	r.mu.Lock()
+	r.metrics = append(r.metrics, metrics...)
+	r.mu.Unlock()
+}
+
+//Write writes the metrics in the text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	metrics := make([]Metric, len(r.metrics))
+	copy(metrics, r.metrics)
+	r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, metric := range metrics {
+		metric.write(bw)
+	}
+	return bw.Flush()
+}
+
+//ServeHTTP serves the metrics.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = r.Write(w)
+}
+
+//Counter is a counter that only increases.
+type Counter struct {
+	name  string
+	help  string
+	value int64
+}
+
+//NewCounter creates a counter.
+func NewCounter(name, help string) *Counter {
+	return &Counter{name: name, help: help}
+}
+
+//Inc increments the counter.
+func (c *Counter) Inc() {
+	atomic.AddInt64(&c.value, 1)
+}
+
+//Add adds to the counter, the value must not be negative.
+func (c *Counter) Add(value int64) {
+	atomic.AddInt64(&c.value, value)
+}
+
+//Value returns the counter value.
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	writeHeader(w, c.name, c.help, "counter")
+	writeSample(w, c.name, "", float64(c.Value()))
+}
+
+//Histogram counts observations in buckets.
+type Histogram struct {
+	name   string
+	help   string
+	mu     *sync.Mutex
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+//NewHistogram creates a histogram, bounds is the upper bounds of the
+//buckets in increasing order. The +Inf bucket is added.
+func NewHistogram(name, help string, bounds []float64) (h *Histogram) {
+	h = new(Histogram)
+	h.name = name
+	h.help = help
+	h.mu = new(sync.Mutex)
+	h.bounds = bounds
+	h.counts = make([]uint64, len(bounds))
+	return h
+}
+
+//Observe adds a observation.
+func (h *Histogram) Observe(value float64) {
+	h.mu.Lock()
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum = h.sum + value
+	h.count++
+	h.mu.Unlock()
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	sum := h.sum
+	count := h.count
+	h.mu.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	for i, bound := range h.bounds {
+		writeSample(w, h.name+"_bucket", labelTxt("le", formatFloat(bound)), float64(counts[i]))
+	}
+	writeSample(w, h.name+"_bucket", labelTxt("le", "+Inf"), float64(count))
+	writeSample(w, h.name+"_sum", "", sum)
+	writeSample(w, h.name+"_count", "", float64(count))
+}
+
+//Func is a metric where the value is read when the metrics is written.
+type Func struct {
+	name  string
+	help  string
+	kind  string
+	value func() float64
+}
+
+//NewGaugeFunc creates a gauge read from a function.
+func NewGaugeFunc(name, help string, value func() float64) *Func {
+	return &Func{name: name, help: help, kind: "gauge", value: value}
+}
+
+//NewCounterFunc creates a counter read from a function.
+func NewCounterFunc(name, help string, value func() float64) *Func {
+	return &Func{name: name, help: help, kind: "counter", value: value}
+}
+
+func (f *Func) write(w *bufio.Writer) {
+	writeHeader(w, f.name, f.help, f.kind)
+	writeSample(w, f.name, "", f.value())
+}
+
+//VecFunc is a metric with one label where the values is read
+//when the metrics is written. The values is keyed by the label value.
+type VecFunc struct {
+	name   string
+	help   string
+	kind   string
+	label  string
+	values func() map[string]float64
+}
+
+//NewGaugeVecFunc creates a labeled gauge read from a function.
+func NewGaugeVecFunc(name, help, label string, values func() map[string]float64) *VecFunc {
+	return &VecFunc{name: name, help: help, kind: "gauge", label: label, values: values}
+}
+
+//NewCounterVecFunc creates a labeled counter read from a function.
+func NewCounterVecFunc(name, help, label string, values func() map[string]float64) *VecFunc {
+	return &VecFunc{name: name, help: help, kind: "counter", label: label, values: values}
+}
+
+func (v *VecFunc) write(w *bufio.Writer) {
+	values := v.values()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	writeHeader(w, v.name, v.help, v.kind)
+	for _, key := range keys {
+		writeSample(w, v.name, labelTxt(v.label, key), values[key])
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	helpTxt := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, helpTxt, name, kind)
+}
+
+func writeSample(w *bufio.Writer, name, labels string, value float64) {
+	fmt.Fprintf(w, "%v%v %v\n", name, labels, formatFloat(value))
+}
+
+//labelTxt returns the label set of one label with the value escaped.
+func labelTxt(label, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return "{" + label + "=\"" + value + "\"}"
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	counter := NewCounter("test_moves_total", "The moves.")
+	counter.Inc()
+	counter.Add(2)
+	hist := NewHistogram("test_latency_seconds", "The latency.", []float64{0.5, 1})
+	hist.Observe(0.25)
+	hist.Observe(0.75)
+	hist.Observe(2)
+	gauge := NewGaugeFunc("test_tables", "The tables.", func() float64 { return 4 })
+	vec := NewGaugeVecFunc("test_db_size_bytes", "The sizes.", "db", func() map[string]float64 {
+		return map[string]float64{"games": 2048, `c"l`: 1024}
+	})
+	reg.Register(counter, hist, gauge, vec)
+	buf := new(bytes.Buffer)
+	err := reg.Write(buf)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	expected := `# HELP test_moves_total The moves.
+# TYPE test_moves_total counter
+test_moves_total 3
+# HELP test_latency_seconds The latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.5"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3
+test_latency_seconds_count 3
+# HELP test_tables The tables.
+# TYPE test_tables gauge
+test_tables 4
+# HELP test_db_size_bytes The sizes.
+# TYPE test_db_size_bytes gauge
+test_db_size_bytes{db="c\"l"} 1024
+test_db_size_bytes{db="games"} 2048
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, buf.String())
+	}
+}
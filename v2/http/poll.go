@@ -0,0 +1,252 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/rezder/go-battleline/v2/http/games"
+	"github.com/rezder/go-error/log"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	//pollBuffLIMIT the number of messages the client have not fetched
+	//before the connection is broken.
+	pollBuffLIMIT = 100
+	//pollWAIT the maximum time a poll waits for messages.
+	pollWAIT = 25 * time.Second
+	//pollIDLE the time without polls before the client is gone.
+	pollIDLE = 2 * time.Minute
+)
+
+//pollConn is a http long-poll player connection. The client post its
+//actions and polls the messages with a cursor, the cursor is the
+//sequence number of the next message. The messages before the cursor
+//is acknowledged and deleted.
+type pollConn struct {
+	mu       *sync.Mutex
+	messages []*games.JsonData
+	first    int64         //The sequence number of the first message.
+	newCh    chan struct{} //Closed and replaced when messages is added.
+	actCh    chan *games.Action
+	closeCh  chan struct{}
+	isClosed bool
+	lastPoll time.Time
+}
+
+func newPollConn() (c *pollConn) {
+	c = new(pollConn)
+	c.mu = new(sync.Mutex)
+	c.newCh = make(chan struct{})
+	c.actCh = make(chan *games.Action)
+	c.closeCh = make(chan struct{})
+	c.lastPoll = time.Now()
+	return c
+}
+
+//Send adds a message, a error is returned when the client have
+//to many messages waiting.
+func (c *pollConn) Send(data *games.JsonData) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isClosed {
+		return errors.New("Poll connection closed")
+	}
+	if len(c.messages) >= pollBuffLIMIT {
+		return errors.New("Poll connection buffer full")
+	}
+	c.messages = append(c.messages, data)
+	close(c.newCh)
+	c.newCh = make(chan struct{})
+	return err
+}
+
+//Receive receives a posted action. io.EOF is returned when the
+//connection is closed or the client stopped polling.
+func (c *pollConn) Receive(act *games.Action, deadline time.Time) (err error) {
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	idleTicker := time.NewTicker(pollIDLE / 4)
+	defer idleTicker.Stop()
+	for {
+		select {
+		case postAct := <-c.actCh:
+			*act = *postAct
+			return err
+		case <-c.closeCh:
+			return io.EOF
+		case <-timer.C:
+			return errors.New("Poll connection receive timeout")
+		case <-idleTicker.C:
+			c.mu.Lock()
+			isIdle := time.Since(c.lastPoll) > pollIDLE
+			c.mu.Unlock()
+			if isIdle {
+				return io.EOF
+			}
+		}
+	}
+}
+
+//Close closes the connection, the client can still poll the
+//messages send before the close.
+func (c *pollConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.isClosed {
+		c.isClosed = true
+		close(c.closeCh)
+	}
+	return nil
+}
+
+//post posts a action from the client.
+func (c *pollConn) post(act *games.Action) (err error) {
+	timer := time.NewTimer(pollWAIT)
+	defer timer.Stop()
+	select {
+	case c.actCh <- act:
+	case <-c.closeCh:
+		err = errors.New("Poll connection closed")
+	case <-timer.C:
+		err = errors.New("Poll connection busy")
+	}
+	return err
+}
+
+//poll returns the messages from the cursor, it waits until a message
+//exist, the wait or the connection is closed. isClosed is true
+//when the connection is closed and all messages is fetched.
+func (c *pollConn) poll(cursor int64, wait time.Duration) (messages []*games.JsonData, next int64, isClosed bool, err error) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		c.lastPoll = time.Now()
+		end := c.first + int64(len(c.messages))
+		if cursor < c.first || cursor > end {
+			c.mu.Unlock()
+			err = errors.New(fmt.Sprintf("Poll cursor %v out of range %v-%v", cursor, c.first, end))
+			return messages, next, isClosed, err
+		}
+		c.messages = c.messages[cursor-c.first:]
+		c.first = cursor
+		newCh := c.newCh
+		if len(c.messages) > 0 || c.isClosed {
+			messages = make([]*games.JsonData, len(c.messages))
+			copy(messages, c.messages)
+			isClosed = c.isClosed && len(messages) == 0
+			c.mu.Unlock()
+			return messages, end, isClosed, err
+		}
+		c.mu.Unlock()
+		select {
+		case <-newCh:
+		case <-c.closeCh:
+		case <-timer.C:
+			return messages, end, isClosed, err
+		}
+	}
+}
+
+//pollHandler handles the long-poll transport, the alternative to the
+//websocket. The client joins the game server, polls the messages with
+//a cursor and post its actions as json.
+// ex: curl -b "name=Rene;sid=1234" -X POST http://localhost:8282/in/poll/join
+//     curl -b "name=Rene;sid=1234" http://localhost:8282/in/poll/messages?cursor=0
+//     curl -b "name=Rene;sid=1234" -d '{"ActType":2,"ID":3}' http://localhost:8282/in/poll/action
+type pollHandler struct {
+	clients *Clients
+	errCh   chan<- error
+}
+
+//PollData is the response of a poll. Cursor is the cursor of the next poll
+//and IsClosed is true when the connection is closed.
+type PollData struct {
+	Messages []*games.JsonData
+	Cursor   int64
+	IsClosed bool
+}
+
+func (handler *pollHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, sid, err := getCookies(r)
+	if err != nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	if r.URL.Path == "/in/poll/join" {
+		handler.join(w, r, name, sid)
+		return
+	}
+	conn, isFound := handler.clients.PollConn(name, sid)
+	if !isFound {
+		http.Error(w, "Not joined", http.StatusGone)
+		return
+	}
+	switch r.URL.Path {
+	case "/in/poll/messages":
+		cursor, convErr := strconv.ParseInt(r.FormValue("cursor"), 10, 64)
+		if convErr != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		messages, next, isClosed, pollErr := conn.poll(cursor, pollWAIT)
+		if pollErr != nil {
+			http.Error(w, pollErr.Error(), http.StatusBadRequest)
+			return
+		}
+		err = httpWrite(&PollData{Messages: messages, Cursor: next, IsClosed: isClosed}, w)
+	case "/in/poll/action":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		act := games.NewAction(0)
+		decErr := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(act)
+		if decErr != nil {
+			handler.errCh <- errors.Wrap(decErr, log.ErrNo(32)+fmt.Sprintf("Poll action from %v failed! Ip: %v", name, r.RemoteAddr))
+			http.Error(w, "Invalid action", http.StatusBadRequest)
+			return
+		}
+		err = conn.post(act)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		err = httpWrite(struct{ IsPosted bool }{IsPosted: true}, w)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		handler.errCh <- err
+	}
+}
+
+//join joins the client to the game server with a new long-poll
+//connection. The player is served until the connection closes.
+func (handler *pollHandler) join(w http.ResponseWriter, r *http.Request, name, sid string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	conn := newPollConn()
+	joinedCh := make(chan *games.Player)
+	ok, isJoined := handler.clients.JoinGameServer(name, sid, conn, handler.errCh, joinedCh)
+	if ok {
+		go func() {
+			player := <-joinedCh
+			player.Serve()
+			_ = conn.Close()
+			handler.clients.LogOut(name)
+		}()
+	}
+	err := httpWrite(struct{ IsJoined, IsAllreadyJoined bool }{IsJoined: ok, IsAllreadyJoined: isJoined}, w)
+	if err != nil {
+		handler.errCh <- err
+	}
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"github.com/rezder/go-battleline/v2/http/games"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPollConn(t *testing.T) {
+	conn := newPollConn()
+	for i := 0; i < 3; i++ {
+		err := conn.Send(&games.JsonData{JsonType: games.JTMess, Data: i})
+		if err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	messages, next, isClosed, err := conn.poll(0, time.Millisecond)
+	if err != nil || len(messages) != 3 || next != 3 || isClosed {
+		t.Fatalf("Expected 3 messages got: %v, %v, %v, %v", len(messages), next, isClosed, err)
+	}
+	messages, next, _, err = conn.poll(2, time.Millisecond)
+	if err != nil || len(messages) != 1 || next != 3 {
+		t.Errorf("Expected the last message got: %v, %v, %v", len(messages), next, err)
+	}
+	if _, _, _, err = conn.poll(1, time.Millisecond); err == nil {
+		t.Error("Acknowledged cursor should fail")
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = conn.Send(&games.JsonData{JsonType: games.JTMess, Data: 3})
+	}()
+	messages, next, _, err = conn.poll(3, time.Second)
+	if err != nil || len(messages) != 1 || next != 4 {
+		t.Errorf("Poll should wait for the message got: %v, %v, %v", len(messages), next, err)
+	}
+	go func() {
+		if postErr := conn.post(&games.Action{ActType: games.ACTIDInvite, ID: 7}); postErr != nil {
+			t.Errorf("Post failed: %v", postErr)
+		}
+	}()
+	var act games.Action
+	err = conn.Receive(&act, time.Now().Add(time.Second))
+	if err != nil || act.ID != 7 {
+		t.Errorf("Receive failed: %v, %v", act, err)
+	}
+	for i := 0; i < pollBuffLIMIT; i++ {
+		err = conn.Send(&games.JsonData{JsonType: games.JTMess, Data: i})
+	}
+	if err == nil {
+		t.Error("Send should fail when the buffer is full")
+	}
+	_ = conn.Close()
+	if err = conn.Receive(&act, time.Now().Add(time.Second)); err != io.EOF {
+		t.Errorf("Receive should return eof after close got: %v", err)
+	}
+	messages, next, isClosed, err = conn.poll(4, time.Second)
+	if err != nil || len(messages) != pollBuffLIMIT-1 || isClosed {
+		t.Errorf("Messages send before close should be fetched got: %v, %v, %v", len(messages), isClosed, err)
+	}
+	_, _, isClosed, err = conn.poll(next, time.Second)
+	if err != nil || !isClosed {
+		t.Errorf("Poll should be closed got: %v, %v", isClosed, err)
+	}
+}
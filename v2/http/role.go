@@ -0,0 +1,71 @@
+package http
+
+import (
+	"fmt"
+)
+
+var (
+	//RoleAll is the account role domain.
+	RoleAll RoleAllST
+)
+
+func init() {
+	RoleAll = newRoleAllST()
+}
+
+//RoleAllST is the account role singleton.
+type RoleAllST struct {
+	Player Role
+	Admin  Role
+	Bot    Role
+}
+
+func newRoleAllST() (r RoleAllST) {
+	r.Player = 0
+	r.Admin = 1
+	r.Bot = 2
+	return r
+}
+
+//Role the account role domain value.
+//The zero value is a player so old accounts are players.
+type Role int
+
+func (r Role) String() (txt string) {
+	switch r {
+	case RoleAll.Player:
+		txt = "Player"
+	case RoleAll.Admin:
+		txt = "Admin"
+	case RoleAll.Bot:
+		txt = "Bot"
+	default:
+		panic(fmt.Sprintf("Role: %v does not exist ", int(r)))
+	}
+	return txt
+}
+
+//IsAdmin returns true if the role is admin.
+func (r Role) IsAdmin() bool {
+	return r == RoleAll.Admin
+}
+
+//IsBot returns true if the role is bot.
+func (r Role) IsBot() bool {
+	return r == RoleAll.Bot
+}
+
+//ParseRole parse a role name.
+func ParseRole(txt string) (role Role, err error) {
+	switch txt {
+	case "player":
+		role = RoleAll.Player
+	case "admin":
+		role = RoleAll.Admin
+	case "bot":
+		role = RoleAll.Bot
+	default:
+		err = fmt.Errorf("Role %v does not exist", txt)
+	}
+	return role, err
+}
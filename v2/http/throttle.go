@@ -0,0 +1,260 @@
+package http
+
+import (
+	"github.com/rezder/go-battleline/v2/http/login"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	//throttleACCOUNTFREE the number of failed log-ins before a account is locked.
+	throttleACCOUNTFREE = 5
+	//throttleIPFREE the number of failed log-ins before a ip is locked,
+	//many players may share a ip.
+	throttleIPFREE = 20
+	//throttleBASE the first lockout, the lockout doubles with every failure.
+	throttleBASE = time.Second
+	//throttleMAX the maximum lockout.
+	throttleMAX = 15 * time.Minute
+	//throttleRESET the time without failures before the failures are forgotten.
+	throttleRESET = time.Hour
+)
+
+//throttle keeps track of failed log-ins per account and per ip.
+//When the failures exceed the free limit the account or ip is locked
+//with a exponential growing lockout.
+type throttle struct {
+	mu        *sync.Mutex
+	accounts  map[string]*throttleEntry
+	ips       map[string]*throttleEntry
+	lastPrune time.Time
+}
+
+//throttleEntry the failed log-ins of a account or ip.
+//pending is the number of reserved log-ins that is not finished.
+type throttleEntry struct {
+	fails       int
+	pending     int
+	lastFail    time.Time
+	lockedUntil time.Time
+}
+
+func newThrottle() (t *throttle) {
+	t = new(throttle)
+	t.mu = new(sync.Mutex)
+	t.accounts = make(map[string]*throttleEntry)
+	t.ips = make(map[string]*throttleEntry)
+	t.lastPrune = time.Now()
+	return t
+}
+
+//reserve reserves a log-in attempt of the account and ip. The time
+//left of the lockout is returned if locked, zero if the attempt is
+//reserved. When the free log-ins are used only one attempt at a time
+//is allowed so parallel attempts can not bypass the lockout.
+//A reserved attempt must be finished with fail, success or release.
+func (t *throttle) reserve(ip, name string) (wait time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	if now.Sub(t.lastPrune) > throttleRESET {
+		t.prune(now)
+	}
+	if len(name) != 0 && t.accounts[name] == nil {
+		t.accounts[name] = new(throttleEntry)
+	}
+	if t.ips[ip] == nil {
+		t.ips[ip] = new(throttleEntry)
+	}
+	entries := t.entries(ip, name)
+	for i, entry := range entries {
+		free := throttleIPFREE
+		if i == 1 {
+			free = throttleACCOUNTFREE
+		}
+		if entry.lockedUntil.After(now) {
+			if d := entry.lockedUntil.Sub(now); d > wait {
+				wait = d
+			}
+		} else if entry.pending > 0 && entry.fails+entry.pending >= free && wait < throttleBASE {
+			wait = throttleBASE
+		}
+	}
+	if wait == 0 {
+		for _, entry := range entries {
+			entry.pending++
+		}
+	}
+	return wait
+}
+
+//entries returns the ip entry and the account entry if it exist.
+//The lock must be held.
+func (t *throttle) entries(ip, name string) (entries []*throttleEntry) {
+	for _, entry := range []*throttleEntry{t.ips[ip], t.accounts[name]} {
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+//release finishes a reserved log-in attempt without a result.
+func (t *throttle) release(ip, name string) {
+	t.mu.Lock()
+	t.releaseEntries(ip, name)
+	t.mu.Unlock()
+}
+
+//releaseEntries removes the reservation from the entries.
+//The lock must be held.
+func (t *throttle) releaseEntries(ip, name string) {
+	for _, entry := range t.entries(ip, name) {
+		if entry.pending > 0 {
+			entry.pending--
+		}
+	}
+}
+
+//fail finishes a reserved log-in attempt as failed.
+func (t *throttle) fail(ip, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.releaseEntries(ip, name)
+	now := time.Now()
+	if len(name) != 0 {
+		t.accounts[name] = failEntry(t.accounts[name], throttleACCOUNTFREE, now)
+	}
+	t.ips[ip] = failEntry(t.ips[ip], throttleIPFREE, now)
+}
+
+//failEntry updates a entry with a failure, a new entry is created
+//if entry is nil.
+func failEntry(entry *throttleEntry, free int, now time.Time) *throttleEntry {
+	if entry == nil {
+		entry = new(throttleEntry)
+	}
+	if now.Sub(entry.lastFail) > throttleRESET {
+		entry.fails = 0
+	}
+	entry.fails++
+	entry.lastFail = now
+	if entry.fails >= free {
+		lockout := throttleMAX
+		if n := uint(entry.fails - free); n < 20 {
+			lockout = throttleBASE << n
+			if lockout > throttleMAX {
+				lockout = throttleMAX
+			}
+		}
+		entry.lockedUntil = now.Add(lockout)
+	}
+	return entry
+}
+
+//success finishes a reserved log-in attempt as successful, the failures
+//of the account is forgotten. The ip is not reset as a valid account
+//could be used to hide attacks on other accounts.
+func (t *throttle) success(ip, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.releaseEntries(ip, name)
+	if entry, isFound := t.accounts[name]; isFound {
+		if entry.pending == 0 {
+			delete(t.accounts, name)
+		} else {
+			entry.fails = 0
+			entry.lockedUntil = time.Time{}
+		}
+	}
+}
+
+//unlock removes the lockout of a account or ip.
+func (t *throttle) unlock(key string) (isFound bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, isFound = t.accounts[key]; isFound {
+		delete(t.accounts, key)
+	}
+	if _, isIPFound := t.ips[key]; isIPFound {
+		delete(t.ips, key)
+		isFound = true
+	}
+	return isFound
+}
+
+//finish finishes a reserved log-in attempt with the result of the
+//attempt. A attempt with a error is released.
+func (t *throttle) finish(ip, name string, status login.Status, err error) {
+	switch {
+	case err != nil:
+		t.release(ip, name)
+	case status.IsOk():
+		t.success(ip, name)
+	case status.IsInValid():
+		t.fail(ip, name)
+	default:
+		t.release(ip, name)
+	}
+}
+
+//prune removes the forgotten entries.
+func (t *throttle) prune(now time.Time) {
+	for _, entries := range []map[string]*throttleEntry{t.accounts, t.ips} {
+		for key, entry := range entries {
+			if entry.pending == 0 && now.Sub(entry.lastFail) > throttleRESET {
+				delete(entries, key)
+			}
+		}
+	}
+	t.lastPrune = now
+}
+
+//LockoutData is the admin information of a account or ip with
+//failed log-ins.
+type LockoutData struct {
+	Key         string
+	IsIP        bool
+	Fails       int
+	LastFail    time.Time
+	LockedUntil time.Time
+	IsLocked    bool
+}
+
+//lockouts returns the accounts and ips with failed log-ins.
+func (t *throttle) lockouts() (lockouts []*LockoutData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	lockouts = make([]*LockoutData, 0, len(t.accounts)+len(t.ips))
+	for i, entries := range []map[string]*throttleEntry{t.accounts, t.ips} {
+		for key, entry := range entries {
+			if now.Sub(entry.lastFail) <= throttleRESET {
+				lockouts = append(lockouts, &LockoutData{
+					Key:         key,
+					IsIP:        i == 1,
+					Fails:       entry.fails,
+					LastFail:    entry.lastFail,
+					LockedUntil: entry.lockedUntil,
+					IsLocked:    entry.lockedUntil.After(now),
+				})
+			}
+		}
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LastFail.After(lockouts[j].LastFail)
+	})
+	return lockouts
+}
+
+//remoteIP returns the ip of the request without the port.
+func remoteIP(r *http.Request) (ip string) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return ip
+}
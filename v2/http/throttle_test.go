@@ -0,0 +1,98 @@
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	th := newThrottle()
+	ip := "127.0.0.1"
+	name := "Rene"
+	for i := 0; i < throttleACCOUNTFREE-1; i++ {
+		testThrottleFail(th, ip, name, t)
+		if wait := testThrottleWait(th, ip, name); wait != 0 {
+			t.Errorf("Fail %v should not lock got wait: %v", i+1, wait)
+		}
+	}
+	testThrottleFail(th, ip, name, t)
+	wait := testThrottleWait(th, ip, name)
+	if wait <= 0 || wait > throttleBASE {
+		t.Errorf("Account should be locked for %v got: %v", throttleBASE, wait)
+	}
+	th.fail(ip, name)
+	wait = testThrottleWait(th, ip, name)
+	if wait <= throttleBASE || wait > 2*throttleBASE {
+		t.Errorf("Lockout should double got: %v", wait)
+	}
+	if wait := testThrottleWait(th, ip, "Peter"); wait != 0 {
+		t.Errorf("Other account on same ip should not be locked got: %v", wait)
+	}
+	lockouts := th.lockouts()
+	if len(lockouts) != 2 {
+		t.Errorf("Expected a account and a ip entry got: %v", lockouts)
+	}
+	th.success(ip, name)
+	if wait := testThrottleWait(th, ip, name); wait != 0 {
+		t.Errorf("Success should reset the account got: %v", wait)
+	}
+	for i := 0; i < throttleIPFREE; i++ {
+		th.fail(ip, "")
+	}
+	if wait := testThrottleWait(th, ip, "Peter"); wait == 0 {
+		t.Error("Ip should be locked")
+	}
+	if !th.unlock(ip) {
+		t.Error("Unlock ip failed")
+	}
+	if wait := testThrottleWait(th, ip, "Peter"); wait != 0 {
+		t.Errorf("Unlocked ip should not be locked got: %v", wait)
+	}
+	entry := &throttleEntry{fails: 100, lastFail: time.Now()}
+	entry = failEntry(entry, throttleACCOUNTFREE, time.Now())
+	if time.Until(entry.lockedUntil) > throttleMAX {
+		t.Errorf("Lockout should be limited to %v got: %v", throttleMAX, time.Until(entry.lockedUntil))
+	}
+}
+
+func TestThrottleParallel(t *testing.T) {
+	th := newThrottle()
+	ip := "127.0.0.1"
+	name := "Rene"
+	for i := 0; i < throttleACCOUNTFREE; i++ {
+		if wait := th.reserve(ip, name); wait != 0 {
+			t.Fatalf("Parallel attempt %v should be reserved got wait: %v", i+1, wait)
+		}
+	}
+	if wait := th.reserve(ip, name); wait == 0 {
+		t.Error("Parallel attempts should not exceed the free log-ins")
+	}
+	th.release(ip, name)
+	if wait := th.reserve(ip, name); wait != 0 {
+		t.Errorf("Released attempt should free a reservation got wait: %v", wait)
+	}
+	for i := 0; i < throttleACCOUNTFREE; i++ {
+		th.fail(ip, name)
+	}
+	if wait := th.reserve(ip, name); wait == 0 {
+		t.Error("Account should be locked after the parallel failures")
+	}
+}
+
+//testThrottleFail makes a failed log-in attempt.
+func testThrottleFail(th *throttle, ip, name string, t *testing.T) {
+	if wait := th.reserve(ip, name); wait != 0 {
+		t.Fatalf("Attempt should be reserved got wait: %v", wait)
+	}
+	th.fail(ip, name)
+}
+
+//testThrottleWait returns the lockout of the account and ip, a reserved
+//attempt is released.
+func testThrottleWait(th *throttle, ip, name string) (wait time.Duration) {
+	wait = th.reserve(ip, name)
+	if wait == 0 {
+		th.release(ip, name)
+	}
+	return wait
+}
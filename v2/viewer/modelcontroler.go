@@ -90,8 +90,9 @@ type ModelCont struct {
 	stdErrCh <-chan string
 }
 
-//NewModelCont creates a model controler.
-func NewModelCont(modelDir string, port int) (mc *ModelCont, err error) {
+//NewModelCont creates a model controler, the model is served by the
+//python tfServerFile.
+func NewModelCont(tfServerFile, modelDir string, port int) (mc *ModelCont, err error) {
 	cpFile := filepath.Join(modelDir, "checkpoint")
 	_, err = os.Stat(cpFile)
 	if err != nil {
@@ -102,7 +103,7 @@ func NewModelCont(modelDir string, port int) (mc *ModelCont, err error) {
 	mc = new(ModelCont)
 	mc.modelDir = modelDir
 	mc.port = port
-	mc.cmd = exec.Command("python", "/home/rho/Python/tensorflow/battleline/botserver.py", "--model_dir="+modelDir, "--port="+strconv.Itoa(port))
+	mc.cmd = exec.Command("python", tfServerFile, "--model_dir="+modelDir, "--port="+strconv.Itoa(port))
 
 	stdErrCh := make(chan string, 100)
 	stdOutCh := make(chan string, 100)
@@ -19,7 +19,9 @@ import (
 
 // Server a view battleline server.
 type Server struct {
-	port int
+	port         int
+	rootDir      string
+	tfServerFile string
 	*sync.RWMutex
 	dbHistFile string
 	dbHist     *dbhist.Db
@@ -27,9 +29,13 @@ type Server struct {
 }
 
 //New creates a new battleline voew server.
-func New(port int) (server *Server, err error) {
+//rootDir is the http files root directory and tfServerFile is the
+//python tensorflow model server file.
+func New(port int, rootDir, tfServerFile string) (server *Server, err error) {
 	server = new(Server)
 	server.port = port
+	server.rootDir = rootDir
+	server.tfServerFile = tfServerFile
 	server.RWMutex = new(sync.RWMutex)
 	server.modelConts = make(map[string]*ModelCont)
 	return server, err
@@ -37,7 +43,7 @@ func New(port int) (server *Server, err error) {
 
 //Start starts a server.
 func (server *Server) Start() {
-	http.Handle("/", http.FileServer(http.Dir("/home/rho/js/batt-app/build/")))
+	http.Handle("/", http.FileServer(http.Dir(server.rootDir)))
 	http.Handle("/dir/", &dirHandler{server: server})
 	http.Handle("/games/", &gamesHandler{server: server})
 	http.Handle("/model/", &modelHandler{server: server})
@@ -98,7 +104,7 @@ func (server *Server) UpdateModel(modelDir string) (err error) {
 	if !ok {
 		port := len(server.modelConts) + 5555
 		var mc *ModelCont
-		mc, err = NewModelCont(modelDir, port)
+		mc, err = NewModelCont(server.tfServerFile, modelDir, port)
 		if err != nil {
 			return err
 		}
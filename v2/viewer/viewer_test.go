@@ -3,15 +3,20 @@ package viewer
 import (
 	"fmt"
 	"github.com/rezder/go-error/log"
+	"os"
 	"testing"
 	"time"
 )
 
 func TestModelCont(t *testing.T) {
+	tfServerFile := os.Getenv("BATT_TFSERVER")
+	modelDir := os.Getenv("BATT_TFMODELDIR")
+	if len(tfServerFile) == 0 || len(modelDir) == 0 {
+		t.Skip("Set BATT_TFSERVER and BATT_TFMODELDIR to test the model server")
+	}
 	log.InitLog(log.Debug)
-	modelDir := "/home/rho/Python/tensorflow/battleline/model/"
 	port := 6555
-	mc, err := NewModelCont(modelDir, port)
+	mc, err := NewModelCont(tfServerFile, modelDir, port)
 	if err != nil {
 		t.Fatal(err)
 	}